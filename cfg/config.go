@@ -2,8 +2,11 @@ package cfg
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/pflag"
@@ -11,16 +14,71 @@ import (
 )
 
 const (
-	defaultBaseURL       = "http://localhost:8080/"
-	defaultServerAddress = ":8080"
+	defaultBaseURL         = "http://localhost:8080/"
+	defaultServerAddress   = ":8080"
+	defaultClickBufferSize = 10000
+	defaultRedirect        = 307
+	defaultAllowedSchemes  = "http,https"
+	defaultPasswordTries   = 5
+	defaultPasswordLockout = 15 * time.Minute
+	defaultNotYetActive    = 404
 )
 
 type Config struct {
-	ServerAddress   string `json:"server_address"`
-	BaseUrl         string `json:"base_url"`
-	FileStoragePath string `json:"file_storage_path"`
-	DatabaseDsn     string `json:"database_dsn"`
-	EnableHttps     bool   `json:"enable_https"`
+	ServerAddress            string           `json:"server_address"`
+	BaseUrl                  string           `json:"base_url"`
+	FileStoragePath          string           `json:"file_storage_path"`
+	DatabaseDsn              string           `json:"database_dsn"`
+	ClickLogPath             string           `json:"click_log_path"`
+	ClickRetention           Duration         `json:"click_retention"`
+	ClickBufferSize          int              `json:"click_buffer_size"`
+	BotRulesPath             string           `json:"bot_rules_path"`
+	TrustedSubnet            string           `json:"trusted_subnet"`
+	DefaultRedirect          int              `json:"default_redirect"`
+	UTMTemplatesPath         string           `json:"utm_templates_path"`
+	AbuseReportsPath         string           `json:"abuse_reports_path"`
+	SortQuery                bool             `json:"sort_query"`
+	AllowedSchemes           []string         `json:"allowed_schemes"`
+	AllowedDomains           []string         `json:"allowed_domains"`
+	DeniedDomains            []string         `json:"denied_domains"`
+	AllowPrivateDestinations bool             `json:"allow_private_destinations"`
+	ResolveDestinations      bool             `json:"resolve_destinations"`
+	RedirectChainLimit       int              `json:"redirect_chain_limit"`
+	StoreFinalDestination    bool             `json:"store_final_destination"`
+	PasswordAttempts         int              `json:"password_attempts"`
+	PasswordLockout          Duration         `json:"password_lockout"`
+	NotYetActiveStatus       int              `json:"not_yet_active_status"`
+	NotYetActiveURL          string           `json:"not_yet_active_url"`
+	RateLimitShorten         int              `json:"rate_limit_shorten"`
+	RateLimitBatch           int              `json:"rate_limit_batch"`
+	RateLimitDelete          int              `json:"rate_limit_delete"`
+	RateLimitRedirect        int              `json:"rate_limit_redirect"`
+	LinkQuota                int              `json:"link_quota"`
+	BatchLimit               int              `json:"batch_limit"`
+	QuotaOverrides           map[string]Quota `json:"quota_overrides"`
+	EnableHttps              bool             `json:"enable_https"`
+}
+
+// Quota - ограничения пользователя из quota_overrides, заменяющие link_quota и batch_limit, 0 - без ограничения.
+// Ограничения отдельных пользователей задаются в файле конфигурации или флагом quota-overrides,
+// администратор может менять их на ходу через внутреннее API
+type Quota struct {
+	Links int `json:"links"`
+	Batch int `json:"batch"`
+}
+
+// Duration - time.Duration, который в файле конфигурации задается строкой вида "720h"
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) (err error) {
+	var s string
+	if err = json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	d.Duration, err = time.ParseDuration(s)
+	return err
 }
 
 func GetConfig() *Config {
@@ -30,6 +88,33 @@ func GetConfig() *Config {
 	pflag.StringP("file-storage-path", "f", "", "sets path for file storage")
 	pflag.StringP("database-dsn", "d", "", "sets connection string for postgres DB")
 	pflag.BoolP("enable-https", "s", false, "enable https protocol")
+	pflag.String("click-log-path", "", "sets path for JSON lines click log, used if database is not set")
+	pflag.Duration("click-retention", 0, "sets how long click events are kept, 0 keeps them forever")
+	pflag.Int("click-buffer-size", defaultClickBufferSize, "sets capacity of in memory click log")
+	pflag.StringP("trusted-subnet", "t", "", "sets CIDR of clients allowed to use internal API")
+	pflag.String("utm-templates-path", "", "sets path for JSON lines UTM templates journal, used if database is not set")
+	pflag.String("abuse-reports-path", "", "sets path for JSON lines abuse reports journal, used if database is not set")
+	pflag.Int("default-redirect", defaultRedirect, "sets redirect status code (301, 302, 307 or 308) for links created without one")
+	pflag.Bool("sort-query", false, "sort query parameters by name when checking links for duplicates")
+	pflag.String("allowed-schemes", defaultAllowedSchemes, "sets comma separated schemes allowed for shortened links")
+	pflag.String("allowed-domains", "", "sets comma separated domains allowed for shortened links, *.example.com matches subdomains")
+	pflag.String("denied-domains", "", "sets comma separated domains denied for shortened links, *.example.com matches subdomains")
+	pflag.Bool("allow-private-destinations", false, "allow links to loopback, private and link-local addresses")
+	pflag.Bool("resolve-destinations", false, "resolve link hosts on shortening to block names pointing to private addresses")
+	pflag.Int("redirect-chain-limit", 0, "sets how many redirects of link destination are followed on shortening, 0 disables following")
+	pflag.Bool("store-final-destination", false, "store the end of destination redirect chain instead of the link itself")
+	pflag.Int("password-attempts", defaultPasswordTries, "sets how many wrong passwords of a link may be entered per lockout period, negative disables limit")
+	pflag.Duration("password-lockout", defaultPasswordLockout, "sets period of wrong link passwords counting")
+	pflag.Int("not-yet-active-status", defaultNotYetActive, "sets error status code for links opened before their activity window")
+	pflag.String("not-yet-active-url", "", "sets page to redirect links opened before their activity window to, overrides status")
+	pflag.Int("rate-limit-shorten", 0, "sets how many links a client may shorten per minute, 0 disables limit")
+	pflag.Int("rate-limit-batch", 0, "sets how many batch shorten requests a client may send per minute, 0 disables limit")
+	pflag.Int("rate-limit-delete", 0, "sets how many delete, restore and update requests of links a client may send per minute, 0 disables limit")
+	pflag.Int("rate-limit-redirect", 0, "sets how many short links a client may open per minute, 0 disables limit")
+	pflag.Int("link-quota", 0, "sets how many active links a user may own, 0 disables limit")
+	pflag.Int("batch-limit", 0, "sets how many links a batch shorten request may contain, 0 disables limit")
+	pflag.String("quota-overrides", "", "sets comma separated user quotas as user=links/batch, they override file ones")
+	pflag.String("bot-rules-path", "", "sets path to user agent substrings for bot detection, built-in rules are used if not set")
 	pflag.Parse()
 	err := viper.BindPFlags(pflag.CommandLine)
 	if err != nil {
@@ -88,4 +173,138 @@ func (c *Config) expandConfigFromFlags() {
 	if viper.GetBool("enable-https") {
 		c.EnableHttps = viper.GetBool("enable-https")
 	}
+	if viper.GetString("click-log-path") != "" {
+		c.ClickLogPath = viper.GetString("click-log-path")
+	}
+	if viper.GetDuration("click-retention") != 0 {
+		c.ClickRetention.Duration = viper.GetDuration("click-retention")
+	}
+	if viper.GetInt("click-buffer-size") != defaultClickBufferSize || c.ClickBufferSize == 0 {
+		c.ClickBufferSize = viper.GetInt("click-buffer-size")
+	}
+	if viper.GetString("trusted-subnet") != "" {
+		c.TrustedSubnet = viper.GetString("trusted-subnet")
+	}
+	if viper.GetInt("default-redirect") != defaultRedirect || c.DefaultRedirect == 0 {
+		c.DefaultRedirect = viper.GetInt("default-redirect")
+	}
+	if viper.GetString("utm-templates-path") != "" {
+		c.UTMTemplatesPath = viper.GetString("utm-templates-path")
+	}
+	if viper.GetString("abuse-reports-path") != "" {
+		c.AbuseReportsPath = viper.GetString("abuse-reports-path")
+	}
+	if viper.GetBool("sort-query") {
+		c.SortQuery = viper.GetBool("sort-query")
+	}
+	if viper.GetString("allowed-schemes") != defaultAllowedSchemes || len(c.AllowedSchemes) == 0 {
+		c.AllowedSchemes = splitList(viper.GetString("allowed-schemes"))
+	}
+	if viper.GetString("allowed-domains") != "" {
+		c.AllowedDomains = splitList(viper.GetString("allowed-domains"))
+	}
+	if viper.GetString("denied-domains") != "" {
+		c.DeniedDomains = splitList(viper.GetString("denied-domains"))
+	}
+	if viper.GetBool("allow-private-destinations") {
+		c.AllowPrivateDestinations = viper.GetBool("allow-private-destinations")
+	}
+	if viper.GetBool("resolve-destinations") {
+		c.ResolveDestinations = viper.GetBool("resolve-destinations")
+	}
+	if viper.GetInt("redirect-chain-limit") != 0 {
+		c.RedirectChainLimit = viper.GetInt("redirect-chain-limit")
+	}
+	if viper.GetBool("store-final-destination") {
+		c.StoreFinalDestination = viper.GetBool("store-final-destination")
+	}
+	if viper.GetInt("password-attempts") != defaultPasswordTries || c.PasswordAttempts == 0 {
+		c.PasswordAttempts = viper.GetInt("password-attempts")
+	}
+	if viper.GetDuration("password-lockout") != defaultPasswordLockout || c.PasswordLockout.Duration == 0 {
+		c.PasswordLockout.Duration = viper.GetDuration("password-lockout")
+	}
+	if viper.GetInt("not-yet-active-status") != defaultNotYetActive || c.NotYetActiveStatus == 0 {
+		c.NotYetActiveStatus = viper.GetInt("not-yet-active-status")
+	}
+	if viper.GetString("not-yet-active-url") != "" {
+		c.NotYetActiveURL = viper.GetString("not-yet-active-url")
+	}
+	if viper.GetInt("rate-limit-shorten") != 0 {
+		c.RateLimitShorten = viper.GetInt("rate-limit-shorten")
+	}
+	if viper.GetInt("rate-limit-batch") != 0 {
+		c.RateLimitBatch = viper.GetInt("rate-limit-batch")
+	}
+	if viper.GetInt("rate-limit-delete") != 0 {
+		c.RateLimitDelete = viper.GetInt("rate-limit-delete")
+	}
+	if viper.GetInt("rate-limit-redirect") != 0 {
+		c.RateLimitRedirect = viper.GetInt("rate-limit-redirect")
+	}
+	if viper.GetInt("link-quota") != 0 {
+		c.LinkQuota = viper.GetInt("link-quota")
+	}
+	if viper.GetInt("batch-limit") != 0 {
+		c.BatchLimit = viper.GetInt("batch-limit")
+	}
+	if viper.GetString("quota-overrides") != "" {
+		if c.QuotaOverrides == nil {
+			c.QuotaOverrides = make(map[string]Quota)
+		}
+		for user, quota := range parseQuotas(viper.GetString("quota-overrides")) {
+			c.QuotaOverrides[user] = quota
+		}
+	}
+	if viper.GetString("bot-rules-path") != "" {
+		c.BotRulesPath = viper.GetString("bot-rules-path")
+	}
+}
+
+// splitList разбирает список значений через запятую, пустые значения отбрасываются.
+func splitList(s string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, strings.ToLower(v))
+		}
+	}
+	return list
+}
+
+// parseQuotas разбирает ограничения пользователей вида "user=links/batch" через запятую.
+// Записи с ошибкой отбрасываются.
+func parseQuotas(s string) map[string]Quota {
+	quotas := make(map[string]Quota)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		user, q, err := parseQuota(v)
+		if err != nil {
+			log.Err(err).Msgf("invalid quota override %s, it will be ignored", v)
+			continue
+		}
+		quotas[user] = q
+	}
+	return quotas
+}
+
+// parseQuota разбирает ограничения одного пользователя вида "user=links/batch".
+func parseQuota(s string) (user string, q Quota, err error) {
+	user, limits, ok := strings.Cut(s, "=")
+	links, batch, ok2 := strings.Cut(limits, "/")
+	if user = strings.TrimSpace(user); !ok || !ok2 || user == "" {
+		return "", Quota{}, errors.New("user=links/batch is expected")
+	}
+	if q.Links, err = strconv.Atoi(strings.TrimSpace(links)); err != nil {
+		return "", Quota{}, err
+	}
+	if q.Batch, err = strconv.Atoi(strings.TrimSpace(batch)); err != nil {
+		return "", Quota{}, err
+	}
+	if q.Links < 0 || q.Batch < 0 {
+		return "", Quota{}, errors.New("limits must not be negative")
+	}
+	return user, q, nil
 }
@@ -2,12 +2,20 @@ package main
 
 import (
 	"database/sql"
+	"net"
 	"os"
+	"time"
 
 	"github.com/UndeadDemidov/yandex-praktikum/cfg"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/abuse"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/chain"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/clicks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/policy"
 	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages/database"
 	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages/file"
 	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages/memory"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utm"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -17,10 +25,15 @@ func init() {
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr}).With().Caller().Logger()
 
 	config = cfg.GetConfig()
-	initRepository()
+	db := initRepository()
+	initClickRecorder(db)
+	initTemplates(db)
+	initReports(db)
 }
 
-func initRepository() {
+// initRepository выбирает и создает хранилище ссылок.
+// Возвращает соединение с БД, если выбрано хранение в БД, иначе nil.
+func initRepository() *sql.DB {
 	var (
 		err error
 		db  *sql.DB
@@ -38,7 +51,7 @@ func initRepository() {
 		repo, err = database.NewStorage(db)
 		if err == nil {
 			log.Info().Msg("In database storage will be used")
-			return
+			return db
 		}
 	}
 
@@ -47,10 +60,140 @@ func initRepository() {
 		repo, err = file.NewStorage(filename)
 		if err == nil {
 			log.Info().Msg("In file storage will be used")
-			return
+			return nil
 		}
 	}
 
 	repo = memory.NewStorage()
 	log.Info().Msg("In memory storage will be used")
+	return nil
+}
+
+// initClickRecorder выбирает хранилище журнала переходов по тому же принципу, что и хранилище ссылок:
+// БД, если она используется, затем файл, если он указан, и в последнюю очередь память.
+func initClickRecorder(db *sql.DB) {
+	retention := config.ClickRetention.Duration
+	classifier := initClassifier()
+
+	if db != nil {
+		sink, err := clicks.NewDatabaseSink(db)
+		if err == nil {
+			recorder = clicks.NewRecorder(sink, retention, classifier)
+			log.Info().Msg("In database click log will be used")
+			return
+		}
+	}
+
+	filename := config.ClickLogPath
+	if len(filename) != 0 {
+		sink, err := clicks.NewFileSink(filename)
+		if err == nil {
+			recorder = clicks.NewRecorder(sink, retention, classifier)
+			log.Info().Msg("In file click log will be used")
+			return
+		}
+	}
+
+	recorder = clicks.NewRecorder(clicks.NewRingSink(config.ClickBufferSize), retention, classifier)
+	log.Info().Msg("In memory click log will be used")
+}
+
+// initTemplates выбирает хранилище шаблонов UTM меток по тому же принципу, что и хранилище ссылок.
+func initTemplates(db *sql.DB) {
+	if db != nil {
+		store, err := utm.NewDatabaseStore(db)
+		if err == nil {
+			templates = store
+			log.Info().Msg("In database UTM templates will be used")
+			return
+		}
+	}
+
+	filename := config.UTMTemplatesPath
+	if len(filename) != 0 {
+		store, err := utm.NewFileStore(filename)
+		if err == nil {
+			templates = store
+			log.Info().Msg("In file UTM templates will be used")
+			return
+		}
+	}
+
+	templates = utm.NewMemoryStore()
+	log.Info().Msg("In memory UTM templates will be used")
+}
+
+// initReports выбирает хранилище жалоб на ссылки по тому же принципу, что и хранилище ссылок.
+func initReports(db *sql.DB) {
+	if db != nil {
+		store, err := abuse.NewDatabaseStore(db)
+		if err == nil {
+			reports = store
+			log.Info().Msg("In database abuse reports will be used")
+			return
+		}
+	}
+
+	filename := config.AbuseReportsPath
+	if len(filename) != 0 {
+		store, err := abuse.NewFileStore(filename)
+		if err == nil {
+			reports = store
+			log.Info().Msg("In file abuse reports will be used")
+			return
+		}
+	}
+
+	reports = abuse.NewMemoryStore()
+	log.Info().Msg("In memory abuse reports will be used")
+}
+
+// initPolicy собирает правила для оригинальных ссылок из конфигурации.
+func initPolicy() policy.Policy {
+	p := policy.Policy{
+		Schemes:      config.AllowedSchemes,
+		Allow:        config.AllowedDomains,
+		Deny:         config.DeniedDomains,
+		BlockPrivate: !config.AllowPrivateDestinations,
+	}
+	if config.ResolveDestinations {
+		p.Resolver = net.DefaultResolver
+	}
+	return p
+}
+
+// initQuotas собирает ограничения пользователей из конфигурации.
+func initQuotas() handlers.Quotas {
+	q := handlers.Quotas{
+		Default: handlers.Quota{Links: config.LinkQuota, Batch: config.BatchLimit},
+		Users:   make(map[string]handlers.Quota, len(config.QuotaOverrides)),
+	}
+	for user, quota := range config.QuotaOverrides {
+		q.Users[user] = handlers.Quota{Links: quota.Links, Batch: quota.Batch}
+	}
+	return q
+}
+
+// initRedirectChain возвращает опцию прохода цепочек перенаправлений, если он включен в конфигурации.
+func initRedirectChain() handlers.Option {
+	var f *chain.Follower
+	if config.RedirectChainLimit > 0 {
+		f = chain.NewFollower(chain.NewClient(3*time.Second), config.RedirectChainLimit)
+		log.Info().Msgf("Redirect chains up to %d hops will be followed", config.RedirectChainLimit)
+	}
+	return handlers.WithRedirectChain(f, config.StoreFinalDestination)
+}
+
+// initClassifier загружает правила распознавания ботов из файла, если он указан.
+// При ошибке загрузки используются встроенные правила.
+func initClassifier() *clicks.Classifier {
+	if len(config.BotRulesPath) == 0 {
+		return nil
+	}
+	c, err := clicks.LoadClassifier(config.BotRulesPath)
+	if err != nil {
+		log.Err(err).Msgf("can't load bot rules from %s, built-in rules will be used", config.BotRulesPath)
+		return nil
+	}
+	return c
 }
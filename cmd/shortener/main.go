@@ -10,9 +10,14 @@ import (
 	"time"
 
 	"github.com/UndeadDemidov/yandex-praktikum/cfg"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/abuse"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/canonical"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/clicks"
 	"github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/password"
 	"github.com/UndeadDemidov/yandex-praktikum/internal/app/server"
 	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utm"
 	_ "github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 )
@@ -22,6 +27,9 @@ var (
 	buildDate    string = "N/A"
 	buildCommit  string = "N/A"
 	repo         handlers.Repository
+	recorder     *clicks.Recorder
+	templates    utm.Store
+	reports      abuse.Store
 	config       *cfg.Config
 )
 
@@ -37,7 +45,17 @@ func main() {
 // CreateServer создает сервер и возвращает его и репозиторий.
 // Можно заменить параметры на глобальные переменные, вроде как от этого ничего плохого не будет.
 func CreateServer() *http.Server {
-	return server.NewServer(config.BaseUrl, config.ServerAddress, repo)
+	return server.NewServer(config, repo,
+		handlers.WithClickRecorder(recorder),
+		handlers.WithDefaultRedirect(config.DefaultRedirect),
+		handlers.WithUTMTemplates(templates),
+		handlers.WithAbuseReports(reports),
+		handlers.WithPasswordAttempts(password.NewLimiter(config.PasswordAttempts, config.PasswordLockout.Duration)),
+		handlers.WithNotYetActive(config.NotYetActiveStatus, config.NotYetActiveURL),
+		handlers.WithCanonicalization(canonical.Options{SortQuery: config.SortQuery}),
+		handlers.WithDestinationPolicy(initPolicy()),
+		handlers.WithQuotas(initQuotas()),
+		initRedirectChain())
 }
 
 // Run запускает сервер с указанным репозиторием и реализуем graceful shutdown
@@ -74,7 +92,20 @@ func Run(srv *http.Server) {
 	log.Info().Msg("Server stopped")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer func() {
-		err := repo.Close()
+		// журнал кликов, шаблоны и жалобы закрываем раньше репозитория, так как они могут делить соединение с БД
+		err := recorder.Close()
+		if err != nil {
+			log.Error().Msgf("Caught an error due closing click recorder:%+v", err)
+		}
+		err = templates.Close()
+		if err != nil {
+			log.Error().Msgf("Caught an error due closing UTM templates:%+v", err)
+		}
+		err = reports.Close()
+		if err != nil {
+			log.Error().Msgf("Caught an error due closing abuse reports:%+v", err)
+		}
+		err = repo.Close()
 		if err != nil {
 			log.Error().Msgf("Caught an error due closing repository:%+v", err)
 		}
@@ -12,9 +12,12 @@ require (
 	github.com/masibw/goone v1.4.1
 	github.com/matoous/go-nanoid/v2 v2.0.0
 	github.com/rs/zerolog v1.27.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.11.0
 	github.com/stretchr/testify v1.7.1
+	golang.org/x/crypto v0.0.0-20220722155217-630584e8d5aa
+	golang.org/x/net v0.0.0-20220722155237-a158d28d115b
 	golang.org/x/tools v0.1.11
 	honnef.co/go/tools v0.3.3
 )
@@ -0,0 +1,77 @@
+// Package abuse ведет очередь жалоб на короткие ссылки, например на фишинг.
+// Жалобы на ссылку остаются в очереди модерации, пока администратор не примет по ней решение:
+// заблокировать ссылку или оставить ее доступной.
+package abuse
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+const maxReasonLength = 1000
+
+var ErrReasonIsTooLong = errors.New("report reason must be at most 1000 characters")
+
+// Report - жалоба пользователя на короткую ссылку ID.
+// От одного пользователя учитывается только последняя жалоба на ссылку.
+type Report struct {
+	ID       string    `json:"id"`
+	Reporter string    `json:"reporter"`
+	Reason   string    `json:"reason,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Validate проверяет длину причины жалобы.
+func (r Report) Validate() error {
+	if utf8.RuneCountInString(r.Reason) > maxReasonLength {
+		return ErrReasonIsTooLong
+	}
+	return nil
+}
+
+// Entry - ссылка в очереди модерации вместе с жалобами на нее, упорядоченными по времени.
+type Entry struct {
+	ID      string
+	Reports []Report
+}
+
+// Last возвращает время последней жалобы.
+func (e Entry) Last() time.Time {
+	return e.Reports[len(e.Reports)-1].Time
+}
+
+// Store описывает контракт хранилища жалоб.
+type Store interface {
+	// Add добавляет жалобу в очередь, заменяя предыдущую жалобу того же пользователя на ту же ссылку.
+	Add(ctx context.Context, r Report) error
+	// Queue возвращает очередь модерации: сначала ссылки с большим числом жалоб,
+	// при равенстве - с более ранней последней жалобой.
+	Queue(ctx context.Context) ([]Entry, error)
+	// Resolve убирает из очереди все жалобы на ссылку id после решения администратора.
+	Resolve(ctx context.Context, id string) error
+	// Close завершает работу хранилища.
+	Close() error
+}
+
+// sortQueue упорядочивает жалобы внутри ссылок и сами ссылки согласно Store.Queue.
+func sortQueue(queue []Entry) []Entry {
+	for _, e := range queue {
+		sort.SliceStable(e.Reports, func(i, j int) bool {
+			return e.Reports[i].Time.Before(e.Reports[j].Time)
+		})
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		a, b := queue[i], queue[j]
+		if len(a.Reports) != len(b.Reports) {
+			return len(a.Reports) > len(b.Reports)
+		}
+		if !a.Last().Equal(b.Last()) {
+			return a.Last().Before(b.Last())
+		}
+		return a.ID < b.ID
+	})
+	return queue
+}
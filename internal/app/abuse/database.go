@@ -0,0 +1,96 @@
+package abuse
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	createReportsStatement = `CREATE TABLE IF NOT EXISTS abuse_reports
+							  (
+							      link_id     VARCHAR     NOT NULL,
+							      reporter    VARCHAR     NOT NULL,
+							      reason      VARCHAR     NOT NULL DEFAULT '',
+							      reported_at TIMESTAMPTZ NOT NULL,
+							      CONSTRAINT abuse_reports_pk PRIMARY KEY (link_id, reporter)
+							  );`
+	addReportStatement = `INSERT INTO abuse_reports (link_id, reporter, reason, reported_at)
+						  VALUES ($1, $2, $3, $4)
+						  ON CONFLICT (link_id, reporter) DO UPDATE
+						  SET reason = EXCLUDED.reason, reported_at = EXCLUDED.reported_at`
+	queueQuery              = `SELECT link_id, reporter, reason, reported_at FROM abuse_reports`
+	resolveReportsStatement = `DELETE FROM abuse_reports WHERE link_id = $1`
+)
+
+// DatabaseStore реализует хранение жалоб в таблице PostgreSQL.
+// Соединение с БД разделяется с хранилищем ссылок, поэтому DatabaseStore его не закрывает.
+type DatabaseStore struct {
+	database *sql.DB
+}
+
+var _ Store = (*DatabaseStore)(nil)
+
+// NewDatabaseStore создает и возвращает DatabaseStore, при необходимости создает таблицу жалоб.
+func NewDatabaseStore(db *sql.DB) (*DatabaseStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, createReportsStatement); err != nil {
+		return nil, err
+	}
+	return &DatabaseStore{database: db}, nil
+}
+
+// Add добавляет жалобу в очередь.
+func (s *DatabaseStore) Add(ctx context.Context, r Report) error {
+	_, err := s.database.ExecContext(ctx, addReportStatement, r.ID, r.Reporter, r.Reason, r.Time)
+	return err
+}
+
+// Queue возвращает очередь модерации. Очередь невелика, поэтому группируется и упорядочивается в памяти.
+func (s *DatabaseStore) Queue(ctx context.Context) ([]Entry, error) {
+	rows, err := s.database.QueryContext(ctx, queueQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Err(err).Send()
+		}
+	}()
+
+	index := make(map[string]int)
+	queue := make([]Entry, 0)
+	for rows.Next() {
+		var r Report
+		if err = rows.Scan(&r.ID, &r.Reporter, &r.Reason, &r.Time); err != nil {
+			return nil, err
+		}
+		r.Time = r.Time.UTC()
+		i, ok := index[r.ID]
+		if !ok {
+			i = len(queue)
+			index[r.ID] = i
+			queue = append(queue, Entry{ID: r.ID})
+		}
+		queue[i].Reports = append(queue[i].Reports, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return sortQueue(queue), nil
+}
+
+// Resolve убирает из очереди жалобы на ссылку id.
+func (s *DatabaseStore) Resolve(ctx context.Context, id string) error {
+	_, err := s.database.ExecContext(ctx, resolveReportsStatement, id)
+	return err
+}
+
+// Close ничего не делает: соединение с БД закрывает хранилище ссылок.
+func (s *DatabaseStore) Close() error {
+	return nil
+}
@@ -0,0 +1,105 @@
+package abuse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+)
+
+// FileStore реализует хранение жалоб в файле - журнале изменений в формате JSON lines.
+// При открытии журнал целиком читается в память, каждое изменение дописывается в конец файла.
+type FileStore struct {
+	MemoryStore
+	file    *os.File
+	encoder *json.Encoder
+}
+
+var _ Store = (*FileStore)(nil)
+
+// change - запись журнала: жалоба или решение по ссылке Resolved.
+type change struct {
+	Report   *Report `json:"report,omitempty"`
+	Resolved string  `json:"resolved,omitempty"`
+}
+
+// NewFileStore создает и возвращает FileStore, изменения дописываются в конец указанного файла.
+func NewFileStore(filename string) (s *FileStore, err error) {
+	if err = utils.CheckFilename(filename); err != nil {
+		return nil, err
+	}
+	s = &FileStore{MemoryStore: MemoryStore{reports: make(map[string]map[string]Report)}}
+	if err = s.load(filename); err != nil {
+		return nil, err
+	}
+	s.file, err = os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s.encoder = json.NewEncoder(s.file)
+	return s, nil
+}
+
+// load применяет журнал к пустому хранилищу.
+func (s *FileStore) load(filename string) error {
+	f, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	for {
+		var c change
+		err = decoder.Decode(&c)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if c.Report != nil {
+			s.add(*c.Report)
+			continue
+		}
+		delete(s.reports, c.Resolved)
+	}
+}
+
+// Add дописывает жалобу в журнал и добавляет ее в очередь в памяти.
+func (s *FileStore) Add(_ context.Context, r Report) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if err := s.encoder.Encode(change{Report: &r}); err != nil {
+		return err
+	}
+	s.add(r)
+	return nil
+}
+
+// Resolve дописывает решение в журнал и убирает жалобы на ссылку из памяти.
+func (s *FileStore) Resolve(_ context.Context, id string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if _, ok := s.reports[id]; !ok {
+		return nil
+	}
+	if err := s.encoder.Encode(change{Resolved: id}); err != nil {
+		return err
+	}
+	delete(s.reports, id)
+	return nil
+}
+
+// Close закрывает файл журнала.
+func (s *FileStore) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,52 @@
+package abuse
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "abuse.json")
+	s, err := NewFileStore(filename)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	start := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	first := Report{ID: "1111", Reporter: "xxxx", Reason: "spam", Time: start}
+	phishing := Report{ID: "2222", Reporter: "xxxx", Reason: "phishing", Time: start.Add(time.Minute)}
+	again := Report{ID: "2222", Reporter: "yyyy", Time: start.Add(2 * time.Minute)}
+	other := Report{ID: "3333", Reporter: "yyyy", Time: start.Add(3 * time.Minute)}
+	require.NoError(t, s.Add(ctx, first))
+	require.NoError(t, s.Add(ctx, Report{ID: "2222", Reporter: "yyyy", Reason: "old", Time: start}))
+	require.NoError(t, s.Add(ctx, phishing))
+	require.NoError(t, s.Add(ctx, again))
+	require.NoError(t, s.Add(ctx, other))
+	require.NoError(t, s.Resolve(ctx, "3333"))
+	require.NoError(t, s.Resolve(ctx, "4444"))
+	require.NoError(t, s.Close())
+
+	// журнал переживает перезапуск
+	s, err = NewFileStore(filename)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, s.Close())
+	}()
+
+	queue, err := s.Queue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{ID: "2222", Reports: []Report{phishing, again}},
+		{ID: "1111", Reports: []Report{first}},
+	}, queue)
+}
+
+func TestReport_Validate(t *testing.T) {
+	assert.NoError(t, Report{Reason: strings.Repeat("ф", maxReasonLength)}.Validate())
+	assert.ErrorIs(t, Report{Reason: strings.Repeat("ф", maxReasonLength+1)}.Validate(), ErrReasonIsTooLong)
+}
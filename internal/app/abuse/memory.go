@@ -0,0 +1,66 @@
+package abuse
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore реализует хранение жалоб в памяти.
+type MemoryStore struct {
+	// reports - map[id]map[reporter]Report
+	reports map[string]map[string]Report
+	mx      sync.RWMutex
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore создает и возвращает пустой MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{reports: make(map[string]map[string]Report)}
+}
+
+// Add добавляет жалобу в очередь.
+func (s *MemoryStore) Add(_ context.Context, r Report) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.add(r)
+	return nil
+}
+
+func (s *MemoryStore) add(r Report) {
+	if _, ok := s.reports[r.ID]; !ok {
+		s.reports[r.ID] = make(map[string]Report)
+	}
+	s.reports[r.ID][r.Reporter] = r
+}
+
+// Queue возвращает очередь модерации.
+func (s *MemoryStore) Queue(_ context.Context) ([]Entry, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	queue := make([]Entry, 0, len(s.reports))
+	for id, reports := range s.reports {
+		e := Entry{ID: id, Reports: make([]Report, 0, len(reports))}
+		for _, r := range reports {
+			e.Reports = append(e.Reports, r)
+		}
+		queue = append(queue, e)
+	}
+	return sortQueue(queue), nil
+}
+
+// Resolve убирает из очереди жалобы на ссылку id.
+func (s *MemoryStore) Resolve(_ context.Context, id string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	delete(s.reports, id)
+	return nil
+}
+
+// Close ничего не делает, требуется только для совместимости с контрактом.
+func (s *MemoryStore) Close() error {
+	return nil
+}
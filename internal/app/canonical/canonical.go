@@ -0,0 +1,187 @@
+// Package canonical приводит ссылки к канонической форме, по которой одинаковые по смыслу ссылки
+// распознаются как одна: HTTP://Example.com:80/a/../b и http://example.com/b.
+package canonical
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var (
+	ErrNoHost      = errors.New("url has no host")
+	ErrInvalidHost = errors.New("invalid host name")
+)
+
+// lookup - профиль IDNA для поиска по UTS #46: отображение регистра и совместимых символов,
+// нормализация NFC, проверка меток и их длины. Подчеркивание в именах хостов допускается,
+// так как встречается в реальных ссылках.
+var lookup = idna.New(idna.MapForLookup(), idna.StrictDomainName(false), idna.VerifyDNSLength(true))
+
+// defaultPorts - порты по умолчанию, которые не указываются в канонической форме.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+	"ftp":   "21",
+}
+
+// Options - необязательные шаги канонизации, меняющие ссылку не только синтаксически.
+type Options struct {
+	// SortQuery - упорядочивать параметры запроса по имени. Порядок одноименных параметров сохраняется.
+	// Большинство сайтов порядок параметров не учитывает, но это не гарантируется, поэтому по умолчанию выключено.
+	SortQuery bool
+}
+
+// URL возвращает каноническую форму ссылки: схема в нижнем регистре, хост в ASCII-форме IDNA
+// (нижний регистр, NFC, интернационализованные метки в Punycode), без порта по умолчанию, с пустым путем, замененным на /, без точечных сегментов пути
+// и с нормализованным процентным кодированием. Фрагмент и данные пользователя сохраняются.
+func URL(raw string, opts Options) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if u.Opaque != "" {
+		u.Scheme = strings.ToLower(u.Scheme)
+		return u.String(), nil
+	}
+	if u.Host == "" {
+		return "", ErrNoHost
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host, err = host(u)
+	if err != nil {
+		return "", err
+	}
+
+	path := removeDotSegments(normalizePercent(u.EscapedPath()))
+	u.Path, err = url.PathUnescape(path)
+	if err != nil {
+		return "", err
+	}
+	u.RawPath = path
+
+	query := normalizePercent(u.RawQuery)
+	if opts.SortQuery {
+		query = sortQuery(query)
+	}
+	u.RawQuery = query
+	u.ForceQuery = false
+	return u.String(), nil
+}
+
+// host возвращает хост и порт ссылки в канонической форме.
+func host(u *url.URL) (string, error) {
+	hostname, port := u.Hostname(), u.Port()
+	if hostname == "" {
+		return "", ErrNoHost
+	}
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+
+	if strings.Contains(hostname, ":") {
+		// IPv6 адрес
+		hostname = "[" + strings.ToLower(hostname) + "]"
+	} else {
+		ascii, err := lookup.ToASCII(hostname)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidHost, err)
+		}
+		hostname = ascii
+	}
+
+	if port == "" {
+		return hostname, nil
+	}
+	return net.JoinHostPort(strings.Trim(hostname, "[]"), port), nil
+}
+
+// removeDotSegments убирает из пути сегменты . и .. по RFC 3986, раздел 5.2.4.
+// Пустой путь заменяется на /, пустые сегменты сохраняются.
+func removeDotSegments(path string) string {
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	stack := make([]string, 0, len(segments))
+	for i, s := range segments {
+		last := i == len(segments)-1
+		switch s {
+		case ".":
+		case "..":
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		default:
+			stack = append(stack, s)
+			continue
+		}
+		if last {
+			// /a/b/.. означает каталог /a/
+			stack = append(stack, "")
+		}
+	}
+	return "/" + strings.Join(stack, "/")
+}
+
+// normalizePercent приводит процентное кодирование к верхнему регистру и раскодирует
+// незарезервированные символы, которые кодировать не нужно, по RFC 3986, раздел 6.2.2.
+func normalizePercent(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			b.WriteByte(s[i])
+			continue
+		}
+		c := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteString(strings.ToUpper(s[i : i+3]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+// sortQuery упорядочивает параметры запроса по имени, не перекодируя их. Пустые параметры отбрасываются.
+func sortQuery(query string) string {
+	params := strings.FieldsFunc(query, func(r rune) bool { return r == '&' })
+	sort.SliceStable(params, func(i, j int) bool {
+		ki, _, _ := strings.Cut(params[i], "=")
+		kj, _, _ := strings.Cut(params[j], "=")
+		return ki < kj
+	})
+	return strings.Join(params, "&")
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c <= '9':
+		return c - '0'
+	case c <= 'F':
+		return c - 'A' + 10
+	default:
+		return c - 'a' + 10
+	}
+}
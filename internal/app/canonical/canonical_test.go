@@ -0,0 +1,110 @@
+package canonical
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		opts Options
+		want string
+	}{
+		{
+			name: "scheme host and default port",
+			raw:  "HTTP://Example.COM:80/a?b=1&a=2",
+			want: "http://example.com/a?b=1&a=2",
+		},
+		{
+			name: "sorted query",
+			raw:  "HTTP://Example.com:80/a?b=1&a=2&a=1&",
+			opts: Options{SortQuery: true},
+			want: "http://example.com/a?a=2&a=1&b=1",
+		},
+		{
+			name: "other port is kept",
+			raw:  "https://example.com:8443",
+			want: "https://example.com:8443/",
+		},
+		{
+			name: "https default port",
+			raw:  "https://example.com:443/?",
+			want: "https://example.com/",
+		},
+		{
+			name: "dot segments",
+			raw:  "http://example.com/a/b/../c/./d/..",
+			want: "http://example.com/a/c/",
+		},
+		{
+			name: "percent encoding",
+			raw:  "http://example.com/%7euser/%2e%2E/a%2fb?q=%7e%2f",
+			want: "http://example.com/a%2Fb?q=~%2F",
+		},
+		{
+			name: "idn",
+			raw:  "https://Bücher.example/путь?x=1#Top",
+			want: "https://xn--bcher-kva.example/%D0%BF%D1%83%D1%82%D1%8C?x=1#Top",
+		},
+		{
+			name: "cyrillic tld",
+			raw:  "http://пример.испытание",
+			want: "http://xn--e1afmkfd.xn--80akhbyknj4f/",
+		},
+		{
+			name: "decomposed idn",
+			raw:  "https://Bu\u0308cher.example/",
+			want: "https://xn--bcher-kva.example/",
+		},
+		{
+			name: "fullwidth host and dots",
+			raw:  "http://ｅｘａｍｐｌｅ．ｃｏｍ。/",
+			want: "http://example.com./",
+		},
+		{
+			// RFC 3492, раздел 7.1, пример B
+			name: "chinese label",
+			raw:  "http://他们为什么不说中文.example",
+			want: "http://xn--ihqwcrb4cv8a8dqg056pqjye.example/",
+		},
+		{
+			name: "underscore",
+			raw:  "http://my_site.example.com",
+			want: "http://my_site.example.com/",
+		},
+		{
+			name: "ipv6 and userinfo",
+			raw:  "http://User@[2001:DB8::1]:80/",
+			want: "http://User@[2001:db8::1]/",
+		},
+		{
+			name: "ipv6 with port",
+			raw:  "http://[::1]:8080/",
+			want: "http://[::1]:8080/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := URL(tt.raw, tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := URL("/relative", Options{})
+	assert.ErrorIs(t, err, ErrNoHost)
+
+	for _, raw := range []string{
+		"http://xn--zz.example/",
+		"http://-start.example/",
+		"http://" + strings.Repeat("a", 64) + ".example/",
+	} {
+		_, err = URL(raw, Options{})
+		assert.ErrorIs(t, err, ErrInvalidHost, raw)
+	}
+}
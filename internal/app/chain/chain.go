@@ -0,0 +1,144 @@
+// Package chain проходит цепочку перенаправлений оригинальной ссылки, например ссылки другого сокращателя,
+// находит конечный адрес и обнаруживает петли.
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/canonical"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/policy"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrRedirectLoop         = errors.New("redirect loop detected")
+	ErrRedirectChainTooLong = errors.New("redirect chain is too long")
+)
+
+// CheckFunc проверяет очередной адрес цепочки до запроса к нему, например на соответствие правилам сервиса.
+type CheckFunc func(ctx context.Context, rawURL string) error
+
+// Follower проходит цепочки перенаправлений не длиннее заданного числа переходов.
+type Follower struct {
+	client  *http.Client
+	maxHops int
+}
+
+// NewFollower создает Follower. Клиент используется для запросов, перенаправления он сам не выполняет:
+// каждый переход проверяется Follower'ом. Если client nil, используется http.DefaultClient.
+// Для ссылок пользователей нужен клиент из NewClient, иначе сервис можно заставить обращаться во внутреннюю сеть.
+func NewFollower(client *http.Client, maxHops int) *Follower {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := *client
+	c.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &Follower{client: &c, maxHops: maxHops}
+}
+
+// NewClient создает клиент для Follower, который не соединяется с внутренними адресами независимо от правил сервиса.
+// Адрес проверяется при установке соединения, поэтому проверку не обойти именем хоста, которое разрешается
+// во внутренний адрес, или перенаправлением на него. Прокси из окружения не используется по той же причине.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: dialControl}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+// dialControl запрещает соединения с внутренними и групповыми адресами, см. policy.IsPrivate.
+func dialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || policy.IsPrivate(ip) || ip.IsMulticast() {
+		return fmt.Errorf("%w: %s", policy.ErrPrivateAddress, host)
+	}
+	return nil
+}
+
+// Resolve возвращает конечный адрес цепочки перенаправлений, начинающейся с rawURL.
+// Каждый адрес цепочки, включая первый, до запроса проверяется check, ее ошибка возвращается как есть.
+// Повтор адреса в цепочке - петля, возвращается ErrRedirectLoop, а если переходов больше maxHops -
+// ErrRedirectChainTooLong. Если очередной адрес недоступен, конечным считается он:
+// недоступность может быть временной, и ссылку не нужно из-за нее отклонять.
+func (f *Follower) Resolve(ctx context.Context, rawURL string, check CheckFunc) (string, error) {
+	visited := make(map[string]bool)
+	current := rawURL
+	for hop := 0; ; hop++ {
+		key, err := canonical.URL(current, canonical.Options{})
+		if err != nil {
+			return "", err
+		}
+		if visited[key] {
+			return "", fmt.Errorf("%w: %s", ErrRedirectLoop, current)
+		}
+		visited[key] = true
+		if check != nil {
+			if err = check(ctx, current); err != nil {
+				return "", err
+			}
+		}
+
+		next, err := f.next(ctx, current)
+		if err != nil {
+			log.Debug().Err(err).Msgf("can't follow %s, it will be treated as final destination", current)
+			return current, nil
+		}
+		if next == "" {
+			return current, nil
+		}
+		if hop == f.maxHops {
+			return "", fmt.Errorf("%w: more than %d redirects", ErrRedirectChainTooLong, f.maxHops)
+		}
+		current = next
+	}
+}
+
+// next запрашивает адрес и возвращает адрес перенаправления или пустую строку, если перенаправления нет.
+func (f *Follower) next(ctx context.Context, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	if err = resp.Body.Close(); err != nil {
+		log.Debug().Err(err).Send()
+	}
+
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return "", nil
+	}
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", nil
+	}
+	// Location может быть относительным
+	next, err := req.URL.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	return next.String(), nil
+}
+
+// IsChainError проверяет, что ошибка Resolve вызвана самой цепочкой, а не check или некорректной ссылкой.
+func IsChainError(err error) bool {
+	return errors.Is(err, ErrRedirectLoop) || errors.Is(err, ErrRedirectChainTooLong)
+}
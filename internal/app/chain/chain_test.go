@@ -0,0 +1,134 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/policy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRedirects поднимает сервер, перенаправляющий по таблице путь -> Location, остальные пути отвечают 200.
+func newRedirects(t *testing.T, redirects map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if location, ok := redirects[r.URL.Path]; ok {
+			http.Redirect(w, r, location, http.StatusMovedPermanently)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFollower_Resolve(t *testing.T) {
+	other := newRedirects(t, map[string]string{"/x": "/final"})
+	srv := newRedirects(t, map[string]string{
+		"/a":     "/b",
+		"/b":     other.URL + "/x",
+		"/loop1": "/loop2",
+		"/loop2": "/loop1",
+		"/self":  "/self",
+		"/long1": "/long2",
+		"/long2": "/long3",
+		"/long3": "/long4",
+		"/down":  "http://127.0.0.1:1/",
+	})
+
+	tests := []struct {
+		name    string
+		url     string
+		maxHops int
+		want    string
+		wantErr error
+	}{
+		{name: "no redirect", url: srv.URL + "/final", maxHops: 3, want: srv.URL + "/final"},
+		{name: "chain across servers", url: srv.URL + "/a", maxHops: 3, want: other.URL + "/final"},
+		{name: "loop", url: srv.URL + "/loop1", maxHops: 10, wantErr: ErrRedirectLoop},
+		{name: "redirect to itself", url: srv.URL + "/self", maxHops: 10, wantErr: ErrRedirectLoop},
+		{name: "limit is exact", url: srv.URL + "/long1", maxHops: 3, want: srv.URL + "/long4"},
+		{name: "too long", url: srv.URL + "/long1", maxHops: 2, wantErr: ErrRedirectChainTooLong},
+		{name: "unreachable hop is final", url: srv.URL + "/down", maxHops: 3, want: "http://127.0.0.1:1/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFollower(srv.Client(), tt.maxHops)
+			got, err := f.Resolve(context.Background(), tt.url, nil)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.True(t, IsChainError(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFollower_ResolveCheck(t *testing.T) {
+	srv := newRedirects(t, map[string]string{"/a": "/forbidden"})
+	errForbidden := errors.New("forbidden")
+	checked := make([]string, 0)
+	check := func(_ context.Context, rawURL string) error {
+		checked = append(checked, rawURL)
+		if rawURL == srv.URL+"/forbidden" {
+			return errForbidden
+		}
+		return nil
+	}
+
+	_, err := NewFollower(nil, 5).Resolve(context.Background(), srv.URL+"/a", check)
+	assert.ErrorIs(t, err, errForbidden)
+	assert.False(t, IsChainError(err))
+	// адрес проверяется до запроса к нему
+	assert.Equal(t, []string{srv.URL + "/a", srv.URL + "/forbidden"}, checked)
+}
+
+func TestNewClient(t *testing.T) {
+	srv := newRedirects(t, map[string]string{"/a": "/b"})
+	client := NewClient(time.Second)
+
+	_, err := client.Get(srv.URL + "/a")
+	assert.ErrorIs(t, err, policy.ErrPrivateAddress)
+	// запрос к внутреннему адресу не выполняется, поэтому он считается конечным
+	got, err := NewFollower(client, 3).Resolve(context.Background(), srv.URL+"/a", nil)
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/a", got)
+}
+
+func TestDialControl(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr bool
+	}{
+		{address: "93.184.216.34:443"},
+		{address: "[2606:2800:220:1:248:1893:25c8:1946]:80"},
+		{address: "127.0.0.1:80", wantErr: true},
+		{address: "10.1.2.3:80", wantErr: true},
+		{address: "192.168.0.1:80", wantErr: true},
+		{address: "169.254.169.254:80", wantErr: true},
+		{address: "0.0.0.0:80", wantErr: true},
+		{address: "224.0.0.1:80", wantErr: true},
+		{address: "[::1]:80", wantErr: true},
+		{address: "[::ffff:127.0.0.1]:80", wantErr: true},
+		{address: "[fe80::1]:80", wantErr: true},
+		{address: "[fd00::1]:80", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := dialControl("tcp", tt.address, nil)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, policy.ErrPrivateAddress)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package clicks
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+//go:embed bots.txt
+var defaultBotRules string
+
+// Classifier распознает переходы ботов: по правилам для User-Agent,
+// по HEAD запросам и по заголовкам предзагрузки страниц браузером.
+type Classifier struct {
+	rules []string
+}
+
+// NewClassifier создает Classifier по правилам из r.
+// Одно правило на строку, правило - подстрока User-Agent без учета регистра.
+// Пустые строки и строки, начинающиеся с #, игнорируются.
+func NewClassifier(r io.Reader) (*Classifier, error) {
+	c := &Classifier{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		rule := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if rule == "" || strings.HasPrefix(rule, "#") {
+			continue
+		}
+		c.rules = append(c.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// DefaultClassifier возвращает Classifier со встроенным набором правил.
+func DefaultClassifier() *Classifier {
+	c, err := NewClassifier(strings.NewReader(defaultBotRules))
+	if err != nil {
+		// встроенные правила читаются из строки, ошибки тут быть не может
+		panic(err)
+	}
+	return c
+}
+
+// LoadClassifier создает Classifier по правилам из указанного файла.
+func LoadClassifier(filename string) (*Classifier, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return NewClassifier(f)
+}
+
+// IsBot возвращает true, если переход совершен не человеком.
+func (c *Classifier) IsBot(e Event) bool {
+	if e.Method == http.MethodHead {
+		return true
+	}
+	purpose := strings.ToLower(e.Purpose)
+	if strings.Contains(purpose, "prefetch") || strings.Contains(purpose, "preview") {
+		return true
+	}
+
+	ua := strings.ToLower(strings.TrimSpace(e.UserAgent))
+	if ua == "" {
+		return true
+	}
+	for _, rule := range c.rules {
+		if strings.Contains(ua, rule) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package clicks
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifier_IsBot(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		want  bool
+	}{
+		{
+			name:  "browser",
+			event: Event{Method: http.MethodGet, UserAgent: browserUA},
+			want:  false,
+		},
+		{
+			name:  "crawler",
+			event: Event{Method: http.MethodGet, UserAgent: "Mozilla/5.0 (compatible; YandexBot/3.0; +http://yandex.com/bots)"},
+			want:  true,
+		},
+		{
+			name:  "link preview",
+			event: Event{Method: http.MethodGet, UserAgent: "TelegramBot (like TwitterBot)"},
+			want:  true,
+		},
+		{
+			name:  "empty user agent",
+			event: Event{Method: http.MethodGet},
+			want:  true,
+		},
+		{
+			name:  "head request",
+			event: Event{Method: http.MethodHead, UserAgent: browserUA},
+			want:  true,
+		},
+		{
+			name:  "browser prefetch",
+			event: Event{Method: http.MethodGet, UserAgent: browserUA, Purpose: "prefetch;prerender"},
+			want:  true,
+		},
+	}
+	c := DefaultClassifier()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, c.IsBot(tt.event))
+		})
+	}
+}
+
+func TestNewClassifier(t *testing.T) {
+	c, err := NewClassifier(strings.NewReader("# comment\n\n  Firefox \n"))
+	require.NoError(t, err)
+	assert.True(t, c.IsBot(Event{UserAgent: browserUA}))
+	assert.False(t, c.IsBot(Event{UserAgent: "curl/7.81.0"}))
+}
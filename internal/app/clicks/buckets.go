@@ -0,0 +1,294 @@
+package clicks
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var ErrUnknownGranularity = errors.New("unknown granularity, hour or day is expected")
+
+// Granularity - размер временного интервала, по которому агрегируются переходы.
+type Granularity string
+
+const (
+	Hour Granularity = "hour"
+	Day  Granularity = "day"
+)
+
+// ParseGranularity возвращает Granularity по ее строковому представлению.
+func ParseGranularity(s string) (Granularity, error) {
+	switch g := Granularity(s); g {
+	case Hour, Day:
+		return g, nil
+	}
+	return "", ErrUnknownGranularity
+}
+
+// Truncate возвращает начало интервала (в UTC), в который попадает t.
+func (g Granularity) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+	if g == Day {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(time.Hour)
+}
+
+// Next возвращает начало интервала, следующего за интервалом, начинающимся в start.
+func (g Granularity) Next(start time.Time) time.Time {
+	if g == Day {
+		return start.AddDate(0, 0, 1)
+	}
+	return start.Add(time.Hour)
+}
+
+// Step возвращает длительность интервала.
+func (g Granularity) Step() time.Duration {
+	if g == Day {
+		return 24 * time.Hour
+	}
+	return time.Hour
+}
+
+// Bucket - количество переходов за интервал, начинающийся в Start.
+type Bucket struct {
+	Start  time.Time `json:"start"`
+	Clicks int64     `json:"clicks"`
+}
+
+// Total - количество переходов по ссылке ID.
+type Total struct {
+	ID     string `json:"id"`
+	Clicks int64  `json:"clicks"`
+}
+
+// Fill возвращает непрерывный ряд интервалов от from до to (не включая to),
+// дополняя нулями интервалы, по которым переходов не было.
+func Fill(buckets []Bucket, g Granularity, from, to time.Time) []Bucket {
+	clicks := make(map[int64]int64, len(buckets))
+	for _, b := range buckets {
+		clicks[b.Start.Unix()] += b.Clicks
+	}
+
+	series := make([]Bucket, 0)
+	for start := g.Truncate(from); start.Before(to); start = g.Next(start) {
+		series = append(series, Bucket{Start: start, Clicks: clicks[start.Unix()]})
+	}
+	return series
+}
+
+// counters - агрегаты переходов в памяти: id -> начало интервала (unix) -> количество переходов.
+// Используются хранилищами, у которых нет своего механизма агрегации.
+// Дневные агрегаты не чистятся вместе с устаревшими событиями, т.к. ради этого и собираются,
+// а почасовые удаляются вместе с ними, см. pruneHours, иначе их число росло бы без ограничений.
+// Переходы на варианты ссылок дополнительно считаются под ключом VariantKey.
+// Owners - владелец -> ссылки с переходами, по нему строится рейтинг ссылок владельца без перебора всех ссылок.
+type counters struct {
+	Hours  map[string]map[int64]int64 `json:"hours"`
+	Days   map[string]map[int64]int64 `json:"days"`
+	Owners map[string]map[string]bool `json:"owners,omitempty"`
+}
+
+func newCounters() *counters {
+	return &counters{
+		Hours:  make(map[string]map[int64]int64),
+		Days:   make(map[string]map[int64]int64),
+		Owners: make(map[string]map[string]bool),
+	}
+}
+
+func (c *counters) add(events []Event) {
+	inc := func(m map[string]map[int64]int64, id string, start time.Time) {
+		if _, ok := m[id]; !ok {
+			m[id] = make(map[int64]int64)
+		}
+		m[id][start.Unix()]++
+	}
+	for _, e := range events {
+		inc(c.Hours, e.ID, Hour.Truncate(e.Time))
+		inc(c.Days, e.ID, Day.Truncate(e.Time))
+		if e.Owner != "" {
+			if _, ok := c.Owners[e.Owner]; !ok {
+				c.Owners[e.Owner] = make(map[string]bool)
+			}
+			c.Owners[e.Owner][e.ID] = true
+		}
+		if e.Variant > 0 {
+			inc(c.Hours, VariantKey(e.ID, e.Variant), Hour.Truncate(e.Time))
+			inc(c.Days, VariantKey(e.ID, e.Variant), Day.Truncate(e.Time))
+		}
+	}
+}
+
+// merge добавляет к агрегатам агрегаты other.
+func (c *counters) merge(other *counters) {
+	add := func(dst, src map[string]map[int64]int64) {
+		for id, buckets := range src {
+			if _, ok := dst[id]; !ok {
+				dst[id] = make(map[int64]int64, len(buckets))
+			}
+			for start, cnt := range buckets {
+				dst[id][start] += cnt
+			}
+		}
+	}
+	add(c.Hours, other.Hours)
+	add(c.Days, other.Days)
+	for owner, ids := range other.Owners {
+		if _, ok := c.Owners[owner]; !ok {
+			c.Owners[owner] = make(map[string]bool, len(ids))
+		}
+		for id := range ids {
+			c.Owners[owner][id] = true
+		}
+	}
+}
+
+// pruneHours удаляет почасовые интервалы, закончившиеся раньше before.
+func (c *counters) pruneHours(before time.Time) {
+	limit := Hour.Truncate(before).Unix()
+	for id, buckets := range c.Hours {
+		for start := range buckets {
+			if start < limit {
+				delete(buckets, start)
+			}
+		}
+		if len(buckets) == 0 {
+			delete(c.Hours, id)
+		}
+	}
+}
+
+// series возвращает отсортированные по времени непустые интервалы ссылки id в промежутке [from, to).
+func (c *counters) series(id string, g Granularity, from, to time.Time) []Bucket {
+	m := c.Hours
+	if g == Day {
+		m = c.Days
+	}
+
+	buckets := make([]Bucket, 0)
+	for start, cnt := range m[id] {
+		t := time.Unix(start, 0).UTC()
+		if t.Before(g.Truncate(from)) || !t.Before(to) {
+			continue
+		}
+		buckets = append(buckets, Bucket{Start: t, Clicks: cnt})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Start.Before(buckets[j].Start)
+	})
+	return buckets
+}
+
+// totals возвращает количество переходов по каждой из ссылок ids в промежутке [from, to).
+// Нулевые from и to означают отсутствие соответствующей границы.
+// Дни, целиком попадающие в промежуток, считаются по дневным интервалам, остальные - по почасовым.
+// Поэтому после чистки почасовых интервалов точность теряется только на границах промежутка.
+func (c *counters) totals(ids []string, from, to time.Time) map[string]int64 {
+	// включен ли почасовой интервал, начинающийся в t
+	included := func(t time.Time) bool {
+		return (from.IsZero() || !t.Before(Hour.Truncate(from))) && (to.IsZero() || t.Before(to))
+	}
+	out := make(map[string]int64, len(ids))
+	for _, id := range ids {
+		var sum int64
+		whole := make(map[int64]bool)
+		for start, cnt := range c.Days[id] {
+			t := time.Unix(start, 0).UTC()
+			if included(t) && included(Day.Next(t).Add(-time.Hour)) {
+				whole[start] = true
+				sum += cnt
+			}
+		}
+		for start, cnt := range c.Hours[id] {
+			t := time.Unix(start, 0).UTC()
+			if whole[Day.Truncate(t).Unix()] || !included(t) {
+				continue
+			}
+			sum += cnt
+		}
+		out[id] = sum
+	}
+	return out
+}
+
+// top возвращает не больше n ссылок владельца owner с наибольшей суммой переходов по агрегатам cs
+// в промежутке [from, to). Перебираются только ссылки владельца, по которым были переходы.
+func top(owner string, n int, from, to time.Time, cs ...*counters) []Total {
+	ids := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, c := range cs {
+		for id := range c.Owners[owner] {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	totals := make(map[string]int64, len(ids))
+	for _, c := range cs {
+		totals = mergeTotals(totals, c.totals(ids, from, to))
+	}
+
+	out := make([]Total, 0, len(totals))
+	for id, cnt := range totals {
+		if cnt > 0 {
+			out = append(out, Total{ID: id, Clicks: cnt})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Clicks != out[j].Clicks {
+			return out[i].Clicks > out[j].Clicks
+		}
+		return out[i].ID < out[j].ID
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// splitBots разделяет события на переходы людей и ботов.
+func splitBots(events []Event) (humans, bots []Event) {
+	for _, e := range events {
+		if e.Bot {
+			bots = append(bots, e)
+		} else {
+			humans = append(humans, e)
+		}
+	}
+	return humans, bots
+}
+
+// mergeSeries складывает два отсортированных по времени ряда интервалов.
+func mergeSeries(a, b []Bucket) []Bucket {
+	out := make([]Bucket, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case j == len(b) || (i < len(a) && a[i].Start.Before(b[j].Start)):
+			out = append(out, a[i])
+			i++
+		case i == len(a) || b[j].Start.Before(a[i].Start):
+			out = append(out, b[j])
+			j++
+		default:
+			out = append(out, Bucket{Start: a[i].Start, Clicks: a[i].Clicks + b[j].Clicks})
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// mergeTotals складывает количество переходов по ссылкам.
+func mergeTotals(a, b map[string]int64) map[string]int64 {
+	out := make(map[string]int64, len(a))
+	for id, cnt := range a {
+		out[id] = cnt
+	}
+	for id, cnt := range b {
+		out[id] += cnt
+	}
+	return out
+}
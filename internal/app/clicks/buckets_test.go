@@ -0,0 +1,118 @@
+package clicks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounters_Series(t *testing.T) {
+	day := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	c := newCounters()
+	c.add([]Event{
+		{ID: "1111", Time: day.Add(10*time.Hour + 5*time.Minute)},
+		{ID: "1111", Time: day.Add(10*time.Hour + 55*time.Minute)},
+		{ID: "1111", Time: day.Add(30 * time.Hour)},
+		{ID: "2222", Time: day.Add(10 * time.Hour)},
+	})
+
+	tests := []struct {
+		name string
+		g    Granularity
+		from time.Time
+		to   time.Time
+		want []Bucket
+	}{
+		{
+			name: "hours",
+			g:    Hour,
+			from: day,
+			to:   day.AddDate(0, 0, 2),
+			want: []Bucket{
+				{Start: day.Add(10 * time.Hour), Clicks: 2},
+				{Start: day.Add(30 * time.Hour), Clicks: 1},
+			},
+		},
+		{
+			name: "days",
+			g:    Day,
+			from: day.Add(time.Hour),
+			to:   day.AddDate(0, 0, 2),
+			want: []Bucket{
+				{Start: day, Clicks: 2},
+				{Start: day.AddDate(0, 0, 1), Clicks: 1},
+			},
+		},
+		{
+			name: "days out of range",
+			g:    Day,
+			from: day.AddDate(0, 0, 1),
+			to:   day.AddDate(0, 0, 2),
+			want: []Bucket{
+				{Start: day.AddDate(0, 0, 1), Clicks: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, c.series("1111", tt.g, tt.from, tt.to))
+		})
+	}
+}
+
+func TestCounters_Totals(t *testing.T) {
+	day := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	c := newCounters()
+	c.add([]Event{
+		{ID: "1111", Time: day.Add(10 * time.Hour)},
+		{ID: "1111", Time: day.Add(30 * time.Hour)},
+		{ID: "2222", Time: day.Add(10 * time.Hour)},
+	})
+
+	assert.Equal(t,
+		map[string]int64{"1111": 2, "2222": 1, "3333": 0},
+		c.totals([]string{"1111", "2222", "3333"}, time.Time{}, time.Time{}))
+	assert.Equal(t,
+		map[string]int64{"1111": 1, "2222": 0},
+		c.totals([]string{"1111", "2222"}, day.AddDate(0, 0, 1), time.Time{}))
+	assert.Equal(t,
+		map[string]int64{"1111": 1, "2222": 1},
+		c.totals([]string{"1111", "2222"}, time.Time{}, day.AddDate(0, 0, 1)))
+	assert.Equal(t,
+		map[string]int64{"1111": 1, "2222": 0},
+		c.totals([]string{"1111", "2222"}, day.Add(11*time.Hour), day.Add(31*time.Hour)))
+
+	// после чистки почасовых интервалов целые дни по-прежнему считаются
+	c.pruneHours(day.AddDate(0, 0, 2))
+	assert.Empty(t, c.Hours)
+	assert.Equal(t,
+		map[string]int64{"1111": 2, "2222": 1},
+		c.totals([]string{"1111", "2222"}, day, day.AddDate(0, 0, 2)))
+}
+
+func TestCounters_Variants(t *testing.T) {
+	day := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	c := newCounters()
+	c.add([]Event{
+		{ID: "1111", Time: day, Variant: 1},
+		{ID: "1111", Time: day, Variant: 2},
+		{ID: "1111", Time: day, Variant: 2},
+		{ID: "2222", Time: day},
+	})
+
+	// переходы на варианты учитываются и в переходах по самой ссылке
+	assert.Equal(t,
+		map[string]int64{"1111": 3, "1111#1": 1, "1111#2": 2, "2222": 1},
+		c.totals([]string{"1111", VariantKey("1111", 1), VariantKey("1111", 2), "2222"}, time.Time{}, time.Time{}))
+}
+
+func TestFill(t *testing.T) {
+	day := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	got := Fill([]Bucket{{Start: day.AddDate(0, 0, 1), Clicks: 5}}, Day, day.Add(time.Hour), day.AddDate(0, 0, 3))
+	assert.Equal(t, []Bucket{
+		{Start: day, Clicks: 0},
+		{Start: day.AddDate(0, 0, 1), Clicks: 5},
+		{Start: day.AddDate(0, 0, 2), Clicks: 0},
+	}, got)
+}
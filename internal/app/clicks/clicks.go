@@ -0,0 +1,59 @@
+// Package clicks реализует журнал переходов по коротким ссылкам.
+// События собираются в пакеты и асинхронно, вне обработки запроса, сбрасываются в подключаемое хранилище (Sink).
+// При записи хранилище агрегирует переходы по часовым и дневным интервалам,
+// статистика отдается из агрегатов, а не подсчетом сырых событий.
+// Переходы ботов и краулеров хранятся отдельно и по умолчанию не попадают в статистику.
+package clicks
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Event - событие перехода по короткой ссылке.
+// Purpose - значение заголовка, которым браузер помечает предзагрузку (Sec-Purpose, Purpose, X-Purpose, X-Moz).
+// Variant - номер (с 1) варианта оригинальной ссылки, на который перенаправлен переход, 0 - у ссылки нет вариантов.
+// User - посетитель, Owner - пользователь, которому принадлежит ссылка, по нему строится рейтинг ссылок, см. Sink.Top.
+type Event struct {
+	Time      time.Time `json:"time"`
+	ID        string    `json:"id"`
+	Method    string    `json:"method,omitempty"`
+	Referer   string    `json:"referer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Purpose   string    `json:"purpose,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	User      string    `json:"user,omitempty"`
+	Owner     string    `json:"owner,omitempty"`
+	Bot       bool      `json:"bot,omitempty"`
+	Variant   int       `json:"variant,omitempty"`
+}
+
+// VariantKey возвращает ключ, под которым агрегируются переходы на вариант variant ссылки id.
+// Переходы по вариантам запрашиваются из Sink по этому ключу так же, как переходы по ссылкам.
+func VariantKey(id string, variant int) string {
+	return id + "#" + strconv.Itoa(variant)
+}
+
+// Sink описывает контракт хранилища событий переходов.
+type Sink interface {
+	// Write сохраняет пакет событий. Слайс после возврата может быть переиспользован,
+	// поэтому хранить ссылку на него нельзя.
+	Write(ctx context.Context, events []Event) error
+	// Purge удаляет события, произошедшие раньше указанного момента. Агрегаты при этом сохраняются.
+	Purge(ctx context.Context, before time.Time) error
+	// Series возвращает отсортированные по времени непустые интервалы переходов по ссылке id в промежутке [from, to).
+	// Переходы ботов учитываются, только если withBots == true.
+	Series(ctx context.Context, id string, g Granularity, from, to time.Time, withBots bool) ([]Bucket, error)
+	// Totals возвращает количество переходов по каждой из ссылок ids в промежутке [from, to).
+	// Нулевые from и to означают отсутствие соответствующей границы.
+	// Переходы ботов учитываются, только если withBots == true.
+	Totals(ctx context.Context, ids []string, from, to time.Time, withBots bool) (map[string]int64, error)
+	// Top возвращает не больше n ссылок владельца owner с наибольшим количеством переходов в промежутке [from, to),
+	// упорядоченных по убыванию переходов, а при равенстве - по id. Ссылки без переходов в рейтинг не попадают.
+	// Нулевые from и to означают отсутствие соответствующей границы.
+	// Переходы ботов учитываются, только если withBots == true.
+	Top(ctx context.Context, owner string, n int, from, to time.Time, withBots bool) ([]Total, error)
+	// Close завершает работу хранилища.
+	Close() error
+}
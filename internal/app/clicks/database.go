@@ -0,0 +1,360 @@
+package clicks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// Запросы параметризованы именами таблиц: %[1]s - таблица событий, %[2]s - таблица агрегатов.
+// Переходы людей и ботов хранятся в одинаковых по структуре, но разных таблицах.
+const (
+	createClicksStatement = `CREATE TABLE IF NOT EXISTS %[1]s
+							(
+							    id         BIGSERIAL   NOT NULL CONSTRAINT %[1]s_pk PRIMARY KEY,
+							    link_id    VARCHAR     NOT NULL,
+							    clicked_at TIMESTAMPTZ NOT NULL,
+							    referer    VARCHAR     NOT NULL DEFAULT '',
+							    user_agent VARCHAR     NOT NULL DEFAULT '',
+							    client_ip  VARCHAR     NOT NULL DEFAULT '',
+							    user_id    VARCHAR     NOT NULL DEFAULT ''
+							);
+							ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS method VARCHAR NOT NULL DEFAULT '';
+							ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS purpose VARCHAR NOT NULL DEFAULT '';
+							ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS variant INTEGER NOT NULL DEFAULT 0;
+							CREATE INDEX IF NOT EXISTS %[1]s_link_id ON %[1]s (link_id);
+							CREATE INDEX IF NOT EXISTS %[1]s_clicked_at ON %[1]s (clicked_at);
+							CREATE TABLE IF NOT EXISTS %[2]s
+							(
+							    link_id      VARCHAR     NOT NULL,
+							    granularity  VARCHAR     NOT NULL,
+							    bucket_start TIMESTAMPTZ NOT NULL,
+							    clicks       BIGINT      NOT NULL DEFAULT 0,
+							    CONSTRAINT %[2]s_pk PRIMARY KEY (link_id, granularity, bucket_start)
+							);
+							ALTER TABLE %[2]s ADD COLUMN IF NOT EXISTS owner_id VARCHAR NOT NULL DEFAULT '';
+							CREATE INDEX IF NOT EXISTS %[2]s_owner_id ON %[2]s (owner_id);`
+	// Если агрегатов еще нет, то строим их по уже накопленным событиям
+	backfillBucketsStatement = `INSERT INTO %[2]s (link_id, granularity, bucket_start, clicks)
+								SELECT link_id, g.granularity,
+								       date_trunc(g.granularity, clicked_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC',
+								       COUNT(1)
+								  FROM %[1]s, (VALUES ('hour'), ('day')) AS g (granularity)
+								 WHERE NOT EXISTS (SELECT 1 FROM %[2]s)
+								 GROUP BY 1, 2, 3`
+	insertClickStatement = `INSERT INTO %[1]s (link_id, clicked_at, method, referer, user_agent, purpose, client_ip, user_id, variant)
+							VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	// Владелец у интервала не затирается пустым: у переходов на варианты и у старых событий его нет
+	upsertBucketStatement = `INSERT INTO %[2]s (link_id, granularity, bucket_start, clicks, owner_id)
+							 VALUES ($1, $2, $3, $4, $5)
+							 ON CONFLICT (link_id, granularity, bucket_start)
+							 DO UPDATE SET clicks = %[2]s.clicks + EXCLUDED.clicks,
+							               owner_id = CASE WHEN EXCLUDED.owner_id = '' THEN %[2]s.owner_id
+							                               ELSE EXCLUDED.owner_id END`
+	purgeClicksStatement = `DELETE FROM %[1]s WHERE clicked_at < $1`
+	seriesQuery          = `SELECT bucket_start, clicks
+							  FROM %[2]s
+							 WHERE link_id = $1 AND granularity = $2 AND bucket_start >= $3 AND bucket_start < $4
+							 ORDER BY bucket_start`
+	totalsQuery = `SELECT link_id, SUM(clicks)
+					 FROM %[2]s
+					WHERE link_id = ANY($1) AND granularity = 'hour'
+					  AND ($2::TIMESTAMPTZ IS NULL OR bucket_start >= $2)
+					  AND ($3::TIMESTAMPTZ IS NULL OR bucket_start < $3)
+					GROUP BY link_id`
+	bucketsQuery = `SELECT owner_id, link_id, granularity, bucket_start, clicks FROM %[2]s`
+	// Параметризован источником интервалов: таблицей агрегатов людей или ее объединением с таблицей ботов
+	topQuery = `SELECT link_id, SUM(clicks)
+				  FROM (%s) AS b
+				 WHERE owner_id = $1 AND granularity = 'hour'
+				   AND ($2::TIMESTAMPTZ IS NULL OR bucket_start >= $2)
+				   AND ($3::TIMESTAMPTZ IS NULL OR bucket_start < $3)
+				 GROUP BY link_id
+				 ORDER BY 2 DESC, link_id
+				 LIMIT $4`
+)
+
+// clickTables - набор запросов к паре таблиц событий и агрегатов.
+type clickTables struct {
+	create   string
+	backfill string
+	insert   string
+	upsert   string
+	purge    string
+	series   string
+	totals   string
+	buckets  string
+}
+
+func newClickTables(events, buckets string) clickTables {
+	q := func(query string) string {
+		return fmt.Sprintf(query, events, buckets)
+	}
+	return clickTables{
+		create:   q(createClicksStatement),
+		backfill: q(backfillBucketsStatement),
+		insert:   q(insertClickStatement),
+		upsert:   q(upsertBucketStatement),
+		purge:    q(purgeClicksStatement),
+		series:   q(seriesQuery),
+		totals:   q(totalsQuery),
+		buckets:  q(bucketsQuery),
+	}
+}
+
+// DatabaseSink реализует хранение событий в таблицах PostgreSQL.
+// Переходы ботов пишутся в отдельные таблицы bot_click_events и bot_click_buckets.
+// Соединение с БД разделяется с хранилищем ссылок, поэтому DatabaseSink его не закрывает.
+type DatabaseSink struct {
+	database *sql.DB
+	humans   clickTables
+	bots     clickTables
+	// top и topWithBots - запросы рейтинга ссылок без учета и с учетом переходов ботов
+	top         string
+	topWithBots string
+}
+
+var _ Sink = (*DatabaseSink)(nil)
+
+// NewDatabaseSink создает и возвращает DatabaseSink, при необходимости создает таблицы событий.
+func NewDatabaseSink(db *sql.DB) (*DatabaseSink, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+
+	s := &DatabaseSink{
+		database: db,
+		humans:   newClickTables("click_events", "click_buckets"),
+		bots:     newClickTables("bot_click_events", "bot_click_buckets"),
+	}
+	s.top = fmt.Sprintf(topQuery, s.humans.buckets)
+	s.topWithBots = fmt.Sprintf(topQuery, s.humans.buckets+" UNION ALL "+s.bots.buckets)
+	for _, t := range []clickTables{s.humans, s.bots} {
+		if _, err := db.ExecContext(ctx, t.create); err != nil {
+			return nil, err
+		}
+		if _, err := db.ExecContext(ctx, t.backfill); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Write сохраняет пакет событий и обновляет агрегаты одной транзакцией.
+func (s *DatabaseSink) Write(ctx context.Context, events []Event) error {
+	// шаг 1 — объявляем транзакцию
+	tx, err := s.database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// шаг 1.1 — если возникает ошибка, откатываем изменения
+	defer func() {
+		if err = tx.Rollback(); err != nil {
+			log.Debug().Err(err).Msg("can't rollback transaction, this error will be omitted")
+		}
+	}()
+
+	// шаг 2 — раскладываем переходы людей и ботов по своим таблицам
+	humans, bots := splitBots(events)
+	if err = writeTx(ctx, tx, s.humans, humans); err != nil {
+		return err
+	}
+	if err = writeTx(ctx, tx, s.bots, bots); err != nil {
+		return err
+	}
+
+	// шаг 3 — сохраняем изменения
+	return tx.Commit()
+}
+
+// writeTx добавляет в транзакцию запись событий и обновление агрегатов в таблицы t.
+// Агрегаты по пакету предварительно считаются в памяти, чтобы обновлять каждый интервал один раз.
+func writeTx(ctx context.Context, tx *sql.Tx, t clickTables, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.PrepareContext(ctx, t.insert)
+	if err != nil {
+		return err
+	}
+	// не забываем закрыть инструкцию, когда она больше не нужна
+	defer func() {
+		if err = stmt.Close(); err != nil {
+			log.Err(err).Msg("can't close insert instruction, this error will be omitted")
+		}
+	}()
+
+	for _, e := range events {
+		_, err = stmt.ExecContext(ctx, e.ID, e.Time, e.Method, e.Referer, e.UserAgent, e.Purpose, e.IP, e.User, e.Variant)
+		if err != nil {
+			return err
+		}
+	}
+
+	upsert, err := tx.PrepareContext(ctx, t.upsert)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err = upsert.Close(); err != nil {
+			log.Err(err).Msg("can't close upsert instruction, this error will be omitted")
+		}
+	}()
+
+	c := newCounters()
+	c.add(events)
+	owners := make(map[string]string)
+	for owner, ids := range c.Owners {
+		for id := range ids {
+			owners[id] = owner
+		}
+	}
+	for g, m := range map[Granularity]map[string]map[int64]int64{Hour: c.Hours, Day: c.Days} {
+		for id, buckets := range m {
+			for start, cnt := range buckets {
+				_, err = upsert.ExecContext(ctx, id, string(g), time.Unix(start, 0).UTC(), cnt, owners[id])
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Purge удаляет события, произошедшие раньше указанного момента.
+func (s *DatabaseSink) Purge(ctx context.Context, before time.Time) error {
+	for _, t := range []clickTables{s.humans, s.bots} {
+		if _, err := s.database.ExecContext(ctx, t.purge, before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Series возвращает непустые интервалы переходов по ссылке id в промежутке [from, to).
+func (s *DatabaseSink) Series(ctx context.Context, id string, g Granularity, from, to time.Time, withBots bool) ([]Bucket, error) {
+	buckets, err := s.series(ctx, s.humans, id, g, from, to)
+	if err != nil || !withBots {
+		return buckets, err
+	}
+	bots, err := s.series(ctx, s.bots, id, g, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return mergeSeries(buckets, bots), nil
+}
+
+func (s *DatabaseSink) series(ctx context.Context, t clickTables, id string, g Granularity, from, to time.Time) ([]Bucket, error) {
+	rows, err := s.database.QueryContext(ctx, t.series, id, string(g), g.Truncate(from), to)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			log.Err(err).Send()
+		}
+	}()
+
+	buckets := make([]Bucket, 0)
+	for rows.Next() {
+		var b Bucket
+		if err = rows.Scan(&b.Start, &b.Clicks); err != nil {
+			return nil, err
+		}
+		b.Start = b.Start.UTC()
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
+
+// Totals возвращает количество переходов по каждой из ссылок ids в промежутке [from, to).
+func (s *DatabaseSink) Totals(ctx context.Context, ids []string, from, to time.Time, withBots bool) (map[string]int64, error) {
+	out, err := s.totals(ctx, s.humans, ids, from, to)
+	if err != nil || !withBots {
+		return out, err
+	}
+	bots, err := s.totals(ctx, s.bots, ids, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return mergeTotals(out, bots), nil
+}
+
+func (s *DatabaseSink) totals(ctx context.Context, t clickTables, ids []string, from, to time.Time) (map[string]int64, error) {
+	rows, err := s.database.QueryContext(ctx, t.totals, pq.Array(ids), nullTime(Hour.Truncate(from), from), nullTime(to, to))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			log.Err(err).Send()
+		}
+	}()
+
+	out := make(map[string]int64, len(ids))
+	for _, id := range ids {
+		out[id] = 0
+	}
+	for rows.Next() {
+		var (
+			id  string
+			cnt int64
+		)
+		if err = rows.Scan(&id, &cnt); err != nil {
+			return nil, err
+		}
+		out[id] = cnt
+	}
+	return out, rows.Err()
+}
+
+// Top возвращает не больше n ссылок владельца owner с наибольшим количеством переходов в промежутке [from, to).
+// Рейтинг считается в БД по почасовым агрегатам, в которых записан владелец ссылки.
+func (s *DatabaseSink) Top(ctx context.Context, owner string, n int, from, to time.Time, withBots bool) ([]Total, error) {
+	if owner == "" {
+		// пустой владелец записан у переходов на варианты и у переходов до появления владельцев
+		return []Total{}, nil
+	}
+	query := s.top
+	if withBots {
+		query = s.topWithBots
+	}
+	rows, err := s.database.QueryContext(ctx, query, owner, nullTime(Hour.Truncate(from), from), nullTime(to, to), n)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			log.Err(err).Send()
+		}
+	}()
+
+	out := make([]Total, 0, n)
+	for rows.Next() {
+		var t Total
+		if err = rows.Scan(&t.ID, &t.Clicks); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// nullTime возвращает NULL для БД, если граница check не задана.
+func nullTime(t time.Time, check time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !check.IsZero()}
+}
+
+// Close ничего не делает, соединение с БД закрывает хранилище ссылок.
+func (s *DatabaseSink) Close() error {
+	// Do nothing
+	return nil
+}
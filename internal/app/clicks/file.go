@@ -0,0 +1,329 @@
+package clicks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// FileSink реализует хранение событий в файле в формате JSON lines - одно событие на строку.
+// Переходы ботов пишутся отдельно, в файл с суффиксом .bots.
+// Агрегаты держатся в памяти, а рядом с журналом в файл с суффиксом .buckets после каждой записи дописываются
+// их приращения. При чистке и после compactEvery приращений файл агрегатов переписывается целиком.
+// Если файла агрегатов нет, то они пересчитываются по журналу при открытии.
+type FileSink struct {
+	humans *journal
+	bots   *journal
+	mx     sync.RWMutex
+}
+
+var _ Sink = (*FileSink)(nil)
+
+// NewFileSink создает и возвращает FileSink, события дописываются в конец указанного файла.
+func NewFileSink(filename string) (s *FileSink, err error) {
+	if err = utils.CheckFilename(filename); err != nil {
+		return nil, err
+	}
+	s = &FileSink{}
+	if s.humans, err = openJournal(filename); err != nil {
+		return nil, err
+	}
+	if s.bots, err = openJournal(filename + ".bots"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write дописывает события в файл и обновляет агрегаты.
+func (s *FileSink) Write(_ context.Context, events []Event) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	humans, bots := splitBots(events)
+	if err := s.humans.write(humans); err != nil {
+		return err
+	}
+	return s.bots.write(bots)
+}
+
+// Series возвращает непустые интервалы переходов по ссылке id в промежутке [from, to).
+func (s *FileSink) Series(_ context.Context, id string, g Granularity, from, to time.Time, withBots bool) ([]Bucket, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	if !withBots {
+		return s.humans.counters.series(id, g, from, to), nil
+	}
+	return mergeSeries(s.humans.counters.series(id, g, from, to), s.bots.counters.series(id, g, from, to)), nil
+}
+
+// Totals возвращает количество переходов по каждой из ссылок ids в промежутке [from, to).
+func (s *FileSink) Totals(_ context.Context, ids []string, from, to time.Time, withBots bool) (map[string]int64, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	if !withBots {
+		return s.humans.counters.totals(ids, from, to), nil
+	}
+	return mergeTotals(s.humans.counters.totals(ids, from, to), s.bots.counters.totals(ids, from, to)), nil
+}
+
+// Top возвращает не больше n ссылок владельца owner с наибольшим количеством переходов в промежутке [from, to).
+func (s *FileSink) Top(_ context.Context, owner string, n int, from, to time.Time, withBots bool) ([]Total, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	if !withBots {
+		return top(owner, n, from, to, s.humans.counters), nil
+	}
+	return top(owner, n, from, to, s.humans.counters, s.bots.counters), nil
+}
+
+// Purge переписывает файлы, оставляя в них только события, произошедшие не раньше указанного момента,
+// и удаляет почасовые агрегаты того же времени.
+func (s *FileSink) Purge(_ context.Context, before time.Time) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if err := s.humans.purge(before); err != nil {
+		return err
+	}
+	return s.bots.purge(before)
+}
+
+// Close закрывает файлы журнала.
+func (s *FileSink) Close() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	err1 := s.humans.close()
+	err2 := s.bots.close()
+	if err1 != nil {
+		return err1
+	}
+	return err2
+}
+
+// compactEvery - через сколько дописанных приращений файл агрегатов переписывается целиком.
+const compactEvery = 1000
+
+// journal - файл событий вместе с его агрегатами.
+type journal struct {
+	counters *counters
+	filename string
+	file     *os.File
+	encoder  *json.Encoder
+	// deltas - файл агрегатов и количество дописанных в него приращений
+	deltas      *os.File
+	deltasCount int
+}
+
+func openJournal(filename string) (*journal, error) {
+	j := &journal{filename: filename}
+	if err := j.loadCounters(); err != nil {
+		return nil, err
+	}
+	if err := j.open(); err != nil {
+		return nil, err
+	}
+	if err := j.openDeltas(); err != nil {
+		_ = j.file.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *journal) open() (err error) {
+	j.file, err = os.OpenFile(j.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	j.encoder = json.NewEncoder(j.file)
+	return nil
+}
+
+func (j *journal) openDeltas() (err error) {
+	j.deltas, err = os.OpenFile(j.countersFilename(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	return err
+}
+
+func (j *journal) countersFilename() string {
+	return j.filename + ".buckets"
+}
+
+// loadCounters складывает сохраненные агрегаты и их приращения, а при их отсутствии пересчитывает агрегаты по журналу.
+// Недописанное при сбое последнее приращение отбрасывается.
+func (j *journal) loadCounters() error {
+	j.counters = newCounters()
+
+	cf, err := os.Open(j.countersFilename())
+	if err == nil {
+		defer cf.Close()
+		dec := json.NewDecoder(cf)
+		for {
+			delta := newCounters()
+			if err = dec.Decode(delta); err != nil {
+				break
+			}
+			j.counters.merge(delta)
+			j.deltasCount++
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		log.Warn().Err(err).Msgf("broken counters in %s are skipped", j.countersFilename())
+		// следующее приращение не должно попасть в конец поврежденной записи
+		return j.saveCounters()
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	f, err := os.Open(j.filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		j.counters.add([]Event{e})
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	// пересчитанные агрегаты сохраняются сразу, иначе при следующем открытии останутся одни приращения
+	return j.saveCounters()
+}
+
+// saveCounters атомарно переписывает файл агрегатов, сворачивая приращения в одну запись.
+func (j *journal) saveCounters() error {
+	b, err := json.Marshal(j.counters)
+	if err != nil {
+		return err
+	}
+	tmpName := j.countersFilename() + ".tmp"
+	if err = os.WriteFile(tmpName, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpName, j.countersFilename()); err != nil {
+		return err
+	}
+	j.deltasCount = 0
+	if j.deltas == nil {
+		return nil
+	}
+	// открытый файл указывает на замененный, запись нужно продолжать в новый
+	if err = j.deltas.Close(); err != nil {
+		return err
+	}
+	return j.openDeltas()
+}
+
+// appendCounters дописывает приращение агрегатов в файл агрегатов.
+func (j *journal) appendCounters(delta *counters) error {
+	if j.deltasCount >= compactEvery {
+		return j.saveCounters()
+	}
+	b, err := json.Marshal(delta)
+	if err != nil {
+		return err
+	}
+	if _, err = j.deltas.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	j.deltasCount++
+	return nil
+}
+
+func (j *journal) write(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	for i := range events {
+		if err := j.encoder.Encode(&events[i]); err != nil {
+			return err
+		}
+	}
+	delta := newCounters()
+	delta.add(events)
+	j.counters.merge(delta)
+	return j.appendCounters(delta)
+}
+
+// purge переписывает файл, оставляя в нем только события, произошедшие не раньше указанного момента.
+// Новый файл сначала пишется рядом и потом подменяет старый, чтобы при сбое не потерять журнал.
+func (j *journal) purge(before time.Time) error {
+	src, err := os.Open(j.filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmpName := j.filename + ".tmp"
+	dst, err := os.Create(tmpName)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(dst)
+	enc := json.NewEncoder(w)
+	scanner := bufio.NewScanner(src)
+	for scanner.Scan() {
+		var e Event
+		if json.Unmarshal(scanner.Bytes(), &e) != nil || e.Time.Before(before) {
+			continue
+		}
+		if err = enc.Encode(&e); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = scanner.Err()
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+
+	if err = j.file.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpName, j.filename); err != nil {
+		return err
+	}
+	if err = j.open(); err != nil {
+		return err
+	}
+	j.counters.pruneHours(before)
+	return j.saveCounters()
+}
+
+func (j *journal) close() error {
+	err := j.file.Close()
+	if derr := j.deltas.Close(); err == nil {
+		err = derr
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+package clicks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readIDs(t *testing.T, filename string) []string {
+	f, err := os.Open(filename)
+	require.NoError(t, err)
+	defer f.Close()
+
+	ids := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		ids = append(ids, e.ID)
+	}
+	require.NoError(t, scanner.Err())
+	return ids
+}
+
+func readLines(t *testing.T, filename string) []string {
+	b, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+func TestFileSink_WriteAndPurge(t *testing.T) {
+	now := time.Now()
+	filename := filepath.Join(t.TempDir(), "clicks.jsonl")
+	s, err := NewFileSink(filename)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, s.Write(ctx, []Event{
+		{ID: "1111", Time: now.Add(-2 * time.Hour), UserAgent: "curl/7.79.1"},
+		{ID: "2222", Time: now, Referer: "https://ya.ru"},
+	}))
+	assert.Equal(t, []string{"1111", "2222"}, readIDs(t, filename))
+
+	require.NoError(t, s.Purge(ctx, now.Add(-time.Hour)))
+	assert.Equal(t, []string{"2222"}, readIDs(t, filename))
+
+	// после чистки запись продолжается в новый файл
+	require.NoError(t, s.Write(ctx, []Event{{ID: "3333", Time: now}}))
+	require.NoError(t, s.Close())
+	assert.Equal(t, []string{"2222", "3333"}, readIDs(t, filename))
+}
+
+func TestFileSink_Counters(t *testing.T) {
+	now := time.Now().UTC()
+	filename := filepath.Join(t.TempDir(), "clicks.jsonl")
+	s, err := NewFileSink(filename)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, s.Write(ctx, []Event{{ID: "1111", Owner: "xxxx", Time: now}, {ID: "1111", Owner: "xxxx", Time: now}}))
+	require.NoError(t, s.Close())
+
+	// агрегаты вместе с владельцами ссылок переживают перезапуск и чистку журнала
+	s, err = NewFileSink(filename)
+	require.NoError(t, err)
+	require.NoError(t, s.Purge(ctx, now.Add(time.Hour)))
+	totals, err := s.Totals(ctx, []string{"1111"}, time.Time{}, time.Time{}, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"1111": 2}, totals)
+	top, err := s.Top(ctx, "xxxx", 10, time.Time{}, time.Time{}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []Total{{ID: "1111", Clicks: 2}}, top)
+	require.NoError(t, s.Close())
+
+	// без файла агрегатов они пересчитываются по журналу
+	require.NoError(t, os.Remove(filename+".buckets"))
+	require.NoError(t, os.WriteFile(filename, []byte(`{"time":"2022-08-01T10:00:00Z","id":"2222"}`+"\n"), 0644))
+	s, err = NewFileSink(filename)
+	require.NoError(t, err)
+	series, err := s.Series(ctx, "2222", Day, time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 8, 2, 0, 0, 0, 0, time.UTC), false)
+	require.NoError(t, err)
+	assert.Equal(t, []Bucket{{Start: time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC), Clicks: 1}}, series)
+	require.NoError(t, s.Close())
+}
+
+func TestFileSink_CountersFile(t *testing.T) {
+	day := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	filename := filepath.Join(t.TempDir(), "clicks.jsonl")
+	s, err := NewFileSink(filename)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Write(ctx, []Event{{ID: "1111", Time: day.Add(time.Duration(i) * time.Hour)}}))
+	}
+	// приращения дописываются в конец файла агрегатов
+	assert.Len(t, readLines(t, filename+".buckets"), 3)
+
+	// при чистке файл агрегатов сворачивается, а почасовые интервалы старше срока хранения удаляются
+	require.NoError(t, s.Purge(ctx, day.Add(2*time.Hour)))
+	assert.Len(t, readLines(t, filename+".buckets"), 1)
+	series, err := s.Series(ctx, "1111", Hour, day, day.Add(24*time.Hour), false)
+	require.NoError(t, err)
+	assert.Equal(t, []Bucket{{Start: day.Add(2 * time.Hour), Clicks: 1}}, series)
+	require.NoError(t, s.Close())
+
+	// недописанное приращение отбрасывается, целые дни считаются по дневным интервалам
+	f, err := os.OpenFile(filename+".buckets", os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"hours":{"1111":`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	s, err = NewFileSink(filename)
+	require.NoError(t, err)
+	totals, err := s.Totals(ctx, []string{"1111"}, day, day.Add(24*time.Hour), false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"1111": 3}, totals)
+	totals, err = s.Totals(ctx, []string{"1111"}, day.Add(time.Hour), day.Add(24*time.Hour), false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"1111": 1}, totals)
+	require.NoError(t, s.Write(ctx, []Event{{ID: "1111", Time: day}}))
+	require.NoError(t, s.Close())
+	assert.Len(t, readLines(t, filename+".buckets"), 2)
+}
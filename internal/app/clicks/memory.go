@@ -0,0 +1,152 @@
+package clicks
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RingSink реализует хранение событий в памяти в кольцевом буфере фиксированного размера.
+// При переполнении самые старые события затираются новыми, агрегаты при этом не теряются.
+// Переходы ботов хранятся в отдельном буфере той же емкости.
+type RingSink struct {
+	humans *ring
+	bots   *ring
+	mx     sync.RWMutex
+}
+
+var _ Sink = (*RingSink)(nil)
+
+// NewRingSink создает и возвращает RingSink указанной емкости.
+func NewRingSink(size int) *RingSink {
+	if size <= 0 {
+		size = 1
+	}
+	return &RingSink{humans: newRing(size), bots: newRing(size)}
+}
+
+// Write дописывает события в буфер и обновляет агрегаты.
+func (s *RingSink) Write(_ context.Context, events []Event) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	humans, bots := splitBots(events)
+	s.humans.write(humans)
+	s.bots.write(bots)
+	return nil
+}
+
+// Purge удаляет из буферов события и почасовые агрегаты, относящиеся ко времени раньше указанного момента.
+func (s *RingSink) Purge(_ context.Context, before time.Time) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.humans.purge(before)
+	s.bots.purge(before)
+	return nil
+}
+
+// Series возвращает непустые интервалы переходов по ссылке id в промежутке [from, to).
+func (s *RingSink) Series(_ context.Context, id string, g Granularity, from, to time.Time, withBots bool) ([]Bucket, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	if !withBots {
+		return s.humans.counters.series(id, g, from, to), nil
+	}
+	return mergeSeries(s.humans.counters.series(id, g, from, to), s.bots.counters.series(id, g, from, to)), nil
+}
+
+// Totals возвращает количество переходов по каждой из ссылок ids в промежутке [from, to).
+func (s *RingSink) Totals(_ context.Context, ids []string, from, to time.Time, withBots bool) (map[string]int64, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	if !withBots {
+		return s.humans.counters.totals(ids, from, to), nil
+	}
+	return mergeTotals(s.humans.counters.totals(ids, from, to), s.bots.counters.totals(ids, from, to)), nil
+}
+
+// Top возвращает не больше n ссылок владельца owner с наибольшим количеством переходов в промежутке [from, to).
+func (s *RingSink) Top(_ context.Context, owner string, n int, from, to time.Time, withBots bool) ([]Total, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	if !withBots {
+		return top(owner, n, from, to, s.humans.counters), nil
+	}
+	return top(owner, n, from, to, s.humans.counters, s.bots.counters), nil
+}
+
+// Events возвращает копию хранимых переходов людей в порядке их записи.
+func (s *RingSink) Events() []Event {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	return s.humans.ordered()
+}
+
+// BotEvents возвращает копию хранимых переходов ботов в порядке их записи.
+func (s *RingSink) BotEvents() []Event {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	return s.bots.ordered()
+}
+
+// Close ничего не делает, требуется только для совместимости с контрактом
+func (s *RingSink) Close() error {
+	// Do nothing
+	return nil
+}
+
+// ring - кольцевой буфер событий вместе с их агрегатами.
+type ring struct {
+	counters *counters
+	events   []Event
+	next     int
+	full     bool
+}
+
+func newRing(size int) *ring {
+	return &ring{events: make([]Event, size), counters: newCounters()}
+}
+
+func (r *ring) write(events []Event) {
+	r.counters.add(events)
+	for _, e := range events {
+		r.events[r.next] = e
+		r.next = (r.next + 1) % len(r.events)
+		if r.next == 0 {
+			r.full = true
+		}
+	}
+}
+
+func (r *ring) purge(before time.Time) {
+	r.counters.pruneHours(before)
+	kept := make([]Event, 0, len(r.events))
+	for _, e := range r.ordered() {
+		if !e.Time.Before(before) {
+			kept = append(kept, e)
+		}
+	}
+
+	buf := make([]Event, len(r.events))
+	copy(buf, kept)
+	r.events = buf
+	r.next = len(kept) % len(buf)
+	r.full = len(kept) == len(buf)
+}
+
+func (r *ring) ordered() []Event {
+	if !r.full {
+		out := make([]Event, r.next)
+		copy(out, r.events[:r.next])
+		return out
+	}
+	out := make([]Event, 0, len(r.events))
+	out = append(out, r.events[r.next:]...)
+	return append(out, r.events[:r.next]...)
+}
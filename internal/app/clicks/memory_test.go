@@ -0,0 +1,105 @@
+package clicks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRingSink_Write(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		written []string
+		want    []string
+	}{
+		{
+			name:    "not full buffer",
+			size:    3,
+			written: []string{"1111", "2222"},
+			want:    []string{"1111", "2222"},
+		},
+		{
+			name:    "exactly full buffer",
+			size:    3,
+			written: []string{"1111", "2222", "3333"},
+			want:    []string{"1111", "2222", "3333"},
+		},
+		{
+			name:    "overwritten buffer",
+			size:    3,
+			written: []string{"1111", "2222", "3333", "4444", "5555"},
+			want:    []string{"3333", "4444", "5555"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewRingSink(tt.size)
+			events := make([]Event, 0, len(tt.written))
+			for _, id := range tt.written {
+				events = append(events, Event{ID: id})
+			}
+			require.NoError(t, s.Write(context.Background(), events))
+
+			got := make([]string, 0)
+			for _, e := range s.Events() {
+				got = append(got, e.ID)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRingSink_Purge(t *testing.T) {
+	now := time.Now()
+	s := NewRingSink(3)
+	err := s.Write(context.Background(), []Event{
+		{ID: "1111", Time: now.Add(-3 * time.Hour)},
+		{ID: "2222", Time: now.Add(-2 * time.Hour)},
+		{ID: "3333", Time: now.Add(-time.Hour)},
+		{ID: "4444", Time: now},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Purge(context.Background(), now.Add(-90*time.Minute)))
+	assert.Equal(t, []Event{{ID: "3333", Time: now.Add(-time.Hour)}, {ID: "4444", Time: now}}, s.Events())
+
+	// после чистки буфер продолжает работать как кольцо
+	require.NoError(t, s.Write(context.Background(), []Event{{ID: "5555"}, {ID: "6666"}}))
+	ids := make([]string, 0)
+	for _, e := range s.Events() {
+		ids = append(ids, e.ID)
+	}
+	assert.Equal(t, []string{"4444", "5555", "6666"}, ids)
+}
+
+func TestRingSink_Top(t *testing.T) {
+	day := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	s := NewRingSink(10)
+	ctx := context.Background()
+	require.NoError(t, s.Write(ctx, []Event{
+		{ID: "1111", Owner: "xxxx", Time: day},
+		{ID: "2222", Owner: "xxxx", Time: day},
+		{ID: "2222", Owner: "xxxx", Time: day.Add(time.Hour), Variant: 1},
+		{ID: "3333", Owner: "xxxx", Time: day.Add(48 * time.Hour)},
+		{ID: "4444", Owner: "yyyy", Time: day},
+		{ID: "1111", Owner: "xxxx", Time: day, Bot: true},
+		{ID: "1111", Owner: "xxxx", Time: day, Bot: true},
+	}))
+
+	got, err := s.Top(ctx, "xxxx", 10, time.Time{}, time.Time{}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []Total{{ID: "2222", Clicks: 2}, {ID: "1111", Clicks: 1}, {ID: "3333", Clicks: 1}}, got)
+
+	// ссылки без переходов в промежутке в рейтинг не попадают
+	got, err = s.Top(ctx, "xxxx", 1, day, day.Add(24*time.Hour), true)
+	require.NoError(t, err)
+	assert.Equal(t, []Total{{ID: "1111", Clicks: 3}}, got)
+
+	got, err = s.Top(ctx, "zzzz", 10, time.Time{}, time.Time{}, true)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
@@ -0,0 +1,153 @@
+package clicks
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	batchSize     = 100
+	queueSize     = 1024
+	flushInterval = time.Second
+	purgeInterval = time.Hour
+)
+
+// Recorder собирает события переходов в пакеты и сбрасывает их в Sink в отдельной горутине.
+// Пакет сбрасывается при заполнении или по таймеру, чтобы хвосты неполных пакетов не застревали.
+// Если задан срок хранения, то устаревшие события регулярно удаляются из Sink.
+type Recorder struct {
+	sink       Sink
+	classifier *Classifier
+	events     chan Event
+	done       chan struct{}
+	stopped    chan struct{}
+	retention  time.Duration
+}
+
+// NewRecorder создает Recorder и запускает его consumer.
+// retention - срок хранения событий, 0 - хранить бессрочно.
+// classifier - правила распознавания ботов, если nil - используются правила по умолчанию.
+func NewRecorder(sink Sink, retention time.Duration, classifier *Classifier) *Recorder {
+	if classifier == nil {
+		classifier = DefaultClassifier()
+	}
+	r := &Recorder{
+		sink:       sink,
+		classifier: classifier,
+		events:     make(chan Event, queueSize),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+		retention:  retention,
+	}
+	go r.consume()
+	return r
+}
+
+// Record помечает переходы ботов и ставит событие в очередь на запись, не блокируя обработку запроса.
+// Если очередь переполнена, то событие отбрасывается.
+func (r *Recorder) Record(e Event) {
+	e.Bot = r.classifier.IsBot(e)
+	select {
+	case r.events <- e:
+	default:
+		log.Warn().Str("id", e.ID).Msg("click queue is full, event is dropped")
+	}
+}
+
+func (r *Recorder) consume() {
+	defer close(r.stopped)
+
+	flush := time.NewTicker(flushInterval)
+	defer flush.Stop()
+	purge := time.NewTicker(purgeInterval)
+	defer purge.Stop()
+
+	buf := make([]Event, 0, batchSize)
+	write := func() {
+		if len(buf) == 0 {
+			return
+		}
+		r.write(buf)
+		buf = buf[:0]
+	}
+
+	r.purge()
+	for {
+		select {
+		case e := <-r.events:
+			buf = append(buf, e)
+			if len(buf) == batchSize {
+				write()
+			}
+		case <-flush.C:
+			write()
+		case <-purge.C:
+			r.purge()
+		case <-r.done:
+			// дописываем все, что успело попасть в очередь
+			for {
+				select {
+				case e := <-r.events:
+					buf = append(buf, e)
+					if len(buf) == batchSize {
+						write()
+					}
+				default:
+					write()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *Recorder) write(events []Event) {
+	// Это чтобы мы тут тоже не зависли надолго
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+
+	if err := r.sink.Write(ctx, events); err != nil {
+		log.Err(err).Msgf("can't write %d click events", len(events))
+	}
+}
+
+func (r *Recorder) purge() {
+	if r.retention <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+
+	if err := r.sink.Purge(ctx, time.Now().Add(-r.retention)); err != nil {
+		log.Err(err).Msg("can't purge outdated click events")
+	}
+}
+
+// Series возвращает непрерывный ряд переходов по ссылке id в промежутке [from, to),
+// интервалы без переходов дополняются нулями.
+func (r *Recorder) Series(ctx context.Context, id string, g Granularity, from, to time.Time, withBots bool) ([]Bucket, error) {
+	buckets, err := r.sink.Series(ctx, id, g, from, to, withBots)
+	if err != nil {
+		return nil, err
+	}
+	return Fill(buckets, g, from, to), nil
+}
+
+// Totals возвращает количество переходов по каждой из ссылок ids в промежутке [from, to).
+func (r *Recorder) Totals(ctx context.Context, ids []string, from, to time.Time, withBots bool) (map[string]int64, error) {
+	return r.sink.Totals(ctx, ids, from, to, withBots)
+}
+
+// Top возвращает не больше n ссылок владельца owner с наибольшим количеством переходов в промежутке [from, to).
+func (r *Recorder) Top(ctx context.Context, owner string, n int, from, to time.Time, withBots bool) ([]Total, error) {
+	return r.sink.Top(ctx, owner, n, from, to, withBots)
+}
+
+// Close сбрасывает в Sink накопленные события и закрывает его.
+func (r *Recorder) Close() error {
+	close(r.done)
+	<-r.stopped
+	return r.sink.Close()
+}
@@ -0,0 +1,69 @@
+package clicks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const browserUA = "Mozilla/5.0 (X11; Linux x86_64; rv:103.0) Gecko/20100101 Firefox/103.0"
+
+func TestRecorder_Close(t *testing.T) {
+	sink := NewRingSink(10)
+	r := NewRecorder(sink, 0, nil)
+	r.Record(Event{ID: "1111", UserAgent: browserUA})
+	r.Record(Event{ID: "2222", UserAgent: browserUA})
+
+	// при закрытии все накопленное должно быть сброшено в хранилище
+	require.NoError(t, r.Close())
+	assert.Len(t, sink.Events(), 2)
+}
+
+func TestRecorder_Flush(t *testing.T) {
+	sink := NewRingSink(10)
+	r := NewRecorder(sink, 0, nil)
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+	r.Record(Event{ID: "1111", UserAgent: browserUA})
+
+	// неполный пакет должен уйти в хранилище по таймеру
+	assert.Eventually(t, func() bool {
+		return len(sink.Events()) == 1
+	}, 3*flushInterval, 10*time.Millisecond)
+}
+
+func TestRecorder_Retention(t *testing.T) {
+	sink := NewRingSink(10)
+	r := NewRecorder(sink, 0, nil)
+	r.Record(Event{ID: "1111", UserAgent: browserUA, Time: time.Now().Add(-48 * time.Hour)})
+	r.Record(Event{ID: "2222", UserAgent: browserUA, Time: time.Now()})
+	require.NoError(t, r.Close())
+
+	// чистка запускается при старте
+	r = NewRecorder(sink, 24*time.Hour, nil)
+	require.NoError(t, r.Close())
+	events := sink.Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, "2222", events[0].ID)
+}
+
+func TestRecorder_Bots(t *testing.T) {
+	sink := NewRingSink(10)
+	r := NewRecorder(sink, 0, nil)
+	r.Record(Event{ID: "1111", UserAgent: browserUA})
+	r.Record(Event{ID: "1111", UserAgent: "Googlebot/2.1 (+http://www.google.com/bot.html)"})
+	require.NoError(t, r.Close())
+
+	// переходы ботов не попадают в статистику по умолчанию
+	totals, err := r.Totals(context.Background(), []string{"1111"}, time.Time{}, time.Time{}, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"1111": 1}, totals)
+	totals, err = r.Totals(context.Background(), []string{"1111"}, time.Time{}, time.Time{}, true)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"1111": 2}, totals)
+	assert.Len(t, sink.BotEvents(), 1)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/abuse"
+	midware "github.com/UndeadDemidov/yandex-praktikum/internal/app/middleware"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// WithAbuseReports подключает хранилище жалоб на ссылки, по умолчанию жалобы хранятся в памяти.
+func WithAbuseReports(store abuse.Store) Option {
+	return func(s *URLShortener) {
+		s.reports = store
+	}
+}
+
+// HandlePostReport - публичный метод для жалобы на короткую ссылку, например на фишинг.
+// Причина жалобы передается json объектом AbuseReportRequest, тело запроса можно не передавать.
+// Жалоба попадает в очередь модерации, повторная жалоба пользователя на ту же ссылку заменяет предыдущую.
+// Метод занимает хвост пути report для POST, поэтому он не пробрасывается в оригинальную ссылку.
+func (s URLShortener) HandlePostReport(w http.ResponseWriter, r *http.Request) {
+	var req AbuseReportRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, ErrProperJSONIsExpected.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+	defer cancel()
+
+	id := chi.URLParam(r, "id")
+	report := abuse.Report{
+		ID:       id,
+		Reporter: midware.GetUserID(ctx),
+		Reason:   req.Reason,
+		Time:     time.Now().UTC(),
+	}
+	if err = report.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := s.restoreLink(ctx, w, id); !ok {
+		return
+	}
+	if err = s.reports.Add(ctx, report); err != nil {
+		utils.InternalServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// HandleGetReports - метод администратора для получения очереди модерации, см. abuse.Store.Queue.
+// Доступ к методу ограничивается доверенной подсетью на уровне роутера.
+func (s URLShortener) HandleGetReports(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+	defer cancel()
+
+	queue, err := s.reports.Queue(ctx)
+	if err != nil {
+		utils.InternalServerError(w, err)
+		return
+	}
+	if len(queue) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp := make([]ReportedLinkItem, 0, len(queue))
+	for _, e := range queue {
+		item := ReportedLinkItem{ShortURL: fmt.Sprintf("%s%s", s.baseURL, e.ID), Reports: e.Reports}
+		link, err := s.linkRepo.Restore(ctx, e.ID)
+		switch {
+		case errors.Is(err, ErrLinkIsDeleted):
+			item.IsDeleted = true
+		case err != nil:
+			utils.InternalServerError(w, err)
+			return
+		default:
+			item.OriginalURL = link.URL
+			item.IsBlocked = link.Blocked
+		}
+		resp = append(resp, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(&resp)
+	if err != nil {
+		utils.InternalServerError(w, err)
+	}
+}
+
+// HandleBlock - метод администратора для блокировки ссылки, переход по ней отвечает 451.
+// Жалобы на ссылку убираются из очереди модерации.
+// Доступ к методу ограничивается доверенной подсетью на уровне роутера.
+func (s URLShortener) HandleBlock(w http.ResponseWriter, r *http.Request) {
+	s.moderate(w, r, true)
+}
+
+// HandleUnblock - метод администратора для снятия блокировки ссылки.
+// Им же отклоняются жалобы на незаблокированную ссылку: они убираются из очереди модерации.
+// Доступ к методу ограничивается доверенной подсетью на уровне роутера.
+func (s URLShortener) HandleUnblock(w http.ResponseWriter, r *http.Request) {
+	s.moderate(w, r, false)
+}
+
+// moderate устанавливает блокировку ссылки и закрывает жалобы на нее.
+func (s URLShortener) moderate(w http.ResponseWriter, r *http.Request, blocked bool) {
+	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+	defer cancel()
+
+	id := chi.URLParam(r, "id")
+	err := s.linkRepo.SetBlocked(ctx, id, blocked)
+	switch {
+	case errors.Is(err, ErrLinkIsNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		utils.InternalServerError(w, err)
+		return
+	}
+	if err = s.reports.Resolve(ctx, id); err != nil {
+		utils.InternalServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/abuse"
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	midware "github.com/UndeadDemidov/yandex-praktikum/internal/app/middleware"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLShortener_HandlePostReport(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		queued  int
+		prepare func(repo *mock_handlers.MockRepository)
+	}{
+		{
+			name:   "report",
+			body:   `{"reason": "phishing"}`,
+			status: http.StatusAccepted,
+			queued: 1,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Restore(gomock.Any(), "1111").Return(storages.Link{ID: "1111", URL: "https://ya.ru"}, nil)
+			},
+		},
+		{
+			name:   "without body",
+			status: http.StatusAccepted,
+			queued: 1,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Restore(gomock.Any(), "1111").Return(storages.Link{ID: "1111", URL: "https://ya.ru"}, nil)
+			},
+		},
+		{
+			name:   "unknown link",
+			status: http.StatusNotFound,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Restore(gomock.Any(), "1111").Return(storages.Link{}, ErrLinkIsNotFound)
+			},
+		},
+		{
+			name:   "already blocked",
+			status: http.StatusUnavailableForLegalReasons,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Restore(gomock.Any(), "1111").Return(storages.Link{ID: "1111", Blocked: true}, nil)
+			},
+		},
+		{
+			name:   "broken json",
+			body:   `{"reason":`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "too long reason",
+			body:   `{"reason": "` + strings.Repeat("a", 1001) + `"}`,
+			status: http.StatusBadRequest,
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			if tt.prepare != nil {
+				tt.prepare(repo)
+			}
+			reports := abuse.NewMemoryStore()
+
+			h := NewURLShortener(baseURL, repo, WithAbuseReports(reports))
+			r := chi.NewRouter()
+			// хвост report зарезервирован и не уходит в проброс пути и форму пароля
+			r.Post("/{id}/*", h.HandleGet)
+			r.Post("/{id}/report", h.HandlePostReport)
+			req := httptest.NewRequest(http.MethodPost, "/1111/report", strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), midware.ContextUserIDKey, "xxxx"))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			result := w.Result()
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, tt.status, result.StatusCode)
+			queue, err := reports.Queue(context.Background())
+			require.NoError(t, err)
+			assert.Len(t, queue, tt.queued)
+		})
+	}
+}
+
+func TestURLShortener_Moderation(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	repo := mock_handlers.NewMockRepository(mockCtrl)
+
+	ctx := context.Background()
+	reported := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	reports := abuse.NewMemoryStore()
+	require.NoError(t, reports.Add(ctx, abuse.Report{ID: "1111", Reporter: "xxxx", Reason: "phishing", Time: reported}))
+	require.NoError(t, reports.Add(ctx, abuse.Report{ID: "2222", Reporter: "xxxx", Time: reported}))
+
+	h := NewURLShortener(baseURL, repo, WithAbuseReports(reports))
+	r := chi.NewRouter()
+	r.Get("/api/internal/reports", h.HandleGetReports)
+	r.Put("/api/internal/urls/{id}/block", h.HandleBlock)
+	r.Delete("/api/internal/urls/{id}/block", h.HandleUnblock)
+	do := func(method, target string) (int, string) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		result := w.Result()
+		body, err := io.ReadAll(result.Body)
+		require.NoError(t, err)
+		require.NoError(t, result.Body.Close())
+		return result.StatusCode, string(body)
+	}
+
+	repo.EXPECT().Restore(gomock.Any(), "1111").Return(storages.Link{ID: "1111", URL: "https://ya.ru"}, nil)
+	repo.EXPECT().Restore(gomock.Any(), "2222").Return(storages.Link{}, ErrLinkIsDeleted)
+	status, body := do(http.MethodGet, "/api/internal/reports")
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `[
+		{"short_url": "http://localhost:8080/1111", "original_url": "https://ya.ru", "is_deleted": false, "is_blocked": false,
+		 "reports": [{"id": "1111", "reporter": "xxxx", "reason": "phishing", "time": "2022-10-01T12:00:00Z"}]},
+		{"short_url": "http://localhost:8080/2222", "is_deleted": true, "is_blocked": false,
+		 "reports": [{"id": "2222", "reporter": "xxxx", "time": "2022-10-01T12:00:00Z"}]}
+	]`, body)
+
+	// блокировка и отклонение жалоб убирают ссылки из очереди
+	repo.EXPECT().SetBlocked(gomock.Any(), "1111", true).Return(nil)
+	status, _ = do(http.MethodPut, "/api/internal/urls/1111/block")
+	assert.Equal(t, http.StatusNoContent, status)
+	repo.EXPECT().SetBlocked(gomock.Any(), "2222", false).Return(nil)
+	status, _ = do(http.MethodDelete, "/api/internal/urls/2222/block")
+	assert.Equal(t, http.StatusNoContent, status)
+	status, _ = do(http.MethodGet, "/api/internal/reports")
+	assert.Equal(t, http.StatusNoContent, status)
+
+	repo.EXPECT().SetBlocked(gomock.Any(), "3333", true).Return(ErrLinkIsNotFound)
+	status, _ = do(http.MethodPut, "/api/internal/urls/3333/block")
+	assert.Equal(t, http.StatusNotFound, status)
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/canonical"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+)
+
+// WithCanonicalization задает необязательные шаги приведения ссылок к канонической форме,
+// по умолчанию параметры запроса не упорядочиваются.
+func WithCanonicalization(opts canonical.Options) Option {
+	return func(s *URLShortener) {
+		s.canonical = opts
+	}
+}
+
+// canonicalize заполняет каноническую форму оригинальной ссылки, по которой хранилище находит повторы.
+// Вызывается после всех изменений оригинальной ссылки, в том числе после применения шаблона UTM меток.
+func (s URLShortener) canonicalize(link *storages.Link) (err error) {
+	link.Canonical, err = canonical.URL(link.URL, s.canonical)
+	return err
+}
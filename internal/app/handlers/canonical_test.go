@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/canonical"
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLShortener_Canonicalization(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		opts    []Option
+		status  int
+		prepare func(repo *mock_handlers.MockRepository)
+	}{
+		{
+			name:   "original is kept",
+			body:   "HTTP://Example.com:80/a?b=1&a=2",
+			status: http.StatusCreated,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Store(gomock.Any(), gomock.Any(), storages.Link{
+					URL:       "HTTP://Example.com:80/a?b=1&a=2",
+					Canonical: "http://example.com/a?b=1&a=2",
+				}).Return("1111", nil)
+			},
+		},
+		{
+			name:   "sorted query",
+			body:   "HTTP://Example.com:80/a?b=1&a=2",
+			opts:   []Option{WithCanonicalization(canonical.Options{SortQuery: true})},
+			status: http.StatusConflict,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Store(gomock.Any(), gomock.Any(), storages.Link{
+					URL:       "HTTP://Example.com:80/a?b=1&a=2",
+					Canonical: "http://example.com/a?a=2&b=1",
+				}).Return("1111", ErrLinkIsAlreadyShortened)
+			},
+		},
+		{
+			name:   "too long domain label",
+			body:   "http://" + strings.Repeat("ё", 64) + ".ru",
+			status: http.StatusBadRequest,
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			if tt.prepare != nil {
+				tt.prepare(repo)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			h := NewURLShortener(baseURL, repo, tt.opts...)
+			w := httptest.NewRecorder()
+			h.HandlePostShortenPlain(w, r)
+			result := w.Result()
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, tt.status, result.StatusCode)
+		})
+	}
+}
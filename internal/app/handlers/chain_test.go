@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/chain"
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/policy"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLShortener_RedirectChain(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/short":
+			http.Redirect(w, r, "/middle", http.StatusMovedPermanently)
+		case "/middle":
+			http.Redirect(w, r, srv.URL+"/final", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop?again", http.StatusFound)
+		case "/self":
+			http.Redirect(w, r, "http://localhost:8080/1111", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+	// в тестах сервер слушает loopback адрес
+	allowLoopback := WithDestinationPolicy(policy.Policy{Schemes: []string{"http"}})
+
+	tests := []struct {
+		name       string
+		url        string
+		storeFinal bool
+		status     int
+		result     string
+		prepare    func(repo *mock_handlers.MockRepository)
+	}{
+		{
+			name:   "original stored",
+			url:    srv.URL + "/short",
+			status: http.StatusCreated,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Store(gomock.Any(), gomock.Any(), linkWithURL(srv.URL+"/short")).Return("1111", nil)
+			},
+		},
+		{
+			name:       "final stored",
+			url:        srv.URL + "/short",
+			storeFinal: true,
+			status:     http.StatusCreated,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Store(gomock.Any(), gomock.Any(), linkWithURL(srv.URL+"/final")).Return("1111", nil)
+			},
+		},
+		{
+			name:   "loop",
+			url:    srv.URL + "/loop",
+			status: http.StatusUnprocessableEntity,
+			result: "redirect loop detected: " + srv.URL + "/loop?again\n",
+		},
+		{
+			name:   "self reference in chain",
+			url:    srv.URL + "/self",
+			status: http.StatusUnprocessableEntity,
+			result: "destination is not allowed: link points to the shortener itself: http://localhost:8080/\n",
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			if tt.prepare != nil {
+				tt.prepare(repo)
+			}
+
+			h := NewURLShortener(baseURL, repo, allowLoopback,
+				WithRedirectChain(chain.NewFollower(srv.Client(), 2), tt.storeFinal))
+			w := httptest.NewRecorder()
+			h.HandlePostShortenPlain(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.url)))
+			result := w.Result()
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, tt.status, result.StatusCode)
+			if tt.result != "" {
+				assert.Equal(t, tt.result, string(body))
+			}
+		})
+	}
+}
+
+func TestURLShortener_PatchRedirectChain(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/short" {
+			http.Redirect(w, r, srv.URL+"/final", http.StatusMovedPermanently)
+		}
+	}))
+	defer srv.Close()
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	repo := mock_handlers.NewMockRepository(mockCtrl)
+	final, canonical := srv.URL+"/final", srv.URL+"/final"
+	repo.EXPECT().Update(gomock.Any(), gomock.Any(), "1111", storages.LinkPatch{URL: &final, Canonical: &canonical}).
+		Return(storages.Link{ID: "1111", URL: final}, nil)
+
+	h := NewURLShortener(baseURL, repo, WithDestinationPolicy(policy.Policy{Schemes: []string{"http"}}),
+		WithRedirectChain(chain.NewFollower(srv.Client(), 2), true))
+	r := chi.NewRouter()
+	r.Patch("/api/user/urls/{id}", h.HandlePatchUserURL)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/api/user/urls/1111", strings.NewReader(`{"url": "`+srv.URL+`/short"}`)))
+	result := w.Result()
+	require.NoError(t, result.Body.Close())
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+}
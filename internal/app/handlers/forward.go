@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+)
+
+// extraPath возвращает экранированный хвост пути запроса после id короткой ссылки,
+// например "a/b%20c" для "/1111/a/b%20c". Пустые сегменты, "." и ".." отбрасываются,
+// чтобы хвост не мог выйти за пределы пути оригинальной ссылки.
+func extraPath(r *http.Request) string {
+	_, tail, _ := strings.Cut(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+	segments := make([]string, 0)
+	for _, s := range strings.Split(tail, "/") {
+		switch s {
+		case "", ".", "..":
+			continue
+		}
+		segments = append(segments, s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// destination возвращает адрес перенаправления по ссылке с учетом ее настроек проброса:
+//   - хвост пути tail дописывается к пути оригинальной ссылки через один "/";
+//   - параметры запроса дописываются к параметрам оригинальной ссылки, но параметры,
+//     уже заданные в оригинальной ссылке, имеют приоритет и из запроса не берутся;
+//   - фрагмент оригинальной ссылки сохраняется.
+//
+// Если проброс не включен или пробрасывать нечего, то возвращается оригинальная ссылка без изменений.
+func destination(link storages.Link, query url.Values, tail string) string {
+	passPath := link.ForwardPath && tail != ""
+	passQuery := link.ForwardQuery && len(query) != 0
+	if !passPath && !passQuery {
+		return link.URL
+	}
+	u, err := url.Parse(link.URL)
+	if err != nil {
+		return link.URL
+	}
+
+	if passPath {
+		escaped := strings.TrimSuffix(u.EscapedPath(), "/") + "/" + tail
+		if path, err := url.PathUnescape(escaped); err == nil {
+			u.Path, u.RawPath = path, escaped
+		}
+	}
+	if passQuery {
+		own := u.Query()
+		extra := url.Values{}
+		for k, v := range query {
+			if _, ok := own[k]; !ok {
+				extra[k] = v
+			}
+		}
+		if len(extra) != 0 {
+			if u.RawQuery != "" {
+				u.RawQuery += "&"
+			}
+			u.RawQuery += extra.Encode()
+		}
+	}
+	return u.String()
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_destination(t *testing.T) {
+	both := storages.Link{ForwardQuery: true, ForwardPath: true}
+	tests := []struct {
+		name  string
+		link  storages.Link
+		url   string
+		query string
+		tail  string
+		want  string
+	}{
+		{
+			name:  "forwarding is off",
+			url:   "https://ya.ru/search",
+			query: "utm_source=mail",
+			tail:  "extra",
+			want:  "https://ya.ru/search",
+		},
+		{
+			name:  "query is appended",
+			link:  both,
+			url:   "https://ya.ru/search?text=go",
+			query: "utm_source=mail",
+			want:  "https://ya.ru/search?text=go&utm_source=mail",
+		},
+		{
+			name:  "own query wins",
+			link:  both,
+			url:   "https://ya.ru/search?text=go&lr=213",
+			query: "text=rust&text=c&utm_source=mail",
+			want:  "https://ya.ru/search?text=go&lr=213&utm_source=mail",
+		},
+		{
+			name: "path is appended",
+			link: both,
+			url:  "https://ya.ru/docs/",
+			tail: "a/b%20c",
+			want: "https://ya.ru/docs/a/b%20c",
+		},
+		{
+			name:  "path and query with fragment",
+			link:  both,
+			url:   "https://ya.ru/docs#top",
+			query: "utm_source=mail",
+			tail:  "page",
+			want:  "https://ya.ru/docs/page?utm_source=mail#top",
+		},
+		{
+			name:  "query only",
+			link:  storages.Link{ForwardQuery: true},
+			url:   "https://ya.ru",
+			query: "utm_source=mail",
+			tail:  "page",
+			want:  "https://ya.ru?utm_source=mail",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+			tt.link.URL = tt.url
+			assert.Equal(t, tt.want, destination(tt.link, query, tt.tail))
+		})
+	}
+}
+
+func TestURLShortener_HandleGetForwarding(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		link     storages.Link
+		status   int
+		location string
+	}{
+		{
+			name:     "query and path",
+			target:   "/1111/a/./../b?utm_source=mail",
+			link:     storages.Link{URL: "https://ya.ru/docs", ForwardQuery: true, ForwardPath: true},
+			status:   http.StatusTemporaryRedirect,
+			location: "https://ya.ru/docs/a/b?utm_source=mail",
+		},
+		{
+			name:     "query is dropped",
+			target:   "/1111?utm_source=mail",
+			link:     storages.Link{URL: "https://ya.ru/docs"},
+			status:   http.StatusTemporaryRedirect,
+			location: "https://ya.ru/docs",
+		},
+		{
+			name:   "extra path is not accepted",
+			target: "/1111/a",
+			link:   storages.Link{URL: "https://ya.ru/docs", ForwardQuery: true},
+			status: http.StatusNotFound,
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			repo.EXPECT().Restore(gomock.Any(), "1111").Return(tt.link, nil)
+
+			h := NewURLShortener(baseURL, repo)
+			r := chi.NewRouter()
+			r.Get("/{id}", h.HandleGet)
+			r.Get("/{id}/*", h.HandleGet)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			result := w.Result()
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, tt.status, result.StatusCode)
+			assert.Equal(t, tt.location, result.Header.Get("Location"))
+		})
+	}
+}
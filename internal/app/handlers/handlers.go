@@ -10,19 +10,31 @@ import (
 	"strings"
 	"time"
 
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/abuse"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/canonical"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/chain"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/clicks"
 	midware "github.com/UndeadDemidov/yandex-praktikum/internal/app/middleware"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/password"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/policy"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
 	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utm"
 	"github.com/go-chi/chi/v5"
 	_ "github.com/golang/mock/mockgen/model"
 	"github.com/rs/zerolog/log"
 )
 
 //go:generate mockgen -destination=./mocks/mock_repository.go . Repository
+//go:generate mockgen -destination=./mocks/mock_click_recorder.go . ClickRecorder
 
 var (
 	ErrLinkIsAlreadyShortened = errors.New("link is already shortened")
 	ErrEmptyBatchToShort      = errors.New("nothing to short")
 	ErrLinkIsDeleted          = errors.New("link is deleted")
+	ErrLinkIsNotFound         = errors.New("link is not found")
+	ErrLinkIsBlocked          = errors.New("link is blocked due to abuse reports")
+	ErrLinkIsExhausted        = errors.New("link has reached its click limit")
 	ErrMethodNotAllowed       = errors.New("method is not allowed, read task description carefully")
 	ErrProperJSONIsExpected   = errors.New("proper JSON is expected, read task description carefully")
 )
@@ -31,19 +43,62 @@ var (
 // и открытие по сокращенному варианту. Обеспечивается контроль авторства сокращенных ссылок.
 type URLShortener struct {
 	linkRepo Repository
+	clicks   ClickRecorder
 	baseURL  string
+	// redirect - код перенаправления для ссылок, у которых он не выбран при создании
+	redirect  int
+	templates utm.Store
+	// canonical - необязательные шаги канонизации оригинальных ссылок
+	canonical canonical.Options
+	// policy - правила для оригинальных ссылок, Self всегда равен baseURL
+	policy policy.Policy
+	// attempts - ограничение неудачных попыток ввода пароля ссылок
+	attempts *password.Limiter
+	// chain - проход цепочки перенаправлений при сокращении, nil - выключен
+	chain      *chain.Follower
+	storeFinal bool
+	// reports - очередь жалоб на ссылки для модерации
+	reports abuse.Store
+	// notYetActive - ответ на переход по ссылке до начала ее окна активности
+	notYetActive notYetActive
+	// pick возвращает случайное число из [0, n) для выбора варианта ссылки
+	pick func(n int) int
+	// quotas - ограничения количества ссылок пользователей и размера пакета
+	quotas *quotaBook
+}
+
+// Option - функциональная опция для дополнительной настройки URLShortener.
+type Option func(s *URLShortener)
+
+// WithClickRecorder подключает журнал переходов по коротким ссылкам.
+func WithClickRecorder(rec ClickRecorder) Option {
+	return func(s *URLShortener) {
+		s.clicks = rec
+	}
 }
 
 // NewURLShortener создает URLShortener и инициализирует его адресом, по которому будут доступны методы,
 // и репозиторием хранения ссылок.
-func NewURLShortener(base string, repo Repository) *URLShortener {
+func NewURLShortener(base string, repo Repository, opts ...Option) *URLShortener {
 	h := URLShortener{}
 	h.linkRepo = repo
+	h.redirect = defaultRedirectCode
+	h.templates = utm.NewMemoryStore()
+	h.policy = policy.Default()
+	h.reports = abuse.NewMemoryStore()
+	h.attempts = password.NewLimiter(defaultPasswordAttempts, defaultPasswordLockout)
+	h.notYetActive.status = defaultNotYetActiveStatus
+	h.pick = randomPoint
+	h.quotas = newQuotaBook(Quotas{})
 	if utils.IsURL(base) {
 		h.baseURL = fmt.Sprintf("%s/", strings.TrimRight(base, "/"))
 	} else {
 		h.baseURL = "http://localhost:8080/"
 	}
+	for _, opt := range opts {
+		opt(&h)
+	}
+	h.policy.Self = h.baseURL
 
 	return &h
 }
@@ -73,8 +128,17 @@ func (s URLShortener) HandlePostShortenPlain(w http.ResponseWriter, r *http.Requ
 
 	w.Header().Set("Content-Type", "application/json")
 
+	newLink := storages.Link{URL: link}
+	if err = s.prepareDestination(ctx, &newLink); err != nil {
+		http.Error(w, err.Error(), destinationErrorStatus(err))
+		return
+	}
 	user := midware.GetUserID(ctx)
-	shortenedURL, err := s.shorten(ctx, user, link)
+	if err = s.checkQuota(ctx, user, []storages.Link{newLink}); err != nil {
+		quotaFailed(w, err)
+		return
+	}
+	shortenedURL, err := s.shorten(ctx, user, newLink)
 	switch {
 	case errors.Is(err, ErrLinkIsAlreadyShortened):
 		w.WriteHeader(http.StatusConflict)
@@ -93,6 +157,7 @@ func (s URLShortener) HandlePostShortenPlain(w http.ResponseWriter, r *http.Requ
 }
 
 // HandlePostShortenJSON - метод для создания короткой ссылки, где оригинальная ссылка передается с помощью JSON.
+// Вместо одной оригинальной ссылки можно передать несколько вариантов с весами, см. pickVariant.
 func (s URLShortener) HandlePostShortenJSON(w http.ResponseWriter, r *http.Request) {
 	req := URLShortenRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -100,19 +165,77 @@ func (s URLShortener) HandlePostShortenJSON(w http.ResponseWriter, r *http.Reque
 		http.Error(w, ErrProperJSONIsExpected.Error(), http.StatusBadRequest)
 		return
 	}
-	if !utils.IsURL(req.URL) {
-		http.Error(w, fmt.Sprintf("Hey, Dude! Provide a link! Not the crap: %v", req.URL), http.StatusBadRequest)
-		log.Debug().Msg(fmt.Sprintf("User provided data: %v", req.URL))
+	if req.URL != "" && len(req.Variants) != 0 {
+		http.Error(w, ErrURLAndVariants.Error(), http.StatusBadRequest)
+		return
+	}
+	link := storages.Link{
+		URL:          req.URL,
+		Title:        req.Title,
+		Tags:         normalizeTags(req.Tags),
+		Notes:        req.Notes,
+		Redirect:     req.Redirect,
+		ForwardQuery: req.ForwardQuery,
+		ForwardPath:  req.ForwardPath,
+		MaxClicks:    req.MaxClicks,
+		NotBefore:    req.NotBefore.UTC(),
+		NotAfter:     req.NotAfter.UTC(),
+	}
+	if len(req.Variants) != 0 {
+		link.Variants = newVariants(req.Variants)
+		link.URL = link.Variants[0].URL
+	}
+	if len(req.Rules) != 0 {
+		link.Rules = newRules(req.Rules)
+	}
+	for _, dest := range destinations(link) {
+		if !utils.IsURL(dest) {
+			http.Error(w, fmt.Sprintf("Hey, Dude! Provide a link! Not the crap: %v", dest), http.StatusBadRequest)
+			log.Debug().Msg(fmt.Sprintf("User provided data: %v", dest))
+			return
+		}
+	}
+	if err = validateLink(link); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = protect(&link, req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
 	defer cancel()
 
+	user := midware.GetUserID(ctx)
+	err = rewriteDestinations(&link, func(rawURL string) (string, error) {
+		return s.applyTemplate(ctx, user, rawURL, req.UTMTemplate)
+	})
+	switch {
+	case errors.Is(err, utm.ErrTemplateNotFound):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		utils.InternalServerError(w, err)
+		return
+	}
+	if err = s.prepareDestination(ctx, &link); err != nil {
+		http.Error(w, err.Error(), destinationErrorStatus(err))
+		return
+	}
+	if err = s.prepareRules(ctx, link.Rules); err != nil {
+		http.Error(w, err.Error(), destinationErrorStatus(err))
+		return
+	}
+
+	if err = s.checkQuota(ctx, user, []storages.Link{link}); err != nil {
+		quotaFailed(w, err)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
-	user := midware.GetUserID(ctx)
-	shortenedURL, err := s.shorten(ctx, user, req.URL)
+	shortenedURL, err := s.shorten(ctx, user, link)
 	switch {
 	case errors.Is(err, ErrLinkIsAlreadyShortened):
 		w.WriteHeader(http.StatusConflict)
@@ -131,9 +254,9 @@ func (s URLShortener) HandlePostShortenJSON(w http.ResponseWriter, r *http.Reque
 }
 
 // shorten возвращает короткую ссылку в ответ на оригинальную
-func (s URLShortener) shorten(ctx context.Context, user string, originalURL string) (shortenedURL string, err error) {
+func (s URLShortener) shorten(ctx context.Context, user string, link storages.Link) (shortenedURL string, err error) {
 	var id string
-	id, err = s.linkRepo.Store(ctx, user, originalURL)
+	id, err = s.linkRepo.Store(ctx, user, link)
 	if err == nil || errors.Is(err, ErrLinkIsAlreadyShortened) {
 		return fmt.Sprintf("%s%s", s.baseURL, id), err
 	}
@@ -141,24 +264,145 @@ func (s URLShortener) shorten(ctx context.Context, user string, originalURL stri
 }
 
 // HandleGet - метод для открытия оригинальной ссылки по короткому варианту.
+// Параметры запроса и хвост пути после id пробрасываются в оригинальную ссылку,
+// если это включено у ссылки, см. destination. Хвост пути у остальных ссылок не найден.
+// Для /{id}+ и ?preview=1 вместо перехода отдается страница предпросмотра, см. renderPreview.
+// Для ссылок с паролем сначала отдается форма ввода пароля, см. unlock.
+// Вне окна активности ссылки переход и предпросмотр не выполняются, см. checkWindow.
+// Если посетителю подходит одно из правил ссылки, переход выполняется по нему, см. applyRules,
+// иначе у ссылок с вариантами - на выпавший посетителю вариант, см. pickVariant.
 func (s URLShortener) HandleGet(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
+	id, isPreview := previewRequested(r, chi.URLParam(r, "id"))
 	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
 	defer cancel()
 
-	url, err := s.linkRepo.Restore(ctx, id)
+	link, ok := s.restoreLink(ctx, w, id)
+	if !ok || !s.checkWindow(w, link) {
+		return
+	}
+	tail := extraPath(r)
+	if tail != "" && !link.ForwardPath {
+		http.Error(w, fmt.Sprintf("link %s does not accept extra path", id), http.StatusNotFound)
+		return
+	}
+	code := s.redirectCode(link.Redirect)
+	if link.PasswordHash != "" {
+		if !s.unlock(w, r, link) {
+			return
+		}
+		// пароль пришел формой: 303 переводит браузер на GET и не передает пароль в оригинальную ссылку
+		code = http.StatusSeeOther
+	}
+	query := r.URL.Query()
+	target, ruled := applyRules(r, link)
+	variant := 0
+	if !ruled {
+		target, variant = s.pickVariant(w, r, link)
+	}
+	if isPreview {
+		query.Del("preview")
+		renderPreview(w, link, destination(target, query, tail))
+		return
+	}
+	cache := cacheControl(code)
+	if variant != 0 {
+		// переход из кэша не попадает в переходы по вариантам
+		cache = cacheControl(http.StatusTemporaryRedirect)
+	}
+	if !link.NotBefore.IsZero() || !link.NotAfter.IsZero() {
+		// перенаправление из кэша продолжало бы работать после окончания окна активности
+		cache = cacheControl(http.StatusTemporaryRedirect)
+	}
+	if len(link.Rules) != 0 {
+		// адрес зависит от посетителя, поэтому общий кэш не должен его запоминать
+		cache = cacheControl(http.StatusTemporaryRedirect)
+		w.Header().Set("Vary", "User-Agent, Accept-Language")
+	}
+	if link.MaxClicks > 0 {
+		if !s.consumeClick(ctx, w, r, id) {
+			return
+		}
+		// переход из кэша не расходует остаток переходов
+		cache = cacheControl(http.StatusTemporaryRedirect)
+	}
+	w.Header().Add("Location", destination(target, query, tail))
+	w.Header().Set("Cache-Control", cache)
+	w.WriteHeader(code)
+	s.recordClick(r, id, link.User, variant)
+}
+
+// consumeClick расходует переход по ссылке с ограничением переходов. HEAD запросы переходы не расходуют,
+// чтобы их не исчерпали проверки ссылок. Если переходы закончились, то ответ уже записан в w и возвращается false.
+func (s URLShortener) consumeClick(ctx context.Context, w http.ResponseWriter, r *http.Request, id string) bool {
+	if r.Method == http.MethodHead {
+		return true
+	}
+	_, err := s.linkRepo.ConsumeClick(ctx, id)
+	switch {
+	case errors.Is(err, ErrLinkIsExhausted):
+		http.Error(w, err.Error(), http.StatusGone)
+		return false
+	case err != nil:
+		utils.InternalServerError(w, err)
+		return false
+	}
+	return true
+}
+
+// restoreLink возвращает ссылку по короткому ID. Если ссылку получить не удалось, она заблокирована
+// или переходы по ней закончились,
+// ответ с ошибкой уже записан в w и возвращается false.
+func (s URLShortener) restoreLink(ctx context.Context, w http.ResponseWriter, id string) (storages.Link, bool) {
+	link, err := s.linkRepo.Restore(ctx, id)
 	switch {
+	case errors.Is(err, ErrLinkIsNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
 	case errors.Is(err, ErrLinkIsDeleted):
 		http.Error(w, err.Error(), http.StatusGone)
-		log.Debug().Err(err)
-		return
 	case err != nil:
 		http.Error(w, err.Error(), http.StatusBadRequest)
-		log.Debug().Err(err)
+	case link.IsExhausted():
+		err = fmt.Errorf("%w: %s", ErrLinkIsExhausted, id)
+		http.Error(w, err.Error(), http.StatusGone)
+	case link.Blocked:
+		err = fmt.Errorf("%w: %s", ErrLinkIsBlocked, id)
+		http.Error(w, err.Error(), http.StatusUnavailableForLegalReasons)
+	default:
+		return link, true
+	}
+	log.Debug().Err(err)
+	return storages.Link{}, false
+}
+
+// recordClick отправляет событие перехода в журнал, если он подключен.
+// owner - владелец ссылки, variant - номер варианта, на который выполнен переход, 0 - у ссылки нет вариантов.
+// Запись происходит асинхронно и не задерживает ответ.
+func (s URLShortener) recordClick(r *http.Request, id, owner string, variant int) {
+	if s.clicks == nil {
 		return
 	}
-	w.Header().Add("Location", url)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	s.clicks.Record(clicks.Event{
+		Time:      time.Now().UTC(),
+		ID:        id,
+		Method:    r.Method,
+		Referer:   r.Referer(),
+		UserAgent: r.UserAgent(),
+		Purpose:   requestPurpose(r),
+		IP:        midware.ClientIP(r),
+		User:      midware.RequestUserID(r),
+		Owner:     owner,
+		Variant:   variant,
+	})
+}
+
+// requestPurpose возвращает назначение запроса, которое браузеры указывают при предзагрузке и предпросмотре.
+func requestPurpose(r *http.Request) string {
+	for _, h := range []string{"Sec-Purpose", "Purpose", "X-Purpose", "X-Moz"} {
+		if v := r.Header.Get(h); v != "" {
+			return v
+		}
+	}
+	return ""
 }
 
 // HandleDelete - метод для удаления раннее созданных коротких ссылок.
@@ -180,39 +424,109 @@ func (s URLShortener) HandleDelete(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s URLShortener) unstore(ctx context.Context, user string, req []URLID) {
+	list := urlIDs(req)
+	// Стартуем в отдельном thread, чтобы не блокировать handler, т.е. удаляем список id асинхронно
+	// Сколько вызовов - столько новых thread
+	go s.linkRepo.Unstore(ctx, user, list)
+}
+
+// HandleRestore - метод для восстановления раннее удаленных коротких ссылок.
+// На вход принимается json массив токенов коротких ссылок для восстановления.
+// Как и удаление, восстановление выполняется асинхронно.
+// Восстановление не должно превышать ограничение количества ссылок пользователя, см. checkQuota.
+func (s URLShortener) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	req := make([]URLID, 0)
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, ErrProperJSONIsExpected.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+	defer cancel()
+
+	user := midware.GetUserID(ctx)
+	if err = s.checkRestoreQuota(ctx, user, urlIDs(req)); err != nil {
+		quotaFailed(w, err)
+		return
+	}
+	s.undelete(ctx, user, req)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s URLShortener) undelete(ctx context.Context, user string, req []URLID) {
+	list := urlIDs(req)
+	// Так же, как и при удалении, не блокируем handler
+	go s.linkRepo.Undelete(ctx, user, list)
+}
+
+// urlIDs возвращает токены коротких ссылок из запроса.
+func urlIDs(req []URLID) []string {
 	list := make([]string, 0, len(req))
 	for _, urlID := range req {
 		list = append(list, string(urlID))
 	}
-	// Стартуем в отдельном thread, чтобы не блокировать handler, т.е. удаляем список id асинхронно
-	// Сколько вызовов - столько новых thread
-	go s.linkRepo.Unstore(ctx, user, list)
+	return list
 }
 
-// HandleGetUserURLsBucket - метод для получения всех сокращенных пользователем ссылок.
+// HandleGetUserURLsBucket - метод для постраничного получения сокращенных пользователем ссылок.
+// Параметры выборки описаны в parseLinkQuery. Если есть следующая страница,
+// то ссылка на нее с курсором передается в заголовке Link с rel="next".
 func (s URLShortener) HandleGetUserURLsBucket(w http.ResponseWriter, r *http.Request) {
+	q, err := parseLinkQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
 	defer cancel()
 
 	user := midware.GetUserID(ctx)
-	urlsMap := s.linkRepo.GetUserStorage(ctx, user)
-	if len(urlsMap) == 0 {
+	page, err := s.linkRepo.GetUserLinks(ctx, user, q)
+	if err != nil {
+		utils.InternalServerError(w, err)
+		return
+	}
+	if len(page.Links) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
+	if page.Next != nil {
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, nextPageLink(r.URL, page.Next)))
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	err := json.NewEncoder(w).Encode(MapToBucket(s.baseURL, urlsMap))
+	err = json.NewEncoder(w).Encode(LinksToBucket(s.baseURL, page.Links, s.linkTotals(ctx, page.Links)))
 	if err != nil {
 		utils.InternalServerError(w, err)
 		return
 	}
 }
 
+// linkTotals возвращает количество переходов по ссылкам за все время без учета ботов.
+// Если журнал переходов не подключен или недоступен, то возвращает nil.
+func (s URLShortener) linkTotals(ctx context.Context, links []storages.Link) map[string]int64 {
+	if s.clicks == nil {
+		return nil
+	}
+	ids := make([]string, 0, len(links))
+	for _, l := range links {
+		ids = append(ids, l.ID)
+	}
+	totals, err := s.clicks.Totals(ctx, ids, time.Time{}, time.Time{}, false)
+	if err != nil {
+		log.Err(err).Msg("can't get click totals for user links")
+		return nil
+	}
+	return totals
+}
+
 // HandlePostShortenBatch - метод для создания коротких ссылок одним пакетом,
 // где оригинальные ссылки передаются через JSON.
+// Размер пакета и количество ссылок пользователя ограничены, см. checkBatch и checkQuota.
 func (s URLShortener) HandlePostShortenBatch(w http.ResponseWriter, r *http.Request) {
 	var req []URLShortenCorrelatedRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -225,12 +539,27 @@ func (s URLShortener) HandlePostShortenBatch(w http.ResponseWriter, r *http.Requ
 	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
 	defer cancel()
 
+	user := midware.GetUserID(ctx)
+	if err = s.checkBatch(user, len(req)); err != nil {
+		quotaFailed(w, err)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
-	user := midware.GetUserID(ctx)
 	var resp []URLShortenCorrelatedResponse
 	resp, err = s.shortenBatch(ctx, user, req)
+	var (
+		itemErr  *batchItemError
+		quotaErr *QuotaError
+	)
 	switch {
+	case errors.As(err, &quotaErr):
+		quotaFailed(w, err)
+		return
+	case errors.As(err, &itemErr):
+		http.Error(w, err.Error(), destinationErrorStatus(err))
+		return
 	case errors.Is(err, ErrLinkIsAlreadyShortened):
 		w.WriteHeader(http.StatusConflict)
 	case err != nil:
@@ -253,9 +582,44 @@ func (s URLShortener) shortenBatch(ctx context.Context, user string, req []URLSh
 		return nil, ErrEmptyBatchToShort
 	}
 
-	batchIn := map[string]string{} // map[correlation_id]original_link
+	batchIn := map[string]storages.Link{} // map[correlation_id]original_link
 	for _, request := range req {
-		batchIn[request.CorrelationID] = request.OriginalURL
+		link := storages.Link{
+			URL:          request.OriginalURL,
+			Title:        request.Title,
+			Tags:         normalizeTags(request.Tags),
+			Notes:        request.Notes,
+			Redirect:     request.Redirect,
+			ForwardQuery: request.ForwardQuery,
+			ForwardPath:  request.ForwardPath,
+			MaxClicks:    request.MaxClicks,
+			NotBefore:    request.NotBefore.UTC(),
+			NotAfter:     request.NotAfter.UTC(),
+		}
+		if err = validateLink(link); err != nil {
+			return nil, &batchItemError{CorrelationID: request.CorrelationID, err: err}
+		}
+		if err = protect(&link, request.Password); err != nil {
+			return nil, &batchItemError{CorrelationID: request.CorrelationID, err: err}
+		}
+		link.URL, err = s.applyTemplate(ctx, user, link.URL, request.UTMTemplate)
+		if errors.Is(err, utm.ErrTemplateNotFound) {
+			return nil, &batchItemError{CorrelationID: request.CorrelationID, err: err}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = s.prepareDestination(ctx, &link); err != nil {
+			return nil, &batchItemError{CorrelationID: request.CorrelationID, err: err}
+		}
+		batchIn[request.CorrelationID] = link
+	}
+	links := make([]storages.Link, 0, len(batchIn))
+	for _, link := range batchIn {
+		links = append(links, link)
+	}
+	if err = s.checkQuota(ctx, user, links); err != nil {
+		return nil, err
 	}
 
 	batchOut, err := s.linkRepo.StoreBatch(ctx, user, batchIn) // batchOut = map[correlation_id]short_id
@@ -263,10 +627,16 @@ func (s URLShortener) shortenBatch(ctx context.Context, user string, req []URLSh
 		return []URLShortenCorrelatedResponse{}, err
 	}
 
+	// ответ собирается в порядке запроса, а не в порядке обхода map
 	resp = make([]URLShortenCorrelatedResponse, 0, len(req))
-	for corrID, id := range batchOut {
+	for _, request := range req {
+		id, ok := batchOut[request.CorrelationID]
+		if !ok {
+			continue
+		}
+		delete(batchOut, request.CorrelationID)
 		resp = append(resp, URLShortenCorrelatedResponse{
-			CorrelationID: corrID,
+			CorrelationID: request.CorrelationID,
 			ShortURL:      fmt.Sprintf("%s%s", s.baseURL, id),
 		})
 	}
@@ -274,6 +644,20 @@ func (s URLShortener) shortenBatch(ctx context.Context, user string, req []URLSh
 	return resp, err
 }
 
+// batchItemError - ошибка в данных элемента пакета, на который указывает CorrelationID.
+type batchItemError struct {
+	CorrelationID string
+	err           error
+}
+
+func (e *batchItemError) Error() string {
+	return fmt.Sprintf("correlation_id %s: %v", e.CorrelationID, e.err)
+}
+
+func (e *batchItemError) Unwrap() error {
+	return e.err
+}
+
 // HeartBeat - метод для проверки, что подключение к репозиторию живое.
 func (s URLShortener) HeartBeat(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
@@ -305,23 +689,64 @@ func (s URLShortener) HandleNotFound(w http.ResponseWriter, _ *http.Request) {
 // Repository описывает контракт работы с хранилищем.
 // Используется для удобства тестирования и для дальнейшей легкой миграции на другой "движок".
 type Repository interface {
-	// Store сохраняет оригинальную ссылку и возвращает id (токен) сокращенного варианта.
-	Store(ctx context.Context, user string, link string) (id string, err error)
-	// Restore возвращает оригинальную ссылку по его id.
-	// если error == ErrLinkIsDeleted значит короткая ссылка (id) была удалена.
-	Restore(ctx context.Context, id string) (link string, err error)
+	// Store сохраняет оригинальную ссылку link.URL вместе с ее описанием, метками и заметками
+	// и возвращает id (токен) сокращенного варианта.
+	Store(ctx context.Context, user string, link storages.Link) (id string, err error)
+	// Restore возвращает ссылку по ее id.
+	// если error == ErrLinkIsDeleted значит короткая ссылка (id) была удалена, сама ссылка при этом тоже возвращается.
+	Restore(ctx context.Context, id string) (link storages.Link, err error)
 	// Unstore - помечает ссылки удаленными.
 	// Согласно заданию - результат работы пользователю не возвращается.
 	Unstore(ctx context.Context, user string, ids []string)
-	// GetUserStorage возвращает массив всех ранее сокращенных пользователей ссылок.
-	GetUserStorage(ctx context.Context, user string) map[string]string
+	// Undelete - снимает со ссылок пометку удаления.
+	// Как и для Unstore, результат работы пользователю не возвращается.
+	Undelete(ctx context.Context, user string, ids []string)
+	// Update изменяет ссылку id пользователя и возвращает ее новое состояние.
+	// если error == ErrLinkIsNotFound значит у пользователя нет такой ссылки.
+	// если error == ErrLinkIsDeleted значит ссылка была удалена.
+	// если error == ErrInvalidWindow значит окно активности ссылки после изменения окажется пустым.
+	// если error == ErrLinkIsAlreadyShortened значит новая оригинальная ссылка уже сокращена,
+	// в этом случае возвращается ранее сохраненная ссылка, если ее удалось найти.
+	Update(ctx context.Context, user string, id string, patch storages.LinkPatch) (storages.Link, error)
+	// SetBlocked устанавливает или снимает блокировку ссылки id администратором.
+	// если error == ErrLinkIsNotFound значит такой ссылки нет.
+	SetBlocked(ctx context.Context, id string, blocked bool) error
+	// ConsumeClick атомарно уменьшает остаток переходов по ссылке id с ограничением переходов
+	// и возвращает новый остаток.
+	// если error == ErrLinkIsExhausted значит переходы по ссылке закончились.
+	ConsumeClick(ctx context.Context, id string) (remaining int, err error)
+	// GetUserLinks возвращает страницу ссылок пользователя, отобранных и упорядоченных согласно q.
+	GetUserLinks(ctx context.Context, user string, q storages.LinkQuery) (storages.LinkPage, error)
 	// StoreBatch сохраняет пакет ссылок в хранилище и возвращает список пакет id.
 	// batchIn = map[correlation_id]original_link
 	// batchOut= map[correlation_id]short_link
 	// если error == ErrLinkIsAlreadyShortened значит среди пакета были ранее сокращенные ссылки.
-	StoreBatch(ctx context.Context, user string, batchIn map[string]string) (batchOut map[string]string, err error)
+	StoreBatch(ctx context.Context, user string, batchIn map[string]storages.Link) (batchOut map[string]string, err error)
+	// FindShortened возвращает id уже сохраненных ссылок по их ключам уникальности, см. storages.Link.DedupKey.
+	// Ключи, которых в хранилище нет, в результат не попадают.
+	FindShortened(ctx context.Context, keys []string) (map[string]string, error)
+	// CountLinks возвращает количество активных, то есть не удаленных, ссылок пользователя.
+	// Заблокированные ссылки остаются у пользователя и тоже учитываются.
+	CountLinks(ctx context.Context, user string) (int, error)
+	// Stats возвращает сводную статистику хранилища.
+	Stats(ctx context.Context) (storages.Stats, error)
 	// Ping проверяет готовность к работе репозитория.
 	Ping(context.Context) error
 	// Close завершает работу репозитория в стиле graceful shutdown.
 	Close() error
 }
+
+// ClickRecorder описывает контракт журнала переходов по коротким ссылкам.
+type ClickRecorder interface {
+	// Record регистрирует переход, не блокируя обработку запроса.
+	Record(e clicks.Event)
+	// Series возвращает непрерывный ряд переходов по ссылке id в промежутке [from, to).
+	// Переходы ботов учитываются только при withBots.
+	Series(ctx context.Context, id string, g clicks.Granularity, from, to time.Time, withBots bool) ([]clicks.Bucket, error)
+	// Totals возвращает количество переходов по каждой из ссылок ids в промежутке [from, to).
+	// Нулевые from и to означают отсутствие соответствующей границы.
+	Totals(ctx context.Context, ids []string, from, to time.Time, withBots bool) (map[string]int64, error)
+	// Top возвращает не больше n ссылок владельца owner с наибольшим количеством переходов в промежутке [from, to).
+	// Ссылки без переходов в рейтинг не попадают.
+	Top(ctx context.Context, owner string, n int, from, to time.Time, withBots bool) ([]clicks.Total, error)
+}
@@ -3,6 +3,8 @@ package handlers
 import (
 	"context"
 	"errors"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
 )
 
 const mockedID = "1111"
@@ -20,29 +22,65 @@ func (rm RepoMock) IsExist(_ context.Context, _ string) bool {
 	return false
 }
 
-func (rm RepoMock) Store(_ context.Context, _ string, _ string) (id string, err error) {
+func (rm RepoMock) Store(_ context.Context, _ string, _ storages.Link) (id string, err error) {
 	// rm.singleItemStorage = link
 	return mockedID, nil
 }
 
-func (rm RepoMock) Restore(_ context.Context, id string) (link string, err error) {
+func (rm RepoMock) Restore(_ context.Context, id string) (link storages.Link, err error) {
 	if id != mockedID {
-		return "", ErrNotExistedID
+		return storages.Link{}, ErrNotExistedID
 	}
-	return rm.singleItemStorage, nil
+	return storages.Link{ID: id, URL: rm.singleItemStorage}, nil
 }
 
 func (rm RepoMock) Unstore(_ context.Context, _ string, _ []string) {
 }
 
-func (rm RepoMock) GetUserStorage(_ context.Context, _ string) map[string]string {
-	return map[string]string{mockedID: rm.singleItemStorage}
+func (rm RepoMock) Undelete(_ context.Context, _ string, _ []string) {
+}
+
+func (rm RepoMock) Update(_ context.Context, user string, id string, _ storages.LinkPatch) (storages.Link, error) {
+	if id != mockedID {
+		return storages.Link{}, ErrLinkIsNotFound
+	}
+	return storages.Link{ID: id, User: user, URL: rm.singleItemStorage}, nil
+}
+
+func (rm RepoMock) SetBlocked(_ context.Context, id string, _ bool) error {
+	if id != mockedID {
+		return ErrLinkIsNotFound
+	}
+	return nil
 }
 
-func (rm RepoMock) StoreBatch(_ context.Context, _ string, _ map[string]string) (batchOut map[string]string, err error) {
+func (rm RepoMock) ConsumeClick(_ context.Context, id string) (int, error) {
+	if id != mockedID {
+		return 0, ErrLinkIsNotFound
+	}
+	return 0, nil
+}
+
+func (rm RepoMock) GetUserLinks(_ context.Context, user string, _ storages.LinkQuery) (storages.LinkPage, error) {
+	return storages.LinkPage{Links: []storages.Link{{ID: mockedID, User: user, URL: rm.singleItemStorage}}}, nil
+}
+
+func (rm RepoMock) StoreBatch(_ context.Context, _ string, _ map[string]storages.Link) (batchOut map[string]string, err error) {
 	return map[string]string{}, nil
 }
 
+func (rm RepoMock) FindShortened(_ context.Context, _ []string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (rm RepoMock) CountLinks(_ context.Context, _ string) (int, error) {
+	return 0, nil
+}
+
+func (rm RepoMock) Stats(_ context.Context) (storages.Stats, error) {
+	return storages.Stats{URLs: 1, Users: 1}, nil
+}
+
 func (rm RepoMock) Close() error {
 	return nil
 }
@@ -11,8 +11,11 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/clicks"
 	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
 	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
 	"github.com/go-chi/chi/v5"
 	"github.com/golang/mock/gomock"
@@ -286,7 +289,7 @@ func TestURLShortenerHandler_HandleGet(t *testing.T) {
 			},
 			prepare: func(f *fields) {
 				gomock.InOrder(
-					f.repo.EXPECT().Restore(gomock.Any(), gomock.Any()).Return("https://ya.ru", nil),
+					f.repo.EXPECT().Restore(gomock.Any(), gomock.Any()).Return(storages.Link{URL: "https://ya.ru"}, nil),
 				)
 			},
 		},
@@ -299,7 +302,33 @@ func TestURLShortenerHandler_HandleGet(t *testing.T) {
 			},
 			prepare: func(f *fields) {
 				gomock.InOrder(
-					f.repo.EXPECT().Restore(gomock.Any(), gomock.Any()).Return("", ErrLinkIsDeleted),
+					f.repo.EXPECT().Restore(gomock.Any(), gomock.Any()).Return(storages.Link{}, ErrLinkIsDeleted),
+				)
+			},
+		},
+		{
+			name: "blocked link",
+			link: "http://localhost:8080",
+			want: want{
+				status:   http.StatusUnavailableForLegalReasons,
+				location: "",
+			},
+			prepare: func(f *fields) {
+				gomock.InOrder(
+					f.repo.EXPECT().Restore(gomock.Any(), gomock.Any()).Return(storages.Link{URL: "https://ya.ru", Blocked: true}, nil),
+				)
+			},
+		},
+		{
+			name: "unknown link",
+			link: "http://localhost:8080",
+			want: want{
+				status:   http.StatusNotFound,
+				location: "",
+			},
+			prepare: func(f *fields) {
+				gomock.InOrder(
+					f.repo.EXPECT().Restore(gomock.Any(), gomock.Any()).Return(storages.Link{}, fmt.Errorf(storages.ErrLinkNotFound, ErrLinkIsNotFound, "1111")),
 				)
 			},
 		},
@@ -312,7 +341,7 @@ func TestURLShortenerHandler_HandleGet(t *testing.T) {
 			},
 			prepare: func(f *fields) {
 				gomock.InOrder(
-					f.repo.EXPECT().Restore(gomock.Any(), gomock.Any()).Return("", errDumb),
+					f.repo.EXPECT().Restore(gomock.Any(), gomock.Any()).Return(storages.Link{}, errDumb),
 				)
 			},
 		},
@@ -349,6 +378,43 @@ func TestURLShortenerHandler_HandleGet(t *testing.T) {
 	}
 }
 
+func TestURLShortener_HandleGet_RecordsClick(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockRepo := mock_handlers.NewMockRepository(mockCtrl)
+	mockClicks := mock_handlers.NewMockClickRecorder(mockCtrl)
+
+	gomock.InOrder(
+		mockRepo.EXPECT().Restore(gomock.Any(), "1111").Return(storages.Link{ID: "1111", User: "xxxx", URL: "https://ya.ru"}, nil),
+		mockClicks.EXPECT().Record(gomock.Any()).Do(func(e clicks.Event) {
+			assert.Equal(t, "1111", e.ID)
+			assert.Equal(t, "xxxx", e.Owner)
+			assert.Equal(t, "https://yandex.ru/search", e.Referer)
+			assert.Equal(t, "Mozilla/5.0", e.UserAgent)
+			assert.Equal(t, "192.0.2.1", e.IP)
+			assert.Empty(t, e.User)
+			assert.False(t, e.Time.IsZero())
+		}),
+	)
+
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/1111", nil)
+	r.Header.Set("Referer", "https://yandex.ru/search")
+	r.Header.Set("User-Agent", "Mozilla/5.0")
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "1111")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+	h := NewURLShortener(baseURL, mockRepo, WithClickRecorder(mockClicks))
+	w := httptest.NewRecorder()
+	h.HandleGet(w, r)
+	result := w.Result()
+	err := result.Body.Close()
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusTemporaryRedirect, result.StatusCode)
+}
+
 func TestURLShortener_HandleDelete(t *testing.T) {
 	type fields struct {
 		repo *mock_handlers.MockRepository
@@ -410,6 +476,59 @@ func TestURLShortener_HandleDelete(t *testing.T) {
 	}
 }
 
+func TestURLShortener_HandleRestore(t *testing.T) {
+	tests := []struct {
+		name    string
+		reqBody string
+		status  int
+		want    []string
+	}{
+		{
+			name:    "valid request",
+			reqBody: `["111","222"]`,
+			status:  http.StatusAccepted,
+			want:    []string{"111", "222"},
+		},
+		{
+			name:    "invalid request",
+			reqBody: `["111","222]`,
+			status:  http.StatusBadRequest,
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockRepo := mock_handlers.NewMockRepository(mockCtrl)
+
+			// восстановление асинхронное, поэтому дожидаемся вызова репозитория
+			done := make(chan struct{})
+			if tt.want != nil {
+				mockRepo.EXPECT().Undelete(gomock.Any(), gomock.Any(), tt.want).
+					Do(func(_ context.Context, _ string, _ []string) { close(done) })
+			}
+
+			request := httptest.NewRequest(http.MethodPost, "/api/user/urls/restore", strings.NewReader(tt.reqBody))
+			w := httptest.NewRecorder()
+			h := NewURLShortener(baseURL, mockRepo)
+			h.HandleRestore(w, request)
+			result := w.Result()
+			err := result.Body.Close()
+			require.NoError(t, err)
+
+			require.Equal(t, tt.status, result.StatusCode)
+			if tt.want != nil {
+				select {
+				case <-done:
+				case <-time.After(time.Second):
+					t.Fatal("Undelete is not called")
+				}
+			}
+		})
+	}
+}
+
 // Пример использования HandleDelete
 func ExampleURLShortener_HandleDelete() {
 	reader := strings.NewReader(`["111","222"]`)
@@ -427,16 +546,21 @@ func ExampleURLShortener_HandleDelete() {
 //nolint:funlen
 func TestURLShortener_HandleGetUserURLsBucket(t *testing.T) {
 	type fields struct {
-		repo *mock_handlers.MockRepository
+		repo   *mock_handlers.MockRepository
+		clicks *mock_handlers.MockClickRecorder
 	}
 	type want struct {
 		status int
 		result string
+		link   string
 	}
+	created := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
 	tests := []struct {
-		name    string
-		want    want
-		prepare func(f *fields)
+		name       string
+		query      string
+		withClicks bool
+		want       want
+		prepare    func(f *fields)
 	}{
 		{
 			name: "single item bucket",
@@ -445,36 +569,140 @@ func TestURLShortener_HandleGetUserURLsBucket(t *testing.T) {
 				result: `[
 					  {
 					    "short_url": "http://localhost:8080/1111",
-					    "original_url": "https://ya.ru"
+					    "original_url": "https://ya.ru",
+					    "is_deleted": false
+					  }
+					]`,
+			},
+			prepare: func(f *fields) {
+				gomock.InOrder(
+					f.repo.EXPECT().GetUserLinks(gomock.Any(), gomock.Any(), storages.LinkQuery{
+						Limit:  defaultPageSize,
+						Sort:   storages.SortByCreated,
+						Status: storages.StatusAll,
+					}).Return(storages.LinkPage{Links: []storages.Link{{ID: "1111", URL: "https://ya.ru"}}}, nil),
+				)
+			},
+		},
+		{
+			name:  "couple item bucket with next page",
+			query: "?limit=2&sort=id&order=desc&domain=ya&status=active",
+			want: want{
+				status: http.StatusOK,
+				result: `[
+					  {
+					    "short_url": "http://localhost:8080/2222",
+					    "original_url": "https://yandex.ru",
+					    "is_deleted": false
+					  },
+					  {
+					    "short_url": "http://localhost:8080/1111",
+					    "original_url": "https://ya.ru",
+					    "created_at": "2022-08-01T00:00:00Z",
+					    "is_deleted": false
+					  }
+					]`,
+				link: `</api/user/urls?cursor=` + storages.Cursor{Created: created, ID: "1111"}.String() +
+					`&domain=ya&limit=2&order=desc&sort=id&status=active>; rel="next"`,
+			},
+			prepare: func(f *fields) {
+				gomock.InOrder(
+					f.repo.EXPECT().GetUserLinks(gomock.Any(), gomock.Any(), storages.LinkQuery{
+						Limit:  2,
+						Sort:   storages.SortByID,
+						Desc:   true,
+						Domain: "ya",
+						Status: storages.StatusActive,
+					}).Return(storages.LinkPage{
+						Links: []storages.Link{{ID: "2222", URL: "https://yandex.ru"}, {ID: "1111", URL: "https://ya.ru", Created: created}},
+						Next:  &storages.Cursor{Created: created, ID: "1111"},
+					}, nil),
+				)
+			},
+		},
+		{
+			name:  "filter by tag",
+			query: "?tag=%20News",
+			want: want{
+				status: http.StatusOK,
+				result: `[
+					  {
+					    "short_url": "http://localhost:8080/1111",
+					    "original_url": "https://ya.ru",
+					    "title": "Yandex",
+					    "tags": ["news", "search"],
+					    "is_deleted": false
+					  }
+					]`,
+			},
+			prepare: func(f *fields) {
+				gomock.InOrder(
+					f.repo.EXPECT().GetUserLinks(gomock.Any(), gomock.Any(), storages.LinkQuery{
+						Limit:  defaultPageSize,
+						Sort:   storages.SortByCreated,
+						Tag:    "news",
+						Status: storages.StatusAll,
+					}).Return(storages.LinkPage{Links: []storages.Link{
+						{ID: "1111", URL: "https://ya.ru", Title: "Yandex", Tags: []string{"news", "search"}},
+					}}, nil),
+				)
+			},
+		},
+		{
+			name:  "next page",
+			query: "?cursor=" + storages.Cursor{Created: created, ID: "1111"}.String(),
+			want: want{
+				status: http.StatusOK,
+				result: `[
+					  {
+					    "short_url": "http://localhost:8080/2222",
+					    "original_url": "https://yandex.ru",
+					    "is_deleted": false
 					  }
 					]`,
 			},
 			prepare: func(f *fields) {
 				gomock.InOrder(
-					f.repo.EXPECT().GetUserStorage(gomock.Any(), gomock.Any()).
-						Return(map[string]string{"1111": "https://ya.ru"}),
+					f.repo.EXPECT().GetUserLinks(gomock.Any(), gomock.Any(), storages.LinkQuery{
+						Limit:  defaultPageSize,
+						After:  &storages.Cursor{Created: created, ID: "1111"},
+						Sort:   storages.SortByCreated,
+						Status: storages.StatusAll,
+					}).Return(storages.LinkPage{Links: []storages.Link{{ID: "2222", URL: "https://yandex.ru"}}}, nil),
 				)
 			},
 		},
 		{
-			name: "couple item bucket",
+			name:       "timestamps and clicks",
+			withClicks: true,
 			want: want{
 				status: http.StatusOK,
 				result: `[
 					  {
 					    "short_url": "http://localhost:8080/1111",
-					    "original_url": "https://ya.ru"
+					    "original_url": "https://ya.ru",
+					    "created_at": "2022-08-01T00:00:00Z",
+					    "updated_at": "2022-08-02T00:00:00Z",
+					    "is_deleted": true,
+					    "clicks": 5
 					  },
 					  {
 					    "short_url": "http://localhost:8080/2222",
-					    "original_url": "https://yandex.ru"
+					    "original_url": "https://yandex.ru",
+					    "is_deleted": false,
+					    "clicks": 0
 					  }
 					]`,
 			},
 			prepare: func(f *fields) {
 				gomock.InOrder(
-					f.repo.EXPECT().GetUserStorage(gomock.Any(), gomock.Any()).
-						Return(map[string]string{"1111": "https://ya.ru", "2222": "https://yandex.ru"}),
+					f.repo.EXPECT().GetUserLinks(gomock.Any(), gomock.Any(), gomock.Any()).
+						Return(storages.LinkPage{Links: []storages.Link{
+							{ID: "1111", URL: "https://ya.ru", Created: created, Updated: created.AddDate(0, 0, 1), Deleted: true},
+							{ID: "2222", URL: "https://yandex.ru"},
+						}}, nil),
+					f.clicks.EXPECT().Totals(gomock.Any(), []string{"1111", "2222"}, time.Time{}, time.Time{}, false).
+						Return(map[string]int64{"1111": 5}, nil),
 				)
 			},
 		},
@@ -486,11 +714,31 @@ func TestURLShortener_HandleGetUserURLsBucket(t *testing.T) {
 			},
 			prepare: func(f *fields) {
 				gomock.InOrder(
-					f.repo.EXPECT().GetUserStorage(gomock.Any(), gomock.Any()).
-						Return(map[string]string{}),
+					f.repo.EXPECT().GetUserLinks(gomock.Any(), gomock.Any(), gomock.Any()).
+						Return(storages.LinkPage{Links: []storages.Link{}}, nil),
 				)
 			},
 		},
+		{
+			name:  "invalid limit",
+			query: "?limit=0",
+			want:  want{status: http.StatusBadRequest, result: ErrInvalidPageSize.Error() + "\n"},
+		},
+		{
+			name:  "invalid cursor",
+			query: "?cursor=!!!",
+			want:  want{status: http.StatusBadRequest, result: storages.ErrInvalidCursor.Error() + "\n"},
+		},
+		{
+			name:  "invalid sort",
+			query: "?sort=url",
+			want:  want{status: http.StatusBadRequest, result: ErrInvalidSort.Error() + "\n"},
+		},
+		{
+			name:  "invalid status",
+			query: "?status=hidden",
+			want:  want{status: http.StatusBadRequest, result: ErrInvalidStatus.Error() + "\n"},
+		},
 	}
 	zerolog.SetGlobalLevel(zerolog.Disabled)
 	for _, tt := range tests {
@@ -500,23 +748,28 @@ func TestURLShortener_HandleGetUserURLsBucket(t *testing.T) {
 			mockRepo := mock_handlers.NewMockRepository(mockCtrl)
 
 			f := fields{
-				repo: mockRepo,
+				repo:   mockRepo,
+				clicks: mock_handlers.NewMockClickRecorder(mockCtrl),
 			}
 			if tt.prepare != nil {
 				tt.prepare(&f)
 			}
 
-			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request := httptest.NewRequest(http.MethodGet, "/api/user/urls"+tt.query, nil)
 			w := httptest.NewRecorder()
 			h := NewURLShortener(baseURL, mockRepo)
+			if tt.withClicks {
+				h = NewURLShortener(baseURL, mockRepo, WithClickRecorder(f.clicks))
+			}
 			h.HandleGetUserURLsBucket(w, request)
 			result := w.Result()
 			assert.Equal(t, tt.want.status, result.StatusCode)
+			assert.Equal(t, tt.want.link, result.Header.Get("Link"))
 
 			buf := new(bytes.Buffer)
 			_, err := buf.ReadFrom(result.Body)
 			require.NoError(t, err)
-			if tt.want.result == "" || buf.String() == "" {
+			if tt.want.result == "" || buf.String() == "" || tt.want.status != http.StatusOK {
 				assert.EqualValues(t, tt.want.result, buf.String())
 			} else {
 				assert.JSONEq(t, tt.want.result, buf.String())
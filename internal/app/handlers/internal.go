@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+)
+
+// HandleGetInternalStats - метод для получения сводной статистики сервиса: количество ссылок, пользователей и удаленных ссылок.
+// Доступ к методу ограничивается доверенной подсетью на уровне роутера.
+func (s URLShortener) HandleGetInternalStats(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+	defer cancel()
+
+	stats, err := s.linkRepo.Stats(ctx)
+	if err != nil {
+		utils.InternalServerError(w, err)
+		return
+	}
+
+	resp := InternalStatsResponse{
+		URLs:    stats.URLs,
+		Users:   stats.Users,
+		Deleted: stats.Deleted,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(&resp)
+	if err != nil {
+		utils.InternalServerError(w, err)
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLShortener_HandleGetInternalStats(t *testing.T) {
+	type want struct {
+		status int
+		result string
+	}
+	tests := []struct {
+		name    string
+		want    want
+		prepare func(repo *mock_handlers.MockRepository)
+	}{
+		{
+			name: "stats",
+			want: want{
+				status: http.StatusOK,
+				result: `{"urls": 10, "users": 3, "deleted": 2}`,
+			},
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Stats(gomock.Any()).Return(storages.Stats{URLs: 10, Users: 3, Deleted: 2}, nil)
+			},
+		},
+		{
+			name: "storage error",
+			want: want{status: http.StatusInternalServerError},
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Stats(gomock.Any()).Return(storages.Stats{}, errors.New("db is down"))
+			},
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			tt.prepare(repo)
+
+			r := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+			h := NewURLShortener(baseURL, repo)
+			w := httptest.NewRecorder()
+			h.HandleGetInternalStats(w, r)
+			result := w.Result()
+			assert.Equal(t, tt.want.status, result.StatusCode)
+
+			buf := new(bytes.Buffer)
+			_, err := buf.ReadFrom(result.Body)
+			require.NoError(t, err)
+			if tt.want.result != "" {
+				assert.JSONEq(t, tt.want.result, buf.String())
+			}
+			err = result.Body.Close()
+			require.NoError(t, err)
+		})
+	}
+}
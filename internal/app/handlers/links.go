@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	midware "github.com/UndeadDemidov/yandex-praktikum/internal/app/middleware"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrEmptyPatch = errors.New("nothing to change")
+
+// HandlePatchUserURL - метод для изменения ранее сокращенной пользователем ссылки.
+// На вход принимается json с изменяемыми полями, в ответ отдается ссылка в новом состоянии.
+// Изменять можно только свои ссылки, новая оригинальная ссылка не должна быть уже сокращена.
+func (s URLShortener) HandlePatchUserURL(w http.ResponseWriter, r *http.Request) {
+	req := LinkPatchRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, ErrProperJSONIsExpected.Error(), http.StatusBadRequest)
+		return
+	}
+	patch := storages.LinkPatch{URL: req.URL, Title: req.Title, Tags: req.Tags, Notes: req.Notes}
+	if req.Rules != nil {
+		rules := newRules(*req.Rules)
+		patch.Rules = &rules
+	}
+	if patch.NotBefore, err = parseWindowBound("not_before", req.NotBefore); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if patch.NotAfter, err = parseWindowBound("not_after", req.NotAfter); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if patch.IsEmpty() {
+		http.Error(w, ErrEmptyPatch.Error(), http.StatusBadRequest)
+		return
+	}
+	if patch.URL != nil && !utils.IsURL(*patch.URL) {
+		http.Error(w, fmt.Sprintf("Hey, Dude! Provide a link! Not the crap: %v", *patch.URL), http.StatusBadRequest)
+		log.Debug().Msg(fmt.Sprintf("User provided data: %v", *patch.URL))
+		return
+	}
+	if patch.Tags != nil {
+		tags := normalizeTags(*patch.Tags)
+		patch.Tags = &tags
+	}
+	if err = validatePatchMeta(patch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+	defer cancel()
+
+	if patch.URL != nil {
+		dest := storages.Link{URL: *patch.URL}
+		if err = s.prepareDestination(ctx, &dest); err != nil {
+			http.Error(w, err.Error(), destinationErrorStatus(err))
+			return
+		}
+		// при сохранении конца цепочки перенаправлений меняется и сама оригинальная ссылка
+		patch.URL = &dest.URL
+		patch.Canonical = &dest.Canonical
+	}
+	if patch.Rules != nil {
+		if err = s.prepareRules(ctx, *patch.Rules); err != nil {
+			http.Error(w, err.Error(), destinationErrorStatus(err))
+			return
+		}
+	}
+
+	id := chi.URLParam(r, "id")
+	user := midware.GetUserID(ctx)
+	link, err := s.linkRepo.Update(ctx, user, id, patch)
+	switch {
+	case errors.Is(err, ErrLinkIsNotFound):
+		http.Error(w, fmt.Sprintf("link %s is not found", id), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrLinkIsDeleted):
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	case errors.Is(err, ErrInvalidWindow):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, ErrLinkIsAlreadyShortened):
+		msg := err.Error()
+		if link.ID != "" {
+			msg = fmt.Sprintf("%s as %s%s", msg, s.baseURL, link.ID)
+		}
+		http.Error(w, msg, http.StatusConflict)
+		return
+	case err != nil:
+		utils.InternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode((*LinksToBucket(s.baseURL, []storages.Link{link}, nil))[0])
+	if err != nil {
+		utils.InternalServerError(w, err)
+	}
+}
+
+// validatePatchMeta проверяет ограничения только для изменяемых полей.
+// Окно активности здесь проверяется, только если изменяются обе его границы,
+// с сохраненной границей его сверяет хранилище в Update.
+func validatePatchMeta(patch storages.LinkPatch) error {
+	if patch.Rules != nil {
+		if err := validateRules(*patch.Rules); err != nil {
+			return err
+		}
+	}
+	var (
+		title, notes string
+		tags         []string
+	)
+	if patch.NotBefore != nil && patch.NotAfter != nil {
+		if err := validateWindow(*patch.NotBefore, *patch.NotAfter); err != nil {
+			return err
+		}
+	}
+	if patch.Title != nil {
+		title = *patch.Title
+	}
+	if patch.Tags != nil {
+		tags = *patch.Tags
+	}
+	if patch.Notes != nil {
+		notes = *patch.Notes
+	}
+	return validateMeta(title, tags, notes)
+}
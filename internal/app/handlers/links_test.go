@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//nolint:funlen
+func TestURLShortener_HandlePatchUserURL(t *testing.T) {
+	type want struct {
+		status int
+		result string
+	}
+	newURL := "https://go.dev"
+	newCanonical := "https://go.dev/"
+	created := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		body    string
+		want    want
+		prepare func(repo *mock_handlers.MockRepository)
+	}{
+		{
+			name: "new destination",
+			body: `{"url": "https://go.dev"}`,
+			want: want{
+				status: http.StatusOK,
+				result: `{
+					"short_url": "http://localhost:8080/1111",
+					"original_url": "https://go.dev",
+					"created_at": "2022-08-01T00:00:00Z",
+					"updated_at": "2022-08-02T00:00:00Z",
+					"is_deleted": false
+				}`,
+			},
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Update(gomock.Any(), gomock.Any(), "1111", storages.LinkPatch{URL: &newURL, Canonical: &newCanonical}).
+					Return(storages.Link{ID: "1111", URL: newURL, Created: created, Updated: created.AddDate(0, 0, 1)}, nil)
+			},
+		},
+		{
+			name: "metadata",
+			body: `{"title": "Go", "tags": [" Lang", "go", "lang", ""]}`,
+			want: want{
+				status: http.StatusOK,
+				result: `{
+					"short_url": "http://localhost:8080/1111",
+					"original_url": "https://go.dev",
+					"title": "Go",
+					"tags": ["lang", "go"],
+					"is_deleted": false
+				}`,
+			},
+			prepare: func(repo *mock_handlers.MockRepository) {
+				title, tags := "Go", []string{"lang", "go"}
+				repo.EXPECT().Update(gomock.Any(), gomock.Any(), "1111", storages.LinkPatch{Title: &title, Tags: &tags}).
+					Return(storages.Link{ID: "1111", URL: newURL, Title: title, Tags: tags}, nil)
+			},
+		},
+		{
+			name: "activity window",
+			body: `{"not_before": "2022-09-01T03:00:00+03:00", "not_after": ""}`,
+			want: want{
+				status: http.StatusOK,
+				result: `{
+					"short_url": "http://localhost:8080/1111",
+					"original_url": "https://go.dev",
+					"not_before": "2022-09-01T00:00:00Z",
+					"is_deleted": false
+				}`,
+			},
+			prepare: func(repo *mock_handlers.MockRepository) {
+				launch, unset := created.AddDate(0, 1, 0), time.Time{}
+				repo.EXPECT().Update(gomock.Any(), gomock.Any(), "1111", storages.LinkPatch{NotBefore: &launch, NotAfter: &unset}).
+					Return(storages.Link{ID: "1111", URL: newURL, NotBefore: launch}, nil)
+			},
+		},
+		{
+			name: "rules",
+			body: `{"rules": [{"platform": "Android", "url": "https://play.google.com/store"}]}`,
+			want: want{
+				status: http.StatusOK,
+				result: `{
+					"short_url": "http://localhost:8080/1111",
+					"original_url": "https://go.dev",
+					"rules": [{"platform": "android", "url": "https://play.google.com/store"}],
+					"is_deleted": false
+				}`,
+			},
+			prepare: func(repo *mock_handlers.MockRepository) {
+				rules := []storages.Rule{{Platform: "android", URL: "https://play.google.com/store"}}
+				repo.EXPECT().Update(gomock.Any(), gomock.Any(), "1111", storages.LinkPatch{Rules: &rules}).
+					Return(storages.Link{ID: "1111", URL: newURL, Rules: rules}, nil)
+			},
+		},
+		{
+			name: "rule without conditions",
+			body: `{"rules": [{"url": "https://play.google.com/store"}]}`,
+			want: want{status: http.StatusBadRequest, result: ErrInvalidRule.Error() + "\n"},
+		},
+		{
+			name: "empty activity window",
+			body: `{"not_before": "2022-09-01T00:00:00Z", "not_after": "2022-09-01T00:00:00Z"}`,
+			want: want{status: http.StatusBadRequest, result: ErrInvalidWindow.Error() + "\n"},
+		},
+		{
+			name: "activity window emptied with stored bound",
+			body: `{"not_before": "2022-09-01T00:00:00Z"}`,
+			want: want{status: http.StatusBadRequest, result: ErrInvalidWindow.Error() + "\n"},
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Update(gomock.Any(), gomock.Any(), "1111", gomock.Any()).Return(storages.Link{}, ErrInvalidWindow)
+			},
+		},
+		{
+			name: "malformed activity window",
+			body: `{"not_after": "tomorrow"}`,
+			want: want{status: http.StatusBadRequest, result: "not_after must be RFC 3339 time: tomorrow\n"},
+		},
+		{
+			name: "too many tags",
+			body: `{"tags": ["1","2","3","4","5","6","7","8","9","10","11","12","13","14","15","16","17","18","19","20","21"]}`,
+			want: want{status: http.StatusBadRequest, result: ErrTooManyTags.Error() + "\n"},
+		},
+		{
+			name: "already shortened",
+			body: `{"url": "https://go.dev"}`,
+			want: want{
+				status: http.StatusConflict,
+				result: "link is already shortened as http://localhost:8080/2222\n",
+			},
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Update(gomock.Any(), gomock.Any(), "1111", gomock.Any()).
+					Return(storages.Link{ID: "2222", URL: newURL}, ErrLinkIsAlreadyShortened)
+			},
+		},
+		{
+			name: "foreign link",
+			body: `{"url": "https://go.dev"}`,
+			want: want{status: http.StatusNotFound, result: "link 1111 is not found\n"},
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Update(gomock.Any(), gomock.Any(), "1111", gomock.Any()).
+					Return(storages.Link{}, ErrLinkIsNotFound)
+			},
+		},
+		{
+			name: "deleted link",
+			body: `{"url": "https://go.dev"}`,
+			want: want{status: http.StatusGone, result: "link is deleted\n"},
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Update(gomock.Any(), gomock.Any(), "1111", gomock.Any()).
+					Return(storages.Link{}, ErrLinkIsDeleted)
+			},
+		},
+		{
+			name: "not a link",
+			body: `{"url": "go.dev"}`,
+			want: want{status: http.StatusBadRequest, result: "Hey, Dude! Provide a link! Not the crap: go.dev\n"},
+		},
+		{
+			name: "empty patch",
+			body: `{}`,
+			want: want{status: http.StatusBadRequest, result: ErrEmptyPatch.Error() + "\n"},
+		},
+		{
+			name: "corrupted json",
+			body: `{"url":`,
+			want: want{status: http.StatusBadRequest, result: ErrProperJSONIsExpected.Error() + "\n"},
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			if tt.prepare != nil {
+				tt.prepare(repo)
+			}
+
+			r := httptest.NewRequest(http.MethodPatch, "/api/user/urls/1111", strings.NewReader(tt.body))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "1111")
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+			h := NewURLShortener(baseURL, repo)
+			w := httptest.NewRecorder()
+			h.HandlePatchUserURL(w, r)
+			result := w.Result()
+			assert.Equal(t, tt.want.status, result.StatusCode)
+
+			buf := new(bytes.Buffer)
+			_, err := buf.ReadFrom(result.Body)
+			require.NoError(t, err)
+			if tt.want.status == http.StatusOK {
+				assert.JSONEq(t, tt.want.result, buf.String())
+			} else {
+				assert.Equal(t, tt.want.result, buf.String())
+			}
+			err = result.Body.Close()
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/golang/mock/gomock"
+)
+
+// linkMatching сравнивает сохраняемую ссылку функцией match, desc описывает ожидание в сообщениях gomock.
+func linkMatching(desc string, match func(storages.Link) bool) gomock.Matcher {
+	return linkMatcher{desc: desc, match: match}
+}
+
+type linkMatcher struct {
+	desc  string
+	match func(storages.Link) bool
+}
+
+func (m linkMatcher) Matches(x interface{}) bool {
+	link, ok := x.(storages.Link)
+	return ok && m.match(link)
+}
+
+func (m linkMatcher) String() string {
+	return m.desc
+}
+
+// linkWithURL сравнивает только оригинальную ссылку сохраняемой записи.
+func linkWithURL(url string) gomock.Matcher {
+	return linkMatching("link to "+url, func(l storages.Link) bool {
+		return l.URL == url
+	})
+}
+
+// linkWithMaxClicks сравнивает только ограничение переходов сохраняемой ссылки.
+func linkWithMaxClicks(n int) gomock.Matcher {
+	return linkMatching(fmt.Sprintf("link with max_clicks %d", n), func(l storages.Link) bool {
+		return l.MaxClicks == n
+	})
+}
+
+// linkWithWindow сравнивает только окно активности сохраняемой ссылки.
+func linkWithWindow(notBefore, notAfter time.Time) gomock.Matcher {
+	return linkMatching(fmt.Sprintf("link with activity window from %s to %s", notBefore, notAfter), func(l storages.Link) bool {
+		return l.NotBefore.Equal(notBefore) && l.NotAfter.Equal(notAfter)
+	})
+}
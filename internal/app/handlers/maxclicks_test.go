@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLShortener_MaxClicks(t *testing.T) {
+	limited := storages.Link{ID: "1111", URL: "https://ya.ru", Redirect: http.StatusMovedPermanently, MaxClicks: 3, RemainingClicks: 1}
+	exhausted := limited
+	exhausted.RemainingClicks = 0
+
+	tests := []struct {
+		name     string
+		method   string
+		link     storages.Link
+		status   int
+		location string
+		prepare  func(repo *mock_handlers.MockRepository)
+	}{
+		{
+			name:     "last click",
+			method:   http.MethodGet,
+			link:     limited,
+			status:   http.StatusMovedPermanently,
+			location: "https://ya.ru",
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().ConsumeClick(gomock.Any(), "1111").Return(0, nil)
+			},
+		},
+		{
+			name:   "consumed concurrently",
+			method: http.MethodGet,
+			link:   limited,
+			status: http.StatusGone,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().ConsumeClick(gomock.Any(), "1111").Return(0, ErrLinkIsExhausted)
+			},
+		},
+		{
+			name:   "storage error",
+			method: http.MethodGet,
+			link:   limited,
+			status: http.StatusInternalServerError,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().ConsumeClick(gomock.Any(), "1111").Return(0, errors.New("db is down"))
+			},
+		},
+		{
+			name:   "exhausted",
+			method: http.MethodGet,
+			link:   exhausted,
+			status: http.StatusGone,
+		},
+		{
+			name:     "head does not consume",
+			method:   http.MethodHead,
+			link:     limited,
+			status:   http.StatusMovedPermanently,
+			location: "https://ya.ru",
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			repo.EXPECT().Restore(gomock.Any(), "1111").Return(tt.link, nil)
+			if tt.prepare != nil {
+				tt.prepare(repo)
+			}
+
+			h := NewURLShortener(baseURL, repo)
+			r := chi.NewRouter()
+			r.Get("/{id}", h.HandleGet)
+			r.Head("/{id}", h.HandleGet)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, "/1111", nil))
+			result := w.Result()
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, tt.status, result.StatusCode)
+			assert.Equal(t, tt.location, result.Header.Get("Location"))
+			if tt.location != "" {
+				// ограниченные переходы не кэшируются даже при постоянном перенаправлении
+				assert.Equal(t, "private, no-cache", result.Header.Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestURLShortener_ShortenWithMaxClicks(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	repo := mock_handlers.NewMockRepository(mockCtrl)
+	repo.EXPECT().Store(gomock.Any(), gomock.Any(), linkWithMaxClicks(1)).Return("1111", nil)
+
+	h := NewURLShortener(baseURL, repo)
+	for body, status := range map[string]int{
+		`{"url": "https://ya.ru", "max_clicks": 1}`:  http.StatusCreated,
+		`{"url": "https://ya.ru", "max_clicks": -1}`: http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		h.HandlePostShortenJSON(w, httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body)))
+		result := w.Result()
+		require.NoError(t, result.Body.Close())
+		assert.Equal(t, status, result.StatusCode)
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+)
+
+const (
+	maxTitleLength = 256
+	maxNotesLength = 4096
+	maxTagsCount   = 20
+	maxTagLength   = 64
+)
+
+var (
+	ErrTitleIsTooLong   = errors.New("title must be at most 256 characters")
+	ErrNotesAreTooLong  = errors.New("notes must be at most 4096 characters")
+	ErrTooManyTags      = errors.New("at most 20 tags are allowed")
+	ErrTagIsTooLong     = errors.New("tag must be at most 64 characters")
+	ErrInvalidMaxClicks = errors.New("max_clicks must not be negative")
+)
+
+// normalizeTags приводит метки к нижнему регистру, обрезает пробелы,
+// отбрасывает пустые и повторяющиеся, сохраняя порядок первого вхождения.
+func normalizeTags(tags []string) []string {
+	norm := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		norm = append(norm, t)
+	}
+	return norm
+}
+
+// validateLink проверяет параметры создаваемой ссылки, кроме самой оригинальной ссылки.
+// Метки должны быть уже нормализованы.
+func validateLink(link storages.Link) error {
+	if err := validateMeta(link.Title, link.Tags, link.Notes); err != nil {
+		return err
+	}
+	if link.MaxClicks < 0 {
+		return ErrInvalidMaxClicks
+	}
+	if err := validateWindow(link.NotBefore, link.NotAfter); err != nil {
+		return err
+	}
+	if err := validateVariants(link.Variants); err != nil {
+		return err
+	}
+	if err := validateRules(link.Rules); err != nil {
+		return err
+	}
+	return validateRedirect(link.Redirect)
+}
+
+// validateMeta проверяет ограничения на размер описания, меток и заметок.
+// Метки должны быть уже нормализованы.
+func validateMeta(title string, tags []string, notes string) error {
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return ErrTitleIsTooLong
+	}
+	if utf8.RuneCountInString(notes) > maxNotesLength {
+		return ErrNotesAreTooLong
+	}
+	if len(tags) > maxTagsCount {
+		return ErrTooManyTags
+	}
+	for _, t := range tags {
+		if utf8.RuneCountInString(t) > maxTagLength {
+			return ErrTagIsTooLong
+		}
+	}
+	return nil
+}
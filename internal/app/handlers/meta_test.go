@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_normalizeTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+		want []string
+	}{
+		{name: "nil", tags: nil, want: []string{}},
+		{name: "trim and lower", tags: []string{" Go ", "NEWS"}, want: []string{"go", "news"}},
+		{name: "drop empty", tags: []string{"", "  ", "go"}, want: []string{"go"}},
+		{name: "dedupe keeps first", tags: []string{"news", "Go", "go", "NEWS"}, want: []string{"news", "go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, normalizeTags(tt.tags))
+		})
+	}
+}
+
+func Test_validateMeta(t *testing.T) {
+	tooManyTags := make([]string, maxTagsCount+1)
+	for i := range tooManyTags {
+		tooManyTags[i] = strings.Repeat("a", i+1)
+	}
+	tests := []struct {
+		name  string
+		title string
+		tags  []string
+		notes string
+		want  error
+	}{
+		{name: "empty", want: nil},
+		{name: "at limits", title: strings.Repeat("я", maxTitleLength), tags: tooManyTags[:maxTagsCount],
+			notes: strings.Repeat("n", maxNotesLength), want: nil},
+		{name: "long title", title: strings.Repeat("я", maxTitleLength+1), want: ErrTitleIsTooLong},
+		{name: "long notes", notes: strings.Repeat("n", maxNotesLength+1), want: ErrNotesAreTooLong},
+		{name: "too many tags", tags: tooManyTags, want: ErrTooManyTags},
+		{name: "long tag", tags: []string{strings.Repeat("t", maxTagLength+1)}, want: ErrTagIsTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, validateMeta(tt.title, tt.tags, tt.notes))
+		})
+	}
+}
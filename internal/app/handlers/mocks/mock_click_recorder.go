@@ -0,0 +1,94 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers (interfaces: ClickRecorder)
+
+// Package mock_handlers is a generated GoMock package.
+package mock_handlers
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	clicks "github.com/UndeadDemidov/yandex-praktikum/internal/app/clicks"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockClickRecorder is a mock of ClickRecorder interface.
+type MockClickRecorder struct {
+	ctrl     *gomock.Controller
+	recorder *MockClickRecorderMockRecorder
+}
+
+// MockClickRecorderMockRecorder is the mock recorder for MockClickRecorder.
+type MockClickRecorderMockRecorder struct {
+	mock *MockClickRecorder
+}
+
+// NewMockClickRecorder creates a new mock instance.
+func NewMockClickRecorder(ctrl *gomock.Controller) *MockClickRecorder {
+	mock := &MockClickRecorder{ctrl: ctrl}
+	mock.recorder = &MockClickRecorderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockClickRecorder) EXPECT() *MockClickRecorderMockRecorder {
+	return m.recorder
+}
+
+// Record mocks base method.
+func (m *MockClickRecorder) Record(arg0 clicks.Event) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Record", arg0)
+}
+
+// Record indicates an expected call of Record.
+func (mr *MockClickRecorderMockRecorder) Record(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Record", reflect.TypeOf((*MockClickRecorder)(nil).Record), arg0)
+}
+
+// Series mocks base method.
+func (m *MockClickRecorder) Series(arg0 context.Context, arg1 string, arg2 clicks.Granularity, arg3, arg4 time.Time, arg5 bool) ([]clicks.Bucket, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Series", arg0, arg1, arg2, arg3, arg4, arg5)
+	ret0, _ := ret[0].([]clicks.Bucket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Series indicates an expected call of Series.
+func (mr *MockClickRecorderMockRecorder) Series(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Series", reflect.TypeOf((*MockClickRecorder)(nil).Series), arg0, arg1, arg2, arg3, arg4, arg5)
+}
+
+// Top mocks base method.
+func (m *MockClickRecorder) Top(arg0 context.Context, arg1 string, arg2 int, arg3, arg4 time.Time, arg5 bool) ([]clicks.Total, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Top", arg0, arg1, arg2, arg3, arg4, arg5)
+	ret0, _ := ret[0].([]clicks.Total)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Top indicates an expected call of Top.
+func (mr *MockClickRecorderMockRecorder) Top(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Top", reflect.TypeOf((*MockClickRecorder)(nil).Top), arg0, arg1, arg2, arg3, arg4, arg5)
+}
+
+// Totals mocks base method.
+func (m *MockClickRecorder) Totals(arg0 context.Context, arg1 []string, arg2, arg3 time.Time, arg4 bool) (map[string]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Totals", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(map[string]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Totals indicates an expected call of Totals.
+func (mr *MockClickRecorderMockRecorder) Totals(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Totals", reflect.TypeOf((*MockClickRecorder)(nil).Totals), arg0, arg1, arg2, arg3, arg4)
+}
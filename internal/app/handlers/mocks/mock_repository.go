@@ -8,6 +8,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	storages "github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -48,18 +49,64 @@ func (mr *MockRepositoryMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockRepository)(nil).Close))
 }
 
-// GetUserStorage mocks base method.
-func (m *MockRepository) GetUserStorage(arg0 context.Context, arg1 string) map[string]string {
+// ConsumeClick mocks base method.
+func (m *MockRepository) ConsumeClick(arg0 context.Context, arg1 string) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserStorage", arg0, arg1)
+	ret := m.ctrl.Call(m, "ConsumeClick", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeClick indicates an expected call of ConsumeClick.
+func (mr *MockRepositoryMockRecorder) ConsumeClick(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeClick", reflect.TypeOf((*MockRepository)(nil).ConsumeClick), arg0, arg1)
+}
+
+// CountLinks mocks base method.
+func (m *MockRepository) CountLinks(arg0 context.Context, arg1 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountLinks", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountLinks indicates an expected call of CountLinks.
+func (mr *MockRepositoryMockRecorder) CountLinks(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountLinks", reflect.TypeOf((*MockRepository)(nil).CountLinks), arg0, arg1)
+}
+
+// FindShortened mocks base method.
+func (m *MockRepository) FindShortened(arg0 context.Context, arg1 []string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindShortened", arg0, arg1)
 	ret0, _ := ret[0].(map[string]string)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindShortened indicates an expected call of FindShortened.
+func (mr *MockRepositoryMockRecorder) FindShortened(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindShortened", reflect.TypeOf((*MockRepository)(nil).FindShortened), arg0, arg1)
 }
 
-// GetUserStorage indicates an expected call of GetUserStorage.
-func (mr *MockRepositoryMockRecorder) GetUserStorage(arg0, arg1 interface{}) *gomock.Call {
+// GetUserLinks mocks base method.
+func (m *MockRepository) GetUserLinks(arg0 context.Context, arg1 string, arg2 storages.LinkQuery) (storages.LinkPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserLinks", arg0, arg1, arg2)
+	ret0, _ := ret[0].(storages.LinkPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserLinks indicates an expected call of GetUserLinks.
+func (mr *MockRepositoryMockRecorder) GetUserLinks(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserStorage", reflect.TypeOf((*MockRepository)(nil).GetUserStorage), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserLinks", reflect.TypeOf((*MockRepository)(nil).GetUserLinks), arg0, arg1, arg2)
 }
 
 // Ping mocks base method.
@@ -77,10 +124,10 @@ func (mr *MockRepositoryMockRecorder) Ping(arg0 interface{}) *gomock.Call {
 }
 
 // Restore mocks base method.
-func (m *MockRepository) Restore(arg0 context.Context, arg1 string) (string, error) {
+func (m *MockRepository) Restore(arg0 context.Context, arg1 string) (storages.Link, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Restore", arg0, arg1)
-	ret0, _ := ret[0].(string)
+	ret0, _ := ret[0].(storages.Link)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -91,8 +138,37 @@ func (mr *MockRepositoryMockRecorder) Restore(arg0, arg1 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockRepository)(nil).Restore), arg0, arg1)
 }
 
+// SetBlocked mocks base method.
+func (m *MockRepository) SetBlocked(arg0 context.Context, arg1 string, arg2 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBlocked", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetBlocked indicates an expected call of SetBlocked.
+func (mr *MockRepositoryMockRecorder) SetBlocked(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBlocked", reflect.TypeOf((*MockRepository)(nil).SetBlocked), arg0, arg1, arg2)
+}
+
+// Stats mocks base method.
+func (m *MockRepository) Stats(arg0 context.Context) (storages.Stats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats", arg0)
+	ret0, _ := ret[0].(storages.Stats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockRepositoryMockRecorder) Stats(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockRepository)(nil).Stats), arg0)
+}
+
 // Store mocks base method.
-func (m *MockRepository) Store(arg0 context.Context, arg1, arg2 string) (string, error) {
+func (m *MockRepository) Store(arg0 context.Context, arg1 string, arg2 storages.Link) (string, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Store", arg0, arg1, arg2)
 	ret0, _ := ret[0].(string)
@@ -107,7 +183,7 @@ func (mr *MockRepositoryMockRecorder) Store(arg0, arg1, arg2 interface{}) *gomoc
 }
 
 // StoreBatch mocks base method.
-func (m *MockRepository) StoreBatch(arg0 context.Context, arg1 string, arg2 map[string]string) (map[string]string, error) {
+func (m *MockRepository) StoreBatch(arg0 context.Context, arg1 string, arg2 map[string]storages.Link) (map[string]string, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "StoreBatch", arg0, arg1, arg2)
 	ret0, _ := ret[0].(map[string]string)
@@ -121,6 +197,18 @@ func (mr *MockRepositoryMockRecorder) StoreBatch(arg0, arg1, arg2 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreBatch", reflect.TypeOf((*MockRepository)(nil).StoreBatch), arg0, arg1, arg2)
 }
 
+// Undelete mocks base method.
+func (m *MockRepository) Undelete(arg0 context.Context, arg1 string, arg2 []string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Undelete", arg0, arg1, arg2)
+}
+
+// Undelete indicates an expected call of Undelete.
+func (mr *MockRepositoryMockRecorder) Undelete(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Undelete", reflect.TypeOf((*MockRepository)(nil).Undelete), arg0, arg1, arg2)
+}
+
 // Unstore mocks base method.
 func (m *MockRepository) Unstore(arg0 context.Context, arg1 string, arg2 []string) {
 	m.ctrl.T.Helper()
@@ -132,3 +220,18 @@ func (mr *MockRepositoryMockRecorder) Unstore(arg0, arg1, arg2 interface{}) *gom
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unstore", reflect.TypeOf((*MockRepository)(nil).Unstore), arg0, arg1, arg2)
 }
+
+// Update mocks base method.
+func (m *MockRepository) Update(arg0 context.Context, arg1, arg2 string, arg3 storages.LinkPatch) (storages.Link, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(storages.Link)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockRepositoryMockRecorder) Update(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockRepository)(nil).Update), arg0, arg1, arg2, arg3)
+}
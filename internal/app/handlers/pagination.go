@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+var (
+	ErrInvalidPageSize = errors.New("limit must be a positive number")
+	ErrInvalidSort     = errors.New("sort must be created or id")
+	ErrInvalidOrder    = errors.New("order must be asc or desc")
+	ErrInvalidStatus   = errors.New("status must be all, active or deleted")
+)
+
+// parseLinkQuery читает из параметров запроса параметры выборки страницы ссылок:
+// limit (по умолчанию 100, не более 1000), cursor, sort (created или id), order (asc или desc),
+// domain - подстрока домена, tag - метка, status (all, active или deleted).
+func parseLinkQuery(q url.Values) (lq storages.LinkQuery, err error) {
+	lq = storages.LinkQuery{
+		Limit:  defaultPageSize,
+		Sort:   storages.SortByCreated,
+		Status: storages.StatusAll,
+		Domain: q.Get("domain"),
+		Tag:    strings.ToLower(strings.TrimSpace(q.Get("tag"))),
+	}
+	if v := q.Get("limit"); v != "" {
+		lq.Limit, err = strconv.Atoi(v)
+		if err != nil || lq.Limit <= 0 {
+			return storages.LinkQuery{}, ErrInvalidPageSize
+		}
+		if lq.Limit > maxPageSize {
+			lq.Limit = maxPageSize
+		}
+	}
+	if v := q.Get("cursor"); v != "" {
+		lq.After, err = storages.ParseCursor(v)
+		if err != nil {
+			return storages.LinkQuery{}, err
+		}
+	}
+	if v := q.Get("sort"); v != "" {
+		switch lq.Sort = storages.LinkSort(v); lq.Sort {
+		case storages.SortByCreated, storages.SortByID:
+		default:
+			return storages.LinkQuery{}, ErrInvalidSort
+		}
+	}
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		lq.Desc = true
+	default:
+		return storages.LinkQuery{}, ErrInvalidOrder
+	}
+	if v := q.Get("status"); v != "" {
+		switch lq.Status = storages.LinkStatus(v); lq.Status {
+		case storages.StatusAll, storages.StatusActive, storages.StatusDeleted:
+		default:
+			return storages.LinkQuery{}, ErrInvalidStatus
+		}
+	}
+	return lq, nil
+}
+
+// nextPageLink возвращает ссылку на следующую страницу: тот же запрос с курсором next.
+func nextPageLink(u *url.URL, next *storages.Cursor) string {
+	q := u.Query()
+	q.Set("cursor", next.String())
+	return (&url.URL{Path: u.Path, RawQuery: q.Encode()}).String()
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	midware "github.com/UndeadDemidov/yandex-praktikum/internal/app/middleware"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/password"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+)
+
+const (
+	defaultPasswordAttempts = 5
+	defaultPasswordLockout  = 15 * time.Minute
+	// maxPasswordFormSize - ограничение тела формы ввода пароля
+	maxPasswordFormSize = 4096
+)
+
+var ErrTooManyPasswordAttempts = errors.New("too many wrong passwords, try again later")
+
+var passwordTemplate = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex, nofollow">
+<title>Password required</title>
+</head>
+<body>
+<h1>Password required</h1>
+<p>This short link is protected with a password.</p>
+{{if .}}<p role="alert">Wrong password, try again.</p>
+{{end}}<form method="post">
+<input type="password" name="password" autocomplete="current-password" autofocus required>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// WithPasswordAttempts задает ограничение неудачных попыток ввода пароля ссылок.
+// По умолчанию каждому клиенту на каждой ссылке разрешается 5 неудачных попыток за 15 минут.
+func WithPasswordAttempts(l *password.Limiter) Option {
+	return func(s *URLShortener) {
+		s.attempts = l
+	}
+}
+
+// protect сохраняет в ссылке соленый хэш пароля, пустой пароль означает ссылку без пароля.
+func protect(link *storages.Link, pass string) (err error) {
+	if pass == "" {
+		return nil
+	}
+	link.PasswordHash, err = password.Hash(pass)
+	return err
+}
+
+// unlock проверяет доступ к ссылке с паролем. На GET и HEAD отдается форма ввода пароля,
+// пароль из формы принимается POST запросом на тот же адрес, поэтому параметры запроса и хвост пути сохраняются.
+// Если доступ не получен, то ответ уже записан в w и возвращается false.
+func (s URLShortener) unlock(w http.ResponseWriter, r *http.Request, link storages.Link) bool {
+	if r.Method != http.MethodPost {
+		renderPasswordForm(w, http.StatusOK, false)
+		return false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasswordFormSize)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, fmt.Sprintf("password form is expected: %v", err), http.StatusBadRequest)
+		return false
+	}
+	// попытки считаются по ссылке и адресу клиента, чтобы перебор с одного адреса
+	// не закрывал ссылку для остальных посетителей
+	key := link.ID + "|" + midware.ClientIP(r)
+	if retry, ok := s.attempts.Reserve(key); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+		http.Error(w, ErrTooManyPasswordAttempts.Error(), http.StatusTooManyRequests)
+		return false
+	}
+	if !password.Verify(link.PasswordHash, r.PostForm.Get("password")) {
+		renderPasswordForm(w, http.StatusForbidden, true)
+		return false
+	}
+	s.attempts.Reset(key)
+	return true
+}
+
+// renderPasswordForm отвечает формой ввода пароля, failed - предыдущий пароль не подошел.
+func renderPasswordForm(w http.ResponseWriter, status int, failed bool) {
+	buf := bytes.Buffer{}
+	if err := passwordTemplate.Execute(&buf, failed); err != nil {
+		utils.InternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		utils.InternalServerError(w, err)
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/password"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLShortener_PasswordProtected(t *testing.T) {
+	hash, err := password.Hash("s3cret")
+	require.NoError(t, err)
+	link := storages.Link{ID: "1111", URL: "https://ya.ru", PasswordHash: hash, ForwardQuery: true}
+
+	type attempt struct {
+		// remote - адрес клиента, по умолчанию адрес httptest
+		remote   string
+		method   string
+		target   string
+		password string
+		status   int
+		location string
+		contains string
+	}
+	tests := []struct {
+		name     string
+		attempts []attempt
+		clicks   int
+	}{
+		{
+			name: "form",
+			attempts: []attempt{
+				{method: http.MethodGet, target: "/1111", status: http.StatusOK, contains: `name="password"`},
+			},
+		},
+		{
+			name: "right password",
+			attempts: []attempt{
+				{method: http.MethodPost, target: "/1111?q=go", password: "s3cret",
+					status: http.StatusSeeOther, location: "https://ya.ru?q=go"},
+			},
+			clicks: 1,
+		},
+		{
+			name: "wrong password",
+			attempts: []attempt{
+				{method: http.MethodPost, target: "/1111", password: "secret", status: http.StatusForbidden, contains: "Wrong password"},
+				{method: http.MethodPost, target: "/1111", password: "s3cret", status: http.StatusSeeOther, location: "https://ya.ru"},
+			},
+			clicks: 1,
+		},
+		{
+			name: "too many attempts",
+			attempts: []attempt{
+				{method: http.MethodPost, target: "/1111", status: http.StatusForbidden},
+				{method: http.MethodPost, target: "/1111", password: "secret", status: http.StatusForbidden},
+				{method: http.MethodPost, target: "/1111", password: "s3cret", status: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "other client is not locked out",
+			attempts: []attempt{
+				{method: http.MethodPost, target: "/1111", status: http.StatusForbidden},
+				{method: http.MethodPost, target: "/1111", status: http.StatusForbidden},
+				{method: http.MethodPost, target: "/1111", status: http.StatusTooManyRequests},
+				{remote: "198.51.100.7:4321", method: http.MethodPost, target: "/1111", password: "s3cret",
+					status: http.StatusSeeOther, location: "https://ya.ru"},
+			},
+			clicks: 1,
+		},
+		{
+			name: "preview",
+			attempts: []attempt{
+				{method: http.MethodGet, target: "/1111+", status: http.StatusOK, contains: `name="password"`},
+				{method: http.MethodPost, target: "/1111+", password: "s3cret", status: http.StatusOK, contains: "https://ya.ru"},
+			},
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			repo.EXPECT().Restore(gomock.Any(), "1111").Return(link, nil).Times(len(tt.attempts))
+			recorder := mock_handlers.NewMockClickRecorder(mockCtrl)
+			recorder.EXPECT().Record(gomock.Any()).Times(tt.clicks)
+
+			h := NewURLShortener(baseURL, repo, WithClickRecorder(recorder),
+				WithPasswordAttempts(password.NewLimiter(2, time.Minute)))
+			r := chi.NewRouter()
+			r.Get("/{id}", h.HandleGet)
+			r.Post("/{id}", h.HandleGet)
+			for _, a := range tt.attempts {
+				form := url.Values{"password": {a.password}}
+				req := httptest.NewRequest(a.method, a.target, strings.NewReader(form.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				if a.remote != "" {
+					req.RemoteAddr = a.remote
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				result := w.Result()
+				body, err := io.ReadAll(result.Body)
+				require.NoError(t, err)
+				require.NoError(t, result.Body.Close())
+
+				require.Equal(t, a.status, result.StatusCode)
+				assert.Equal(t, a.location, result.Header.Get("Location"))
+				assert.Contains(t, string(body), a.contains)
+				if a.status == http.StatusTooManyRequests {
+					assert.Equal(t, "60", result.Header.Get("Retry-After"))
+				}
+			}
+		})
+	}
+}
+
+func TestURLShortener_ShortenWithPassword(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	repo := mock_handlers.NewMockRepository(mockCtrl)
+	repo.EXPECT().Store(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ interface{}, _ string, link storages.Link) (string, error) {
+			assert.NotContains(t, link.PasswordHash, "s3cret")
+			assert.True(t, password.Verify(link.PasswordHash, "s3cret"))
+			return "1111", nil
+		})
+
+	h := NewURLShortener(baseURL, repo)
+	for body, status := range map[string]int{
+		`{"url": "https://ya.ru", "password": "s3cret"}`:                           http.StatusCreated,
+		`{"url": "https://ya.ru", "password": "` + strings.Repeat("p", 129) + `"}`: http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		h.HandlePostShortenJSON(w, httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body)))
+		result := w.Result()
+		require.NoError(t, result.Body.Close())
+		assert.Equal(t, status, result.StatusCode)
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/chain"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/policy"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+)
+
+// WithDestinationPolicy задает правила для оригинальных ссылок, по умолчанию используется policy.Default.
+// Ссылки на базовый адрес сервиса запрещены всегда.
+func WithDestinationPolicy(p policy.Policy) Option {
+	return func(s *URLShortener) {
+		s.policy = p
+	}
+}
+
+// WithRedirectChain включает проход цепочки перенаправлений оригинальной ссылки при сокращении, по умолчанию выключен.
+// Каждый адрес цепочки проверяется по правилам сервиса, ссылки с петлями отклоняются.
+// При storeFinal сохраняется конечный адрес цепочки вместо переданного.
+func WithRedirectChain(f *chain.Follower, storeFinal bool) Option {
+	return func(s *URLShortener) {
+		s.chain = f
+		s.storeFinal = storeFinal
+	}
+}
+
+// prepareDestination проходит цепочку перенаправлений, если это включено, приводит оригинальную ссылку
+// к канонической форме и проверяет ее по правилам сервиса.
+// Вызывается после всех изменений оригинальной ссылки, статус ответа на ошибку см. destinationErrorStatus.
+// У ссылки с вариантами так готовится каждый вариант, см. prepareVariants.
+func (s URLShortener) prepareDestination(ctx context.Context, link *storages.Link) error {
+	if len(link.Variants) != 0 {
+		return s.prepareVariants(ctx, link)
+	}
+	if s.chain != nil {
+		final, err := s.chain.Resolve(ctx, link.URL, s.policy.Check)
+		if err != nil {
+			return err
+		}
+		if s.storeFinal {
+			link.URL = final
+		}
+	}
+	if err := s.canonicalize(link); err != nil {
+		return err
+	}
+	return s.policy.Check(ctx, link.DedupKey())
+}
+
+// destinationErrorStatus возвращает статус ответа на ошибку prepareDestination:
+// 422 при нарушении правил сервиса или петле перенаправлений и 400 для некорректной ссылки.
+func destinationErrorStatus(err error) int {
+	if errors.Is(err, policy.ErrDestinationNotAllowed) || chain.IsChainError(err) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusBadRequest
+}
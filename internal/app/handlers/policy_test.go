@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/policy"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLShortener_DestinationPolicy(t *testing.T) {
+	ftp := policy.Default()
+	ftp.Schemes = append(ftp.Schemes, "ftp")
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		body    string
+		opts    []Option
+		status  int
+		result  string
+		prepare func(repo *mock_handlers.MockRepository)
+	}{
+		{
+			name:   "plain scheme",
+			method: http.MethodPost,
+			target: "/",
+			body:   "ftp://ftp.example/pub",
+			status: http.StatusUnprocessableEntity,
+			result: "destination is not allowed: scheme is not allowed: ftp\n",
+		},
+		{
+			name:   "plain scheme allowed",
+			method: http.MethodPost,
+			target: "/",
+			body:   "ftp://ftp.example/pub",
+			opts:   []Option{WithDestinationPolicy(ftp)},
+			status: http.StatusCreated,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Store(gomock.Any(), gomock.Any(), gomock.Any()).Return("1111", nil)
+			},
+		},
+		{
+			name:   "json self reference",
+			method: http.MethodPost,
+			target: "/api/shorten",
+			body:   `{"url": "http://LOCALHOST:8080/2222"}`,
+			opts:   []Option{WithDestinationPolicy(policy.Policy{})},
+			status: http.StatusUnprocessableEntity,
+			result: "destination is not allowed: link points to the shortener itself: http://localhost:8080/\n",
+		},
+		{
+			name:   "batch private address",
+			method: http.MethodPost,
+			target: "/api/shorten/batch",
+			body: `[{"correlation_id": "1", "original_url": "https://ya.ru"},
+					{"correlation_id": "2", "original_url": "http://192.168.0.1/admin"}]`,
+			status: http.StatusUnprocessableEntity,
+			result: "correlation_id 2: destination is not allowed: private or loopback address: 192.168.0.1\n",
+		},
+		{
+			name:   "patch denied domain",
+			method: http.MethodPatch,
+			target: "/api/user/urls/1111",
+			body:   `{"url": "https://www.evil.example/"}`,
+			opts:   []Option{WithDestinationPolicy(policy.Policy{Deny: []string{"*.evil.example"}})},
+			status: http.StatusUnprocessableEntity,
+			result: "destination is not allowed: domain is not allowed: www.evil.example\n",
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			if tt.prepare != nil {
+				tt.prepare(repo)
+			}
+
+			h := NewURLShortener(baseURL, repo, tt.opts...)
+			r := chi.NewRouter()
+			r.Post("/", h.HandlePostShortenPlain)
+			r.Post("/api/shorten", h.HandlePostShortenJSON)
+			r.Post("/api/shorten/batch", h.HandlePostShortenBatch)
+			r.Patch("/api/user/urls/{id}", h.HandlePatchUserURL)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+			result := w.Result()
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, tt.status, result.StatusCode)
+			if tt.result != "" {
+				assert.Equal(t, tt.result, string(body))
+			}
+		})
+	}
+}
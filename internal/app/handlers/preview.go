@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+)
+
+// previewSuffix - суффикс короткого ID, запрашивающий страницу предпросмотра вместо перехода.
+// Сгенерированные ID его не содержат.
+const previewSuffix = "+"
+
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex, nofollow">
+<title>{{if .Title}}{{.Title}}{{else}}Link preview{{end}}</title>
+</head>
+<body>
+<h1>{{if .Title}}{{.Title}}{{else}}Link preview{{end}}</h1>
+<p>This short link leads to:</p>
+<p><code>{{.Destination}}</code></p>
+{{if not .Created.IsZero}}<p>Created <time datetime="{{.Created.Format "2006-01-02T15:04:05Z07:00"}}">{{.Created.Format "2 Jan 2006"}}</time></p>
+{{end}}<p><a href="{{.Destination}}" rel="noopener noreferrer nofollow">Continue to the site</a></p>
+</body>
+</html>
+`))
+
+// preview - данные страницы предпросмотра.
+type preview struct {
+	Title       string
+	Destination string
+	Created     time.Time
+}
+
+// previewRequested возвращает короткий ID без суффикса предпросмотра и признак того,
+// что вместо перехода запрошена страница предпросмотра: /{id}+ или ?preview=1.
+func previewRequested(r *http.Request, id string) (string, bool) {
+	if strings.HasSuffix(id, previewSuffix) {
+		return strings.TrimSuffix(id, previewSuffix), true
+	}
+	return id, r.URL.Query().Get("preview") == "1"
+}
+
+// renderPreview отвечает страницей с адресом назначения, датой создания и названием ссылки.
+// Переход по ссылке при этом не учитывается в статистике.
+func renderPreview(w http.ResponseWriter, link storages.Link, dest string) {
+	buf := bytes.Buffer{}
+	err := previewTemplate.Execute(&buf, preview{Title: link.Title, Destination: dest, Created: link.Created})
+	if err != nil {
+		utils.InternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		utils.InternalServerError(w, err)
+	}
+}
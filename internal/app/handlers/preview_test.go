@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLShortener_HandleGetPreview(t *testing.T) {
+	created := time.Date(2022, 5, 17, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		target     string
+		link       storages.Link
+		restoreErr error
+		status     int
+		contains   []string
+		excludes   []string
+	}{
+		{
+			name:   "plus suffix",
+			target: "/1111+",
+			link:   storages.Link{URL: "https://ya.ru/docs", Title: "Docs", Created: created},
+			status: http.StatusOK,
+			contains: []string{
+				"<title>Docs</title>",
+				`<a href="https://ya.ru/docs"`,
+				`datetime="2022-05-17T10:00:00Z"`,
+				"17 May 2022",
+			},
+		},
+		{
+			name:     "query param with forwarding",
+			target:   "/1111/a?preview=1&utm_source=mail",
+			link:     storages.Link{URL: "https://ya.ru/docs", ForwardQuery: true, ForwardPath: true},
+			status:   http.StatusOK,
+			contains: []string{"<title>Link preview</title>", "https://ya.ru/docs/a?utm_source=mail"},
+			excludes: []string{"preview=1", "<time"},
+		},
+		{
+			name:     "title is escaped",
+			target:   "/1111+",
+			link:     storages.Link{URL: "javascript:alert(1)", Title: "<script>alert(1)</script>"},
+			status:   http.StatusOK,
+			contains: []string{"&lt;script&gt;", `href="#ZgotmplZ"`},
+			excludes: []string{"<script>"},
+		},
+		{
+			name:       "deleted link",
+			target:     "/1111+",
+			restoreErr: ErrLinkIsDeleted,
+			status:     http.StatusGone,
+		},
+		{
+			name:   "blocked link",
+			target: "/1111+",
+			link:   storages.Link{URL: "https://ya.ru", Blocked: true},
+			status: http.StatusUnavailableForLegalReasons,
+		},
+		{
+			name:       "unknown link",
+			target:     "/1111?preview=1",
+			restoreErr: fmt.Errorf(storages.ErrLinkNotFound, ErrLinkIsNotFound, "1111"),
+			status:     http.StatusNotFound,
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			repo.EXPECT().Restore(gomock.Any(), "1111").Return(tt.link, tt.restoreErr)
+			// предпросмотр не считается переходом
+			recorder := mock_handlers.NewMockClickRecorder(mockCtrl)
+
+			h := NewURLShortener(baseURL, repo, WithClickRecorder(recorder))
+			r := chi.NewRouter()
+			r.Get("/{id}", h.HandleGet)
+			r.Get("/{id}/*", h.HandleGet)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			result := w.Result()
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+
+			require.Equal(t, tt.status, result.StatusCode)
+			assert.Empty(t, result.Header.Get("Location"))
+			if tt.status != http.StatusOK {
+				return
+			}
+			assert.Equal(t, "text/html; charset=utf-8", result.Header.Get("Content-Type"))
+			for _, s := range tt.contains {
+				assert.Contains(t, string(body), s)
+			}
+			for _, s := range tt.excludes {
+				assert.NotContains(t, string(body), s)
+			}
+		})
+	}
+}
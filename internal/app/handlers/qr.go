@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/qr"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultQRSize   = 256
+	minQRSize       = 64
+	maxQRSize       = 2048
+	defaultQRMargin = 4
+	maxQRMargin     = 16
+)
+
+var (
+	ErrInvalidQRFormat = errors.New("format must be png or svg")
+	ErrInvalidQRSize   = fmt.Errorf("size must be a number from %d to %d", minQRSize, maxQRSize)
+	ErrInvalidQRMargin = fmt.Errorf("margin must be a number from 0 to %d", maxQRMargin)
+)
+
+// qrParams - параметры отрисовки QR кода из строки запроса.
+type qrParams struct {
+	format string
+	level  qr.Level
+	size   int
+	margin int
+}
+
+// parseQRParams читает параметры format (png, svg), ec (L, M, Q, H), size в пикселях и margin в модулях.
+// Отсутствующие параметры принимают значения по умолчанию: png, M, 256 и 4.
+func parseQRParams(r *http.Request) (qrParams, error) {
+	q := r.URL.Query()
+	p := qrParams{format: "png", level: qr.M, size: defaultQRSize, margin: defaultQRMargin}
+
+	if f := q.Get("format"); f != "" {
+		if f != "png" && f != "svg" {
+			return qrParams{}, ErrInvalidQRFormat
+		}
+		p.format = f
+	}
+	if ec := q.Get("ec"); ec != "" {
+		level, err := qr.ParseLevel(ec)
+		if err != nil {
+			return qrParams{}, err
+		}
+		p.level = level
+	}
+	if v := q.Get("size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < minQRSize || size > maxQRSize {
+			return qrParams{}, ErrInvalidQRSize
+		}
+		p.size = size
+	}
+	if v := q.Get("margin"); v != "" {
+		margin, err := strconv.Atoi(v)
+		if err != nil || margin < 0 || margin > maxQRMargin {
+			return qrParams{}, ErrInvalidQRMargin
+		}
+		p.margin = margin
+	}
+	return p, nil
+}
+
+// HandleGetQR - метод для получения QR кода полной короткой ссылки в PNG или SVG.
+// Для удаленной ссылки возвращается 410, для неизвестной - 404, как и при переходе по ссылке.
+// Метод занимает хвост пути qr, поэтому он не пробрасывается в оригинальную ссылку даже при ForwardPath.
+func (s URLShortener) HandleGetQR(w http.ResponseWriter, r *http.Request) {
+	p, err := parseQRParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+	defer cancel()
+
+	if _, ok := s.restoreLink(ctx, w, id); !ok {
+		return
+	}
+
+	code, err := qr.Encode([]byte(s.baseURL+id), p.level)
+	if err != nil {
+		utils.InternalServerError(w, err)
+		return
+	}
+	buf := bytes.Buffer{}
+	contentType := "image/png"
+	if p.format == "svg" {
+		contentType = "image/svg+xml"
+		err = code.SVG(&buf, p.size, p.margin)
+	} else {
+		err = code.PNG(&buf, p.size, p.margin)
+	}
+	if err != nil {
+		utils.InternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		utils.InternalServerError(w, err)
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLShortener_HandleGetQR(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		restoreErr  error
+		noRestore   bool
+		status      int
+		contentType string
+	}{
+		{
+			name:        "png by default",
+			target:      "/1111/qr",
+			status:      http.StatusOK,
+			contentType: "image/png",
+		},
+		{
+			name:        "svg",
+			target:      "/1111/qr?format=svg&ec=H&size=512&margin=0",
+			status:      http.StatusOK,
+			contentType: "image/svg+xml",
+		},
+		{
+			name:       "unknown link",
+			target:     "/1111/qr",
+			restoreErr: fmt.Errorf(storages.ErrLinkNotFound, ErrLinkIsNotFound, "1111"),
+			status:     http.StatusNotFound,
+		},
+		{
+			name:       "deleted link",
+			target:     "/1111/qr",
+			restoreErr: ErrLinkIsDeleted,
+			status:     http.StatusGone,
+		},
+		{
+			name:      "invalid format",
+			target:    "/1111/qr?format=gif",
+			noRestore: true,
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "invalid level",
+			target:    "/1111/qr?ec=X",
+			noRestore: true,
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "too large",
+			target:    "/1111/qr?size=4096",
+			noRestore: true,
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "invalid margin",
+			target:    "/1111/qr?margin=-1",
+			noRestore: true,
+			status:    http.StatusBadRequest,
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			if !tt.noRestore {
+				repo.EXPECT().Restore(gomock.Any(), "1111").Return(storages.Link{ID: "1111", URL: "https://ya.ru"}, tt.restoreErr)
+			}
+
+			h := NewURLShortener(baseURL, repo)
+			r := chi.NewRouter()
+			// хвост qr зарезервирован и не уходит в проброс пути
+			r.Get("/{id}/*", h.HandleGet)
+			r.Get("/{id}/qr", h.HandleGetQR)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			result := w.Result()
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+
+			require.Equal(t, tt.status, result.StatusCode)
+			if tt.status != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.contentType, result.Header.Get("Content-Type"))
+			if tt.contentType == "image/png" {
+				img, err := png.Decode(bytes.NewReader(body))
+				require.NoError(t, err)
+				assert.LessOrEqual(t, img.Bounds().Dx(), defaultQRSize)
+			} else {
+				assert.Contains(t, string(body), `width="512"`)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	ErrLinkQuotaExceeded = errors.New("link quota is exceeded")
+	ErrBatchIsTooLarge   = errors.New("batch is too large")
+	ErrInvalidQuota      = errors.New("links and batch must not be negative")
+)
+
+// Quota - ограничения пользователя: Links - сколько активных ссылок ему можно иметь,
+// Batch - сколько ссылок можно передать в одном пакете. 0 - без ограничения.
+type Quota struct {
+	Links int
+	Batch int
+}
+
+// Quotas - ограничения по умолчанию и ограничения отдельных пользователей, назначенные администратором.
+// Ограничение пользователя заменяет ограничение по умолчанию целиком, в том числе 0 в нем снимает ограничение.
+type Quotas struct {
+	Default Quota
+	Users   map[string]Quota
+}
+
+// quotaBook - ограничения пользователей, которые администратор может менять на ходу, см. HandlePutQuota.
+// Изменения живут до перезапуска, постоянные ограничения задаются в конфигурации.
+type quotaBook struct {
+	quotas Quotas
+	mx     sync.RWMutex
+}
+
+// newQuotaBook создает quotaBook с копией ограничений q, чтобы изменения не затрагивали исходную map.
+func newQuotaBook(q Quotas) *quotaBook {
+	users := make(map[string]Quota, len(q.Users))
+	for user, quota := range q.Users {
+		users[user] = quota
+	}
+	return &quotaBook{quotas: Quotas{Default: q.Default, Users: users}}
+}
+
+// of возвращает ограничения пользователя user и признак того, что они назначены ему отдельно.
+func (b *quotaBook) of(user string) (Quota, bool) {
+	b.mx.RLock()
+	defer b.mx.RUnlock()
+
+	if quota, ok := b.quotas.Users[user]; ok {
+		return quota, true
+	}
+	return b.quotas.Default, false
+}
+
+// set назначает пользователю отдельные ограничения.
+func (b *quotaBook) set(user string, quota Quota) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	b.quotas.Users[user] = quota
+}
+
+// reset возвращает пользователю ограничения по умолчанию.
+func (b *quotaBook) reset(user string) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	delete(b.quotas.Users, user)
+}
+
+// WithQuotas задает ограничения на количество ссылок пользователей и размер пакета.
+func WithQuotas(q Quotas) Option {
+	return func(s *URLShortener) {
+		s.quotas = newQuotaBook(q)
+	}
+}
+
+// HandleGetQuota - метод администратора для получения ограничений пользователя.
+// Доступ к методу ограничивается доверенной подсетью на уровне роутера.
+func (s URLShortener) HandleGetQuota(w http.ResponseWriter, r *http.Request) {
+	s.writeQuota(w, chi.URLParam(r, "user"))
+}
+
+// HandlePutQuota - метод администратора для назначения пользователю отдельных ограничений,
+// например чтобы разрешить ему больше ссылок. 0 снимает ограничение.
+// Доступ к методу ограничивается доверенной подсетью на уровне роутера.
+func (s URLShortener) HandlePutQuota(w http.ResponseWriter, r *http.Request) {
+	req := QuotaRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, ErrProperJSONIsExpected.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Links < 0 || req.Batch < 0 {
+		http.Error(w, ErrInvalidQuota.Error(), http.StatusBadRequest)
+		return
+	}
+	user := chi.URLParam(r, "user")
+	s.quotas.set(user, Quota{Links: req.Links, Batch: req.Batch})
+	s.writeQuota(w, user)
+}
+
+// HandleDeleteQuota - метод администратора для возврата пользователю ограничений по умолчанию.
+// Доступ к методу ограничивается доверенной подсетью на уровне роутера.
+func (s URLShortener) HandleDeleteQuota(w http.ResponseWriter, r *http.Request) {
+	s.quotas.reset(chi.URLParam(r, "user"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeQuota отвечает действующими ограничениями пользователя.
+func (s URLShortener) writeQuota(w http.ResponseWriter, user string) {
+	quota, overridden := s.quotas.of(user)
+	resp := QuotaResponse{User: user, Links: quota.Links, Batch: quota.Batch, Overridden: overridden}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(&resp); err != nil {
+		utils.InternalServerError(w, err)
+	}
+}
+
+// QuotaError - превышение ограничения пользователя с подробностями для ответа.
+type QuotaError struct {
+	err error
+	// Limit - ограничение, Used - сколько уже использовано, Requested - сколько запрошено
+	Limit     int
+	Used      int
+	Requested int
+}
+
+func (e *QuotaError) Error() string {
+	if errors.Is(e.err, ErrBatchIsTooLarge) {
+		return fmt.Sprintf("%s: %d links are provided, at most %d are allowed", e.err, e.Requested, e.Limit)
+	}
+	return fmt.Sprintf("%s: %d of %d active links are used, %d more are requested", e.err, e.Used, e.Limit, e.Requested)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return e.err
+}
+
+// quota возвращает действующие ограничения пользователя.
+func (s URLShortener) quota(user string) Quota {
+	quota, _ := s.quotas.of(user)
+	return quota
+}
+
+// checkBatch проверяет, что пакет из n ссылок не больше разрешенного пользователю.
+func (s URLShortener) checkBatch(user string, n int) error {
+	limit := s.quota(user).Batch
+	if limit > 0 && n > limit {
+		return &QuotaError{err: ErrBatchIsTooLarge, Limit: limit, Requested: n}
+	}
+	return nil
+}
+
+// checkQuota проверяет, что у пользователя останется не больше разрешенного количества активных ссылок,
+// если сохранить links. Ссылки должны быть уже подготовлены, см. prepareDestination: ранее сокращенные ссылки
+// и повторы внутри запроса ищутся по ключу уникальности и не учитываются, на них по-прежнему отдается 409.
+// Проверка и сохранение не атомарны, поэтому параллельные запросы могут превысить ограничение на несколько ссылок.
+func (s URLShortener) checkQuota(ctx context.Context, user string, links []storages.Link) error {
+	limit := s.quota(user).Links
+	if limit <= 0 || len(links) == 0 {
+		return nil
+	}
+	used, err := s.linkRepo.CountLinks(ctx, user)
+	if err != nil || used+len(links) <= limit {
+		return err
+	}
+
+	keys := make([]string, 0, len(links))
+	seen := make(map[string]bool, len(links))
+	for _, l := range links {
+		if key := l.DedupKey(); !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	found, err := s.linkRepo.FindShortened(ctx, keys)
+	if err != nil {
+		return err
+	}
+	return quotaExceeded(limit, used, len(keys)-len(found))
+}
+
+// checkRestoreQuota проверяет ограничение количества активных ссылок перед восстановлением ids.
+// Учитываются только удаленные ссылки самого пользователя, остальные id восстановлению не подлежат.
+func (s URLShortener) checkRestoreQuota(ctx context.Context, user string, ids []string) error {
+	limit := s.quota(user).Links
+	if limit <= 0 || len(ids) == 0 {
+		return nil
+	}
+	used, err := s.linkRepo.CountLinks(ctx, user)
+	if err != nil || used+len(ids) <= limit {
+		return err
+	}
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	deleted := 0
+	q := storages.LinkQuery{Limit: maxPageSize, Sort: storages.SortByID, Status: storages.StatusDeleted}
+	for len(wanted) != 0 {
+		page, err := s.linkRepo.GetUserLinks(ctx, user, q)
+		if err != nil {
+			return err
+		}
+		for _, l := range page.Links {
+			if wanted[l.ID] {
+				delete(wanted, l.ID)
+				deleted++
+			}
+		}
+		if page.Next == nil {
+			break
+		}
+		q.After = page.Next
+	}
+	return quotaExceeded(limit, used, deleted)
+}
+
+// quotaExceeded возвращает QuotaError, если к used активным ссылкам нельзя добавить еще n.
+func quotaExceeded(limit, used, n int) error {
+	if n > 0 && used+n > limit {
+		return &QuotaError{err: ErrLinkQuotaExceeded, Limit: limit, Used: used, Requested: n}
+	}
+	return nil
+}
+
+// quotaFailed отдает ответ на ошибку проверки ограничений: 403 при превышении количества ссылок,
+// 413 при слишком большом пакете и 500 при ошибке хранилища.
+func quotaFailed(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrBatchIsTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, ErrLinkQuotaExceeded):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		utils.InternalServerError(w, err)
+	}
+}
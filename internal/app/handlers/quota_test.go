@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	midware "github.com/UndeadDemidov/yandex-praktikum/internal/app/middleware"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLShortener_Quotas(t *testing.T) {
+	const batch = `[{"correlation_id": "1", "original_url": "https://ya.ru"}, {"correlation_id": "2", "original_url": "https://go.dev"}]`
+	// восстановление асинхронное, поэтому дожидаемся вызова репозитория
+	undeleted := make(chan struct{})
+	deletedLinks := storages.LinkQuery{Limit: maxPageSize, Sort: storages.SortByID, Status: storages.StatusDeleted}
+	quotas := Quotas{
+		Default: Quota{Links: 10, Batch: 1},
+		Users:   map[string]Quota{"vip": {Links: 0, Batch: 2}, "team": {Links: 3, Batch: 5}},
+	}
+
+	tests := []struct {
+		name    string
+		user    string
+		handler func(s *URLShortener) http.HandlerFunc
+		body    string
+		prepare func(repo *mock_handlers.MockRepository)
+		status  int
+		result  string
+		done    chan struct{}
+	}{
+		{
+			name:    "link within quota",
+			user:    "xxxx",
+			handler: func(s *URLShortener) http.HandlerFunc { return s.HandlePostShortenJSON },
+			body:    `{"url": "https://ya.ru"}`,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().CountLinks(gomock.Any(), "xxxx").Return(9, nil)
+				repo.EXPECT().Store(gomock.Any(), "xxxx", gomock.Any()).Return("1111", nil)
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name:    "link over quota",
+			user:    "xxxx",
+			handler: func(s *URLShortener) http.HandlerFunc { return s.HandlePostShortenPlain },
+			body:    "https://ya.ru",
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().CountLinks(gomock.Any(), "xxxx").Return(10, nil)
+				repo.EXPECT().FindShortened(gomock.Any(), []string{"https://ya.ru/"}).Return(map[string]string{}, nil)
+			},
+			status: http.StatusForbidden,
+			result: "link quota is exceeded: 10 of 10 active links are used, 1 more are requested\n",
+		},
+		{
+			name:    "already shortened link at quota",
+			user:    "xxxx",
+			handler: func(s *URLShortener) http.HandlerFunc { return s.HandlePostShortenJSON },
+			body:    `{"url": "https://ya.ru"}`,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().CountLinks(gomock.Any(), "xxxx").Return(10, nil)
+				repo.EXPECT().FindShortened(gomock.Any(), []string{"https://ya.ru/"}).
+					Return(map[string]string{"https://ya.ru/": "1111"}, nil)
+				repo.EXPECT().Store(gomock.Any(), "xxxx", gomock.Any()).Return("1111", ErrLinkIsAlreadyShortened)
+			},
+			status: http.StatusConflict,
+		},
+		{
+			name:    "unlimited links of overridden user",
+			user:    "vip",
+			handler: func(s *URLShortener) http.HandlerFunc { return s.HandlePostShortenJSON },
+			body:    `{"url": "https://ya.ru"}`,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Store(gomock.Any(), "vip", gomock.Any()).Return("1111", nil)
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name:    "storage error",
+			user:    "xxxx",
+			handler: func(s *URLShortener) http.HandlerFunc { return s.HandlePostShortenJSON },
+			body:    `{"url": "https://ya.ru"}`,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().CountLinks(gomock.Any(), "xxxx").Return(0, errors.New("connection refused"))
+			},
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:    "batch is too large",
+			user:    "xxxx",
+			handler: func(s *URLShortener) http.HandlerFunc { return s.HandlePostShortenBatch },
+			body:    batch,
+			status:  http.StatusRequestEntityTooLarge,
+			result:  "batch is too large: 2 links are provided, at most 1 are allowed\n",
+		},
+		{
+			name:    "batch of overridden user",
+			user:    "vip",
+			handler: func(s *URLShortener) http.HandlerFunc { return s.HandlePostShortenBatch },
+			body:    batch,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().StoreBatch(gomock.Any(), "vip", gomock.Any()).
+					Return(map[string]string{"1": "1111", "2": "2222"}, nil)
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name:    "batch with already shortened link at quota",
+			user:    "team",
+			handler: func(s *URLShortener) http.HandlerFunc { return s.HandlePostShortenBatch },
+			body:    batch,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().CountLinks(gomock.Any(), "team").Return(2, nil)
+				repo.EXPECT().FindShortened(gomock.Any(), gomock.Len(2)).
+					Return(map[string]string{"https://ya.ru/": "1111"}, nil)
+				repo.EXPECT().StoreBatch(gomock.Any(), "team", gomock.Any()).
+					Return(map[string]string{"1": "1111", "2": "2222"}, ErrLinkIsAlreadyShortened)
+			},
+			status: http.StatusConflict,
+		},
+		{
+			name:    "batch over quota",
+			user:    "team",
+			handler: func(s *URLShortener) http.HandlerFunc { return s.HandlePostShortenBatch },
+			body:    batch,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().CountLinks(gomock.Any(), "team").Return(2, nil)
+				repo.EXPECT().FindShortened(gomock.Any(), gomock.Len(2)).Return(map[string]string{}, nil)
+			},
+			status: http.StatusForbidden,
+			result: "link quota is exceeded: 2 of 3 active links are used, 2 more are requested\n",
+		},
+		{
+			name:    "restore over quota",
+			user:    "xxxx",
+			handler: func(s *URLShortener) http.HandlerFunc { return s.HandleRestore },
+			body:    `["1111", "2222", "3333"]`,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().CountLinks(gomock.Any(), "xxxx").Return(9, nil)
+				repo.EXPECT().GetUserLinks(gomock.Any(), "xxxx", deletedLinks).
+					Return(storages.LinkPage{Links: []storages.Link{{ID: "1111"}, {ID: "2222"}, {ID: "4444"}}}, nil)
+			},
+			status: http.StatusForbidden,
+			result: "link quota is exceeded: 9 of 10 active links are used, 2 more are requested\n",
+		},
+		{
+			name:    "restore of links that are not deleted",
+			user:    "xxxx",
+			handler: func(s *URLShortener) http.HandlerFunc { return s.HandleRestore },
+			body:    `["1111", "2222", "3333"]`,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().CountLinks(gomock.Any(), "xxxx").Return(9, nil)
+				repo.EXPECT().GetUserLinks(gomock.Any(), "xxxx", deletedLinks).
+					Return(storages.LinkPage{Links: []storages.Link{{ID: "1111"}}}, nil)
+				repo.EXPECT().Undelete(gomock.Any(), "xxxx", []string{"1111", "2222", "3333"}).
+					Do(func(_ context.Context, _ string, _ []string) { close(undeleted) })
+			},
+			status: http.StatusAccepted,
+			done:   undeleted,
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			if tt.prepare != nil {
+				tt.prepare(repo)
+			}
+
+			h := NewURLShortener(baseURL, repo, WithQuotas(quotas))
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), midware.ContextUserIDKey, tt.user))
+			w := httptest.NewRecorder()
+			tt.handler(h)(w, req)
+			result := w.Result()
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, tt.status, result.StatusCode)
+			if tt.result != "" {
+				assert.Equal(t, tt.result, string(body))
+			}
+			if tt.done != nil {
+				select {
+				case <-tt.done:
+				case <-time.After(time.Second):
+					t.Fatal("Undelete is not called")
+				}
+			}
+		})
+	}
+}
+
+func TestURLShortener_QuotaOverride(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	repo := mock_handlers.NewMockRepository(mockCtrl)
+	users := map[string]Quota{"vip": {Links: 100}}
+	h := NewURLShortener(baseURL, repo, WithQuotas(Quotas{Default: Quota{Links: 10, Batch: 5}, Users: users}))
+	r := chi.NewRouter()
+	r.Get("/api/internal/quotas/{user}", h.HandleGetQuota)
+	r.Put("/api/internal/quotas/{user}", h.HandlePutQuota)
+	r.Delete("/api/internal/quotas/{user}", h.HandleDeleteQuota)
+
+	steps := []struct {
+		method string
+		user   string
+		body   string
+		status int
+		result string
+	}{
+		{method: http.MethodGet, user: "xxxx", status: http.StatusOK,
+			result: `{"user": "xxxx", "links": 10, "batch": 5, "overridden": false}`},
+		{method: http.MethodGet, user: "vip", status: http.StatusOK,
+			result: `{"user": "vip", "links": 100, "batch": 0, "overridden": true}`},
+		{method: http.MethodPut, user: "xxxx", body: `{"links": 1000, "batch": 50}`, status: http.StatusOK,
+			result: `{"user": "xxxx", "links": 1000, "batch": 50, "overridden": true}`},
+		{method: http.MethodPut, user: "xxxx", body: `{"links": -1}`, status: http.StatusBadRequest},
+		{method: http.MethodPut, user: "xxxx", body: `links`, status: http.StatusBadRequest},
+		{method: http.MethodDelete, user: "vip", status: http.StatusNoContent},
+		{method: http.MethodGet, user: "vip", status: http.StatusOK,
+			result: `{"user": "vip", "links": 10, "batch": 5, "overridden": false}`},
+	}
+	for _, step := range steps {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(step.method, "/api/internal/quotas/"+step.user, strings.NewReader(step.body)))
+		result := w.Result()
+		body, err := io.ReadAll(result.Body)
+		require.NoError(t, err)
+		require.NoError(t, result.Body.Close())
+
+		assert.Equal(t, step.status, result.StatusCode, step.method+" "+step.user)
+		if step.result != "" {
+			assert.JSONEq(t, step.result, string(body))
+		}
+	}
+	// назначенные на ходу ограничения сразу действуют при проверке, а исходная map не меняется
+	assert.Equal(t, Quota{Links: 1000, Batch: 50}, h.quota("xxxx"))
+	assert.Equal(t, map[string]Quota{"vip": {Links: 100}}, users)
+}
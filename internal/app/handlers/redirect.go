@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultRedirectCode = http.StatusTemporaryRedirect
+	// permanentRedirectMaxAge - сколько клиенты и прокси могут кэшировать постоянное перенаправление.
+	// Переходы из кэша до сервиса не доходят и в журнал переходов не попадают.
+	permanentRedirectMaxAge = 24 * time.Hour
+)
+
+var ErrInvalidRedirect = errors.New("redirect must be 301, 302, 307 or 308")
+
+// IsRedirectCode проверяет, что code - один из поддерживаемых кодов перенаправления.
+func IsRedirectCode(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// WithDefaultRedirect задает код перенаправления для ссылок, у которых он не выбран при создании.
+// Неподдерживаемый код игнорируется, остается 307.
+func WithDefaultRedirect(code int) Option {
+	return func(s *URLShortener) {
+		if !IsRedirectCode(code) {
+			log.Warn().Msgf("default redirect %d is not supported, %d will be used", code, s.redirect)
+			return
+		}
+		s.redirect = code
+	}
+}
+
+// validateRedirect проверяет код перенаправления из запроса, 0 - код по умолчанию сервиса.
+func validateRedirect(code int) error {
+	if code != 0 && !IsRedirectCode(code) {
+		return ErrInvalidRedirect
+	}
+	return nil
+}
+
+// redirectCode возвращает код перенаправления ссылки или код по умолчанию сервиса.
+func (s URLShortener) redirectCode(code int) int {
+	if code == 0 {
+		return s.redirect
+	}
+	return code
+}
+
+// cacheControl возвращает значение Cache-Control для кода перенаправления:
+// постоянные перенаправления можно кэшировать, временные - нет.
+func cacheControl(code int) string {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+		return fmt.Sprintf("public, max-age=%d", int(permanentRedirectMaxAge.Seconds()))
+	default:
+		return "private, no-cache"
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLShortener_HandleGetRedirectCode(t *testing.T) {
+	type want struct {
+		status       int
+		cacheControl string
+	}
+	tests := []struct {
+		name     string
+		redirect int
+		opts     []Option
+		want     want
+	}{
+		{
+			name: "service default",
+			want: want{status: http.StatusTemporaryRedirect, cacheControl: "private, no-cache"},
+		},
+		{
+			name: "configured default",
+			opts: []Option{WithDefaultRedirect(http.StatusFound)},
+			want: want{status: http.StatusFound, cacheControl: "private, no-cache"},
+		},
+		{
+			name: "unsupported configured default",
+			opts: []Option{WithDefaultRedirect(http.StatusSeeOther)},
+			want: want{status: http.StatusTemporaryRedirect, cacheControl: "private, no-cache"},
+		},
+		{
+			name:     "link moved permanently",
+			redirect: http.StatusMovedPermanently,
+			opts:     []Option{WithDefaultRedirect(http.StatusFound)},
+			want:     want{status: http.StatusMovedPermanently, cacheControl: "public, max-age=86400"},
+		},
+		{
+			name:     "link permanent redirect",
+			redirect: http.StatusPermanentRedirect,
+			want:     want{status: http.StatusPermanentRedirect, cacheControl: "public, max-age=86400"},
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			repo.EXPECT().Restore(gomock.Any(), "1111").
+				Return(storages.Link{ID: "1111", URL: "https://ya.ru", Redirect: tt.redirect}, nil)
+
+			r := httptest.NewRequest(http.MethodGet, "/1111", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "1111")
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+			h := NewURLShortener(baseURL, repo, tt.opts...)
+			w := httptest.NewRecorder()
+			h.HandleGet(w, r)
+			result := w.Result()
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, tt.want.status, result.StatusCode)
+			assert.Equal(t, "https://ya.ru", result.Header.Get("Location"))
+			assert.Equal(t, tt.want.cacheControl, result.Header.Get("Cache-Control"))
+		})
+	}
+}
+
+func TestURLShortener_HandlePostShortenJSONRedirect(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		prepare func(repo *mock_handlers.MockRepository)
+	}{
+		{
+			name:   "chosen redirect",
+			body:   `{"url": "https://ya.ru", "redirect": 308}`,
+			status: http.StatusCreated,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Store(gomock.Any(), gomock.Any(), storages.Link{URL: "https://ya.ru", Canonical: "https://ya.ru/", Tags: []string{}, Redirect: 308}).
+					Return("1111", nil)
+			},
+		},
+		{
+			name:   "unsupported redirect",
+			body:   `{"url": "https://ya.ru", "redirect": 303}`,
+			status: http.StatusBadRequest,
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			if tt.prepare != nil {
+				tt.prepare(repo)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(tt.body))
+			h := NewURLShortener(baseURL, repo)
+			w := httptest.NewRecorder()
+			h.HandlePostShortenJSON(w, r)
+			result := w.Result()
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, tt.status, result.StatusCode)
+		})
+	}
+}
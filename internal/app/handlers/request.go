@@ -1,20 +1,135 @@
 package handlers
 
+import "time"
+
 // URLShortenRequest represents JSON {"url":"<some_url>"}
+// Остальные поля необязательны, Redirect - код перенаправления 301, 302, 307 или 308,
+// ForwardQuery и ForwardPath включают проброс параметров запроса и хвоста пути в оригинальную ссылку,
+// UTMTemplate - имя шаблона UTM меток пользователя, параметры которого добавляются в оригинальную ссылку,
+// Password - пароль, без которого переход по ссылке запрещен, MaxClicks - сколько раз можно перейти по ссылке,
+// NotBefore и NotAfter - окно активности ссылки, вне которого переход по ней не выполняется,
+// Variants - несколько оригинальных ссылок с весами, между которыми распределяются переходы, передаются вместо URL,
+// Rules - правила выбора оригинальной ссылки по устройству и языку посетителя.
 type URLShortenRequest struct {
-	URL string `json:"url"`
+	URL          string        `json:"url"`
+	Title        string        `json:"title,omitempty"`
+	Tags         []string      `json:"tags,omitempty"`
+	Notes        string        `json:"notes,omitempty"`
+	Redirect     int           `json:"redirect,omitempty"`
+	ForwardQuery bool          `json:"forward_query,omitempty"`
+	ForwardPath  bool          `json:"forward_path,omitempty"`
+	UTMTemplate  string        `json:"utm_template,omitempty"`
+	Password     string        `json:"password,omitempty"`
+	MaxClicks    int           `json:"max_clicks,omitempty"`
+	NotBefore    time.Time     `json:"not_before,omitempty"`
+	NotAfter     time.Time     `json:"not_after,omitempty"`
+	Variants     []LinkVariant `json:"variants,omitempty"`
+	Rules        []LinkRule    `json:"rules,omitempty"`
+}
+
+// LinkVariant представляет собой вариант оригинальной ссылки с весом, вес по умолчанию - 1.
+//
+//	{
+//	  "url": "https://...",
+//	  "weight": 3
+//	}
+type LinkVariant struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight,omitempty"`
+}
+
+// LinkRule представляет собой правило выбора оригинальной ссылки для посетителей с платформой platform
+// (ios, android или desktop) и самым предпочтительным языком language. Нужно хотя бы одно из условий.
+//
+//	{
+//	  "platform": "ios",
+//	  "language": "de",
+//	  "url": "https://..."
+//	}
+type LinkRule struct {
+	Platform string `json:"platform,omitempty"`
+	Language string `json:"language,omitempty"`
+	URL      string `json:"url"`
 }
 
 // URLShortenCorrelatedRequest представляет собой структуру, в которую требуется дериализовать список ссылок для сокращения
-// [
-//   {
-//     "correlation_id": "4444",
-//     "original_url": "https://..."
-//   }, ...
-// ]
+//
+//	[
+//	  {
+//	    "correlation_id": "4444",
+//	    "original_url": "https://...",
+//	    "title": "...",
+//	    "tags": ["..."],
+//	    "notes": "...",
+//	    "redirect": 301,
+//	    "forward_query": true,
+//	    "forward_path": true,
+//	    "utm_template": "newsletter",
+//	    "password": "...",
+//	    "max_clicks": 1,
+//	    "not_before": "2022-09-01T00:00:00Z",
+//	    "not_after": "2022-10-01T00:00:00Z"
+//	  }, ...
+//	]
 type URLShortenCorrelatedRequest struct {
-	CorrelationID string `json:"correlation_id"`
-	OriginalURL   string `json:"original_url"`
+	CorrelationID string    `json:"correlation_id"`
+	OriginalURL   string    `json:"original_url"`
+	Title         string    `json:"title,omitempty"`
+	Tags          []string  `json:"tags,omitempty"`
+	Notes         string    `json:"notes,omitempty"`
+	Redirect      int       `json:"redirect,omitempty"`
+	ForwardQuery  bool      `json:"forward_query,omitempty"`
+	ForwardPath   bool      `json:"forward_path,omitempty"`
+	UTMTemplate   string    `json:"utm_template,omitempty"`
+	Password      string    `json:"password,omitempty"`
+	MaxClicks     int       `json:"max_clicks,omitempty"`
+	NotBefore     time.Time `json:"not_before,omitempty"`
+	NotAfter      time.Time `json:"not_after,omitempty"`
 }
 
 type URLID string
+
+// LinkPatchRequest представляет собой структуру, в которую требуется дериализовать изменение ссылки.
+// Не переданные поля не изменяются, пустая строка в not_before или not_after снимает границу окна активности,
+// а переданный список rules заменяет все правила ссылки.
+//
+//	{
+//	  "url": "https://...",
+//	  "title": "...",
+//	  "tags": ["..."],
+//	  "notes": "...",
+//	  "not_before": "2022-09-01T00:00:00Z",
+//	  "not_after": "",
+//	  "rules": [{"platform": "ios", "url": "https://..."}, ...]
+//	}
+type LinkPatchRequest struct {
+	URL       *string     `json:"url"`
+	Title     *string     `json:"title"`
+	Tags      *[]string   `json:"tags"`
+	Notes     *string     `json:"notes"`
+	NotBefore *string     `json:"not_before"`
+	NotAfter  *string     `json:"not_after"`
+	Rules     *[]LinkRule `json:"rules"`
+}
+
+// AbuseReportRequest представляет собой структуру, в которую требуется дериализовать жалобу на ссылку.
+// Тело запроса необязательно.
+//
+//	{
+//	  "reason": "phishing"
+//	}
+type AbuseReportRequest struct {
+	Reason string `json:"reason"`
+}
+
+// QuotaRequest представляет собой структуру, в которую требуется дериализовать ограничения пользователя,
+// назначаемые администратором. 0 снимает ограничение.
+//
+//	{
+//	  "links": 1000,
+//	  "batch": 500
+//	}
+type QuotaRequest struct {
+	Links int `json:"links"`
+	Batch int `json:"batch"`
+}
@@ -1,6 +1,13 @@
 package handlers
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/abuse"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/clicks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+)
 
 // URLShortenResponse represents JSON {"result":"<shorten_url>"}
 type URLShortenResponse struct {
@@ -12,12 +19,55 @@ type URLShortenResponse struct {
 //	[
 //	  {
 //	    "short_url": "https://...",
-//	    "original_url": "https://..."
+//	    "original_url": "https://...",
+//	    "title": "...",
+//	    "tags": ["..."],
+//	    "notes": "...",
+//	    "redirect": 301,
+//	    "forward_query": true,
+//	    "forward_path": true,
+//	    "created_at": "2022-08-01T10:00:00Z",
+//	    "updated_at": "2022-08-01T10:00:00Z",
+//	    "is_deleted": false,
+//	    "is_blocked": true,
+//	    "password_protected": true,
+//	    "max_clicks": 5,
+//	    "remaining_clicks": 2,
+//	    "not_before": "2022-09-01T00:00:00Z",
+//	    "not_after": "2022-10-01T00:00:00Z",
+//	    "variants": [{"url": "https://...", "weight": 3}, ...],
+//	    "rules": [{"platform": "ios", "url": "https://..."}, ...],
+//	    "clicks": 10
 //	  }, ...
 //	]
+//
+// Время не передается у ссылок, сохраненных до его появления, clicks - если журнал переходов не подключен,
+// redirect - если у ссылки используется код перенаправления по умолчанию, is_blocked - у незаблокированных ссылок,
+// password_protected - у ссылок без пароля, max_clicks и remaining_clicks - у ссылок без ограничения переходов,
+// not_before и not_after - если соответствующая граница окна активности не задана, variants - у ссылок
+// с одной оригинальной ссылкой, а rules - у ссылок без правил. У ссылок с вариантами original_url - адрес первого варианта.
+// Сам пароль и его хэш не передаются никогда.
 type BucketItem struct {
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	ShortURL     string        `json:"short_url"`
+	OriginalURL  string        `json:"original_url"`
+	Title        string        `json:"title,omitempty"`
+	Tags         []string      `json:"tags,omitempty"`
+	Notes        string        `json:"notes,omitempty"`
+	Redirect     int           `json:"redirect,omitempty"`
+	ForwardQuery bool          `json:"forward_query,omitempty"`
+	ForwardPath  bool          `json:"forward_path,omitempty"`
+	CreatedAt    *time.Time    `json:"created_at,omitempty"`
+	UpdatedAt    *time.Time    `json:"updated_at,omitempty"`
+	IsDeleted    bool          `json:"is_deleted"`
+	IsBlocked    bool          `json:"is_blocked,omitempty"`
+	Protected    bool          `json:"password_protected,omitempty"`
+	MaxClicks    int           `json:"max_clicks,omitempty"`
+	Remaining    *int          `json:"remaining_clicks,omitempty"`
+	NotBefore    *time.Time    `json:"not_before,omitempty"`
+	NotAfter     *time.Time    `json:"not_after,omitempty"`
+	Variants     []LinkVariant `json:"variants,omitempty"`
+	Rules        []LinkRule    `json:"rules,omitempty"`
+	Clicks       *int64        `json:"clicks,omitempty"`
 }
 
 // MapToBucket создает корзину ссылок из `map[string]string`
@@ -32,6 +82,56 @@ func MapToBucket(baseURL string, m map[string]string) *[]BucketItem {
 	return &bucket
 }
 
+// LinksToBucket создает корзину ссылок из страницы ссылок, сохраняя их порядок.
+// totals - количество переходов по ссылкам, nil - если оно неизвестно.
+func LinksToBucket(baseURL string, links []storages.Link, totals map[string]int64) *[]BucketItem {
+	bucket := make([]BucketItem, 0, len(links))
+	for _, l := range links {
+		item := BucketItem{
+			ShortURL:     fmt.Sprintf("%s%s", baseURL, l.ID),
+			OriginalURL:  l.URL,
+			Title:        l.Title,
+			Tags:         l.Tags,
+			Notes:        l.Notes,
+			Redirect:     l.Redirect,
+			ForwardQuery: l.ForwardQuery,
+			ForwardPath:  l.ForwardPath,
+			CreatedAt:    optionalTime(l.Created),
+			UpdatedAt:    optionalTime(l.Updated),
+			IsDeleted:    l.Deleted,
+			IsBlocked:    l.Blocked,
+			Protected:    l.PasswordHash != "",
+			MaxClicks:    l.MaxClicks,
+			NotBefore:    optionalTime(l.NotBefore),
+			NotAfter:     optionalTime(l.NotAfter),
+		}
+		for _, v := range l.Variants {
+			item.Variants = append(item.Variants, LinkVariant{URL: v.URL, Weight: v.Weight})
+		}
+		for _, r := range l.Rules {
+			item.Rules = append(item.Rules, LinkRule{Platform: r.Platform, Language: r.Language, URL: r.URL})
+		}
+		if l.MaxClicks > 0 {
+			remaining := l.RemainingClicks
+			item.Remaining = &remaining
+		}
+		if totals != nil {
+			clicks := totals[l.ID]
+			item.Clicks = &clicks
+		}
+		bucket = append(bucket, item)
+	}
+	return &bucket
+}
+
+// optionalTime возвращает nil для нулевого времени, чтобы оно не попадало в JSON
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
 // URLShortenCorrelatedResponse представляет собой структуру, в которой требуется сериализовать список ссылок
 //
 //	[
@@ -44,3 +144,106 @@ type URLShortenCorrelatedResponse struct {
 	CorrelationID string `json:"correlation_id"`
 	ShortURL      string `json:"short_url"`
 }
+
+// ClickSeriesResponse представляет собой временной ряд переходов по короткой ссылке
+//
+//	{
+//	  "short_url": "https://...",
+//	  "granularity": "day",
+//	  "from": "2022-08-01T00:00:00Z",
+//	  "to": "2022-08-03T00:00:00Z",
+//	  "total": 3,
+//	  "series": [
+//	    {"start": "2022-08-01T00:00:00Z", "clicks": 1},
+//	    {"start": "2022-08-02T00:00:00Z", "clicks": 2}
+//	  ],
+//	  "variants": [
+//	    {"url": "https://...", "weight": 3, "clicks": 2},
+//	    {"url": "https://...", "weight": 1, "clicks": 1}
+//	  ]
+//	}
+//
+// variants передаются только у ссылок с вариантами.
+type ClickSeriesResponse struct {
+	From        time.Time          `json:"from"`
+	To          time.Time          `json:"to"`
+	ShortURL    string             `json:"short_url"`
+	Granularity clicks.Granularity `json:"granularity"`
+	Series      []clicks.Bucket    `json:"series"`
+	Total       int64              `json:"total"`
+	Variants    []VariantClicks    `json:"variants,omitempty"`
+}
+
+// VariantClicks - количество переходов на вариант ссылки за период ряда.
+type VariantClicks struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+	Clicks int64  `json:"clicks"`
+}
+
+// TopLinkItem представляет собой элемент рейтинга ссылок пользователя по количеству переходов
+//
+//	[
+//	  {
+//	    "short_url": "https://...",
+//	    "original_url": "https://...",
+//	    "clicks": 42
+//	  }, ...
+//	]
+type TopLinkItem struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+	Clicks      int64  `json:"clicks"`
+}
+
+// InternalStatsResponse представляет собой сводную статистику сервиса
+//
+//	{
+//	  "urls": 10,
+//	  "users": 2,
+//	  "deleted": 1
+//	}
+type InternalStatsResponse struct {
+	URLs    int `json:"urls"`
+	Users   int `json:"users"`
+	Deleted int `json:"deleted"`
+}
+
+// ReportedLinkItem представляет собой элемент очереди модерации: ссылку и жалобы на нее
+//
+//	[
+//	  {
+//	    "short_url": "https://...",
+//	    "original_url": "https://...",
+//	    "is_deleted": false,
+//	    "is_blocked": false,
+//	    "reports": [
+//	      {"id": "...", "reporter": "...", "reason": "phishing", "time": "2022-08-01T10:00:00Z"}
+//	    ]
+//	  }, ...
+//	]
+//
+// original_url не передается у удаленных ссылок.
+type ReportedLinkItem struct {
+	ShortURL    string         `json:"short_url"`
+	OriginalURL string         `json:"original_url,omitempty"`
+	IsDeleted   bool           `json:"is_deleted"`
+	IsBlocked   bool           `json:"is_blocked"`
+	Reports     []abuse.Report `json:"reports"`
+}
+
+// QuotaResponse представляет собой структуру с действующими ограничениями пользователя.
+// overridden - ограничения назначены пользователю отдельно, а не действуют по умолчанию.
+//
+//	{
+//	  "user": "...",
+//	  "links": 1000,
+//	  "batch": 500,
+//	  "overridden": true
+//	}
+type QuotaResponse struct {
+	User       string `json:"user"`
+	Links      int    `json:"links"`
+	Batch      int    `json:"batch"`
+	Overridden bool   `json:"overridden"`
+}
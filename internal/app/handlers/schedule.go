@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const defaultNotYetActiveStatus = http.StatusNotFound
+
+var (
+	ErrLinkIsNotActiveYet = errors.New("link is not active yet")
+	ErrLinkIsExpired      = errors.New("link has expired")
+	ErrInvalidWindow      = errors.New("not_after must be later than not_before")
+)
+
+// notYetActive - ответ на переход по ссылке до начала окна активности:
+// перенаправление на url, если он задан, иначе ошибка со статусом status.
+type notYetActive struct {
+	status int
+	url    string
+}
+
+// WithNotYetActive задает ответ на переход по ссылке до начала ее окна активности.
+// Если redirectURL задан, выполняется временное перенаправление на него, иначе отдается ошибка со статусом status.
+// Статус должен быть кодом ошибки 4xx или 5xx, иначе остается 404.
+func WithNotYetActive(status int, redirectURL string) Option {
+	return func(s *URLShortener) {
+		if redirectURL != "" && !utils.IsURL(redirectURL) {
+			log.Warn().Msgf("not yet active url %s is not a link, it will be ignored", redirectURL)
+			redirectURL = ""
+		}
+		s.notYetActive.url = redirectURL
+		if status < http.StatusBadRequest || status > 599 {
+			log.Warn().Msgf("not yet active status %d is not an error, %d will be used", status, s.notYetActive.status)
+			return
+		}
+		s.notYetActive.status = status
+	}
+}
+
+// checkWindow проверяет, что ссылка в своем окне активности. До начала окна отдается ответ notYetActive,
+// после окончания ссылка считается истекшей и отдается 410.
+// Если ссылка не активна, ответ уже записан в w и возвращается false.
+func (s URLShortener) checkWindow(w http.ResponseWriter, link storages.Link) bool {
+	now := time.Now()
+	switch {
+	case link.IsExpired(now):
+		http.Error(w, fmt.Sprintf("%s: %s", ErrLinkIsExpired, link.ID), http.StatusGone)
+	case !link.IsPending(now):
+		return true
+	case s.notYetActive.url != "":
+		w.Header().Set("Location", s.notYetActive.url)
+		w.Header().Set("Cache-Control", cacheControl(http.StatusTemporaryRedirect))
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	default:
+		w.Header().Set("Cache-Control", cacheControl(http.StatusTemporaryRedirect))
+		http.Error(w, fmt.Sprintf("%s: %s", ErrLinkIsNotActiveYet, link.ID), s.notYetActive.status)
+	}
+	return false
+}
+
+// parseWindowBound разбирает границу окна активности из запроса на изменение ссылки.
+// nil - граница не изменяется, пустая строка снимает границу, иначе ожидается время в RFC 3339,
+// которое приводится к UTC.
+func parseWindowBound(name string, v *string) (*time.Time, error) {
+	if v == nil {
+		return nil, nil
+	}
+	var t time.Time
+	if *v != "" {
+		var err error
+		if t, err = time.Parse(time.RFC3339, *v); err != nil {
+			return nil, fmt.Errorf("%s must be RFC 3339 time: %s", name, *v)
+		}
+		t = t.UTC()
+	}
+	return &t, nil
+}
+
+// validateWindow проверяет, что окно активности не пустое, если заданы обе его границы.
+func validateWindow(notBefore, notAfter time.Time) error {
+	if !notBefore.IsZero() && !notAfter.IsZero() && !notAfter.After(notBefore) {
+		return ErrInvalidWindow
+	}
+	return nil
+}
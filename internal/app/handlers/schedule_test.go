@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLShortener_ActivityWindow(t *testing.T) {
+	now := time.Now().UTC()
+	pending := storages.Link{ID: "1111", URL: "https://ya.ru", NotBefore: now.Add(time.Hour)}
+	active := storages.Link{ID: "1111", URL: "https://ya.ru", NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour)}
+	permanent := storages.Link{ID: "1111", URL: "https://ya.ru", Redirect: http.StatusMovedPermanently, NotAfter: now.Add(time.Hour)}
+	expired := storages.Link{ID: "1111", URL: "https://ya.ru", NotAfter: now.Add(-time.Hour)}
+
+	tests := []struct {
+		name     string
+		target   string
+		link     storages.Link
+		opts     []Option
+		status   int
+		location string
+	}{
+		{
+			name:     "active",
+			target:   "/1111",
+			link:     active,
+			status:   http.StatusTemporaryRedirect,
+			location: "https://ya.ru",
+		},
+		{
+			name:     "active permanent redirect is not cached",
+			target:   "/1111",
+			link:     permanent,
+			status:   http.StatusMovedPermanently,
+			location: "https://ya.ru",
+		},
+		{
+			name:   "not active yet",
+			target: "/1111",
+			link:   pending,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "not active yet with custom status",
+			target: "/1111",
+			link:   pending,
+			opts:   []Option{WithNotYetActive(http.StatusServiceUnavailable, "")},
+			status: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "not active yet with landing page",
+			target:   "/1111",
+			link:     pending,
+			opts:     []Option{WithNotYetActive(http.StatusNotFound, "https://example.com/soon")},
+			status:   http.StatusTemporaryRedirect,
+			location: "https://example.com/soon",
+		},
+		{
+			name:   "not active yet with unsupported status",
+			target: "/1111",
+			link:   pending,
+			opts:   []Option{WithNotYetActive(http.StatusOK, "")},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "preview of not active yet",
+			target: "/1111+",
+			link:   pending,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "expired",
+			target: "/1111",
+			link:   expired,
+			status: http.StatusGone,
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			repo.EXPECT().Restore(gomock.Any(), "1111").Return(tt.link, nil)
+
+			h := NewURLShortener(baseURL, repo, tt.opts...)
+			r := chi.NewRouter()
+			r.Get("/{id}", h.HandleGet)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			result := w.Result()
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, tt.status, result.StatusCode)
+			assert.Equal(t, tt.location, result.Header.Get("Location"))
+			if tt.location == tt.link.URL {
+				// ссылку с окном активности нельзя кэшировать дольше окна
+				assert.Equal(t, "private, no-cache", result.Header.Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestURLShortener_ShortenWithActivityWindow(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	repo := mock_handlers.NewMockRepository(mockCtrl)
+	launch := time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC)
+	repo.EXPECT().Store(gomock.Any(), gomock.Any(), linkWithWindow(launch, time.Time{})).Return("1111", nil)
+
+	h := NewURLShortener(baseURL, repo)
+	for body, status := range map[string]int{
+		`{"url": "https://ya.ru", "not_before": "2022-09-01T03:00:00+03:00"}`:                                 http.StatusCreated,
+		`{"url": "https://ya.ru", "not_before": "2022-09-01T00:00:00Z", "not_after": "2022-08-01T00:00:00Z"}`: http.StatusBadRequest,
+		`{"url": "https://ya.ru", "not_before": "tomorrow"}`:                                                  http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		h.HandlePostShortenJSON(w, httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body)))
+		result := w.Result()
+		require.NoError(t, result.Body.Close())
+		assert.Equal(t, status, result.StatusCode, body)
+	}
+}
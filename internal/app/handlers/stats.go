@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/clicks"
+	midware "github.com/UndeadDemidov/yandex-praktikum/internal/app/middleware"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultTopSize  = 10
+	maxTopSize      = 100
+	maxSeriesPoints = 24 * 366
+)
+
+var (
+	ErrClickStatsDisabled = errors.New("click statistics are not enabled")
+	ErrInvalidTimeRange   = errors.New("invalid time range, from and to are expected in RFC3339 and from must be before to")
+	ErrTooLongTimeRange   = errors.New("time range is too long for requested granularity")
+	ErrInvalidTopSize     = errors.New("n must be a positive number")
+	ErrInvalidIncludeBots = errors.New("include_bots must be a boolean")
+)
+
+// HandleGetClickSeries - метод для получения временного ряда переходов по ссылке пользователя.
+// Параметры запроса: granularity (hour или day, по умолчанию day), from и to в формате RFC3339.
+// По умолчанию отдается последний месяц по дням или последние сутки по часам.
+// Переходы ботов учитываются только при include_bots=true.
+// У ссылок с вариантами дополнительно отдается количество переходов на каждый вариант за тот же период.
+func (s URLShortener) HandleGetClickSeries(w http.ResponseWriter, r *http.Request) {
+	if s.clicks == nil {
+		http.Error(w, ErrClickStatsDisabled.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	var err error
+	id := chi.URLParam(r, "id")
+	q := r.URL.Query()
+	g := clicks.Day
+	if v := q.Get("granularity"); v != "" {
+		g, err = clicks.ParseGranularity(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	to := time.Now().UTC()
+	from := to.AddDate(0, -1, 0)
+	if g == clicks.Hour {
+		from = to.Add(-24 * time.Hour)
+	}
+	from, to, err = parseTimeRange(q, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) > maxSeriesPoints*g.Step() {
+		http.Error(w, ErrTooLongTimeRange.Error(), http.StatusBadRequest)
+		return
+	}
+	withBots, err := parseIncludeBots(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+	defer cancel()
+
+	// статистика удаленной ссылки остается доступной ее владельцу
+	link, err := s.linkRepo.Restore(ctx, id)
+	switch {
+	case errors.Is(err, ErrLinkIsNotFound):
+		http.Error(w, fmt.Sprintf("link %s is not found", id), http.StatusNotFound)
+		return
+	case err != nil && !errors.Is(err, ErrLinkIsDeleted):
+		utils.InternalServerError(w, err)
+		return
+	case link.User != midware.GetUserID(ctx):
+		http.Error(w, fmt.Sprintf("link %s is not found", id), http.StatusNotFound)
+		return
+	}
+
+	series, err := s.clicks.Series(ctx, id, g, from, to, withBots)
+	if err != nil {
+		utils.InternalServerError(w, err)
+		return
+	}
+	variants, err := s.variantClicks(ctx, link, from, to, withBots)
+	if err != nil {
+		utils.InternalServerError(w, err)
+		return
+	}
+
+	resp := ClickSeriesResponse{
+		ShortURL:    fmt.Sprintf("%s%s", s.baseURL, id),
+		Granularity: g,
+		From:        from,
+		To:          to,
+		Series:      series,
+		Variants:    variants,
+	}
+	for _, b := range series {
+		resp.Total += b.Clicks
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(&resp)
+	if err != nil {
+		utils.InternalServerError(w, err)
+	}
+}
+
+// HandleGetTopLinks - метод для получения рейтинга ссылок пользователя по количеству переходов.
+// Параметры запроса: n - размер рейтинга (по умолчанию 10), from и to в формате RFC3339 (по умолчанию за все время),
+// include_bots - учитывать ли переходы ботов (по умолчанию нет).
+// Ссылки без переходов за период в рейтинг не попадают, удаленные ссылки остаются в нем.
+func (s URLShortener) HandleGetTopLinks(w http.ResponseWriter, r *http.Request) {
+	if s.clicks == nil {
+		http.Error(w, ErrClickStatsDisabled.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	q := r.URL.Query()
+	n := defaultTopSize
+	if v := q.Get("n"); v != "" {
+		var err error
+		n, err = strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, ErrInvalidTopSize.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if n > maxTopSize {
+		n = maxTopSize
+	}
+	from, to, err := parseTimeRange(q, time.Time{}, time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	withBots, err := parseIncludeBots(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+	defer cancel()
+
+	// рейтинг строится журналом переходов, из хранилища читаются только попавшие в него ссылки
+	totals, err := s.clicks.Top(ctx, midware.GetUserID(ctx), n, from, to, withBots)
+	if err != nil {
+		utils.InternalServerError(w, err)
+		return
+	}
+	if len(totals) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	top := make([]TopLinkItem, 0, len(totals))
+	for _, t := range totals {
+		link, err := s.linkRepo.Restore(ctx, t.ID)
+		if err != nil && !errors.Is(err, ErrLinkIsDeleted) {
+			utils.InternalServerError(w, err)
+			return
+		}
+		top = append(top, TopLinkItem{
+			ShortURL:    fmt.Sprintf("%s%s", s.baseURL, t.ID),
+			OriginalURL: link.URL,
+			Clicks:      t.Clicks,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(&top)
+	if err != nil {
+		utils.InternalServerError(w, err)
+	}
+}
+
+// parseTimeRange читает из параметров запроса границы from и to, если они не переданы - возвращает значения по умолчанию.
+// Нулевые значения по умолчанию означают отсутствие границы.
+func parseTimeRange(q url.Values, defFrom, defTo time.Time) (from, to time.Time, err error) {
+	from, to = defFrom, defTo
+	if v := q.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidTimeRange
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidTimeRange
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return time.Time{}, time.Time{}, ErrInvalidTimeRange
+	}
+	return from.UTC(), to.UTC(), nil
+}
+
+// parseIncludeBots читает из параметров запроса признак учета переходов ботов, по умолчанию - false.
+func parseIncludeBots(q url.Values) (bool, error) {
+	v := q.Get("include_bots")
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, ErrInvalidIncludeBots
+	}
+	return b, nil
+}
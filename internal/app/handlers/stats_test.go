@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/clicks"
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	midware "github.com/UndeadDemidov/yandex-praktikum/internal/app/middleware"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//nolint:funlen
+func TestURLShortener_HandleGetClickSeries(t *testing.T) {
+	type fields struct {
+		repo   *mock_handlers.MockRepository
+		clicks *mock_handlers.MockClickRecorder
+	}
+	type want struct {
+		status int
+		result string
+	}
+	day := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		query   string
+		want    want
+		prepare func(f *fields)
+	}{
+		{
+			name:  "daily series",
+			query: "?from=2022-08-01T00:00:00Z&to=2022-08-03T00:00:00Z",
+			want: want{
+				status: http.StatusOK,
+				result: `{
+					"short_url": "http://localhost:8080/1111",
+					"granularity": "day",
+					"from": "2022-08-01T00:00:00Z",
+					"to": "2022-08-03T00:00:00Z",
+					"total": 3,
+					"series": [
+						{"start": "2022-08-01T00:00:00Z", "clicks": 1},
+						{"start": "2022-08-02T00:00:00Z", "clicks": 2}
+					]
+				}`,
+			},
+			prepare: func(f *fields) {
+				gomock.InOrder(
+					f.repo.EXPECT().Restore(gomock.Any(), "1111").
+						Return(storages.Link{ID: "1111", User: "xxxx", URL: "https://ya.ru"}, nil),
+					f.clicks.EXPECT().Series(gomock.Any(), "1111", clicks.Day, day, day.AddDate(0, 0, 2), false).
+						Return([]clicks.Bucket{{Start: day, Clicks: 1}, {Start: day.AddDate(0, 0, 1), Clicks: 2}}, nil),
+				)
+			},
+		},
+		{
+			name:  "variants",
+			query: "?from=2022-08-01T00:00:00Z&to=2022-08-02T00:00:00Z",
+			want: want{
+				status: http.StatusOK,
+				result: `{
+					"short_url": "http://localhost:8080/1111",
+					"granularity": "day",
+					"from": "2022-08-01T00:00:00Z",
+					"to": "2022-08-02T00:00:00Z",
+					"total": 3,
+					"series": [
+						{"start": "2022-08-01T00:00:00Z", "clicks": 3}
+					],
+					"variants": [
+						{"url": "https://ya.ru", "weight": 3, "clicks": 2},
+						{"url": "https://go.dev", "weight": 1, "clicks": 1}
+					]
+				}`,
+			},
+			prepare: func(f *fields) {
+				link := storages.Link{ID: "1111", User: "xxxx", URL: "https://ya.ru", Variants: []storages.Variant{
+					{URL: "https://ya.ru", Weight: 3}, {URL: "https://go.dev", Weight: 1}}}
+				gomock.InOrder(
+					f.repo.EXPECT().Restore(gomock.Any(), "1111").Return(link, nil),
+					f.clicks.EXPECT().Series(gomock.Any(), "1111", clicks.Day, day, day.AddDate(0, 0, 1), false).
+						Return([]clicks.Bucket{{Start: day, Clicks: 3}}, nil),
+					f.clicks.EXPECT().Totals(gomock.Any(), []string{"1111#1", "1111#2"}, day, day.AddDate(0, 0, 1), false).
+						Return(map[string]int64{"1111#1": 2, "1111#2": 1}, nil),
+				)
+			},
+		},
+		{
+			name:  "foreign link",
+			query: "",
+			want:  want{status: http.StatusNotFound},
+			prepare: func(f *fields) {
+				f.repo.EXPECT().Restore(gomock.Any(), "1111").
+					Return(storages.Link{ID: "1111", User: "yyyy", URL: "https://ya.ru"}, nil)
+			},
+		},
+		{
+			name:  "unknown link",
+			query: "",
+			want:  want{status: http.StatusNotFound},
+			prepare: func(f *fields) {
+				f.repo.EXPECT().Restore(gomock.Any(), "1111").Return(storages.Link{}, ErrLinkIsNotFound)
+			},
+		},
+		{
+			name:  "unknown granularity",
+			query: "?granularity=week",
+			want:  want{status: http.StatusBadRequest},
+		},
+		{
+			name:  "inverted range",
+			query: "?from=2022-08-03T00:00:00Z&to=2022-08-01T00:00:00Z",
+			want:  want{status: http.StatusBadRequest},
+		},
+		{
+			name:  "deleted link with bots",
+			query: "?from=2022-08-01T00:00:00Z&to=2022-08-02T00:00:00Z&include_bots=true",
+			want: want{
+				status: http.StatusOK,
+				result: `{
+					"short_url": "http://localhost:8080/1111",
+					"granularity": "day",
+					"from": "2022-08-01T00:00:00Z",
+					"to": "2022-08-02T00:00:00Z",
+					"total": 5,
+					"series": [
+						{"start": "2022-08-01T00:00:00Z", "clicks": 5}
+					]
+				}`,
+			},
+			prepare: func(f *fields) {
+				gomock.InOrder(
+					f.repo.EXPECT().Restore(gomock.Any(), "1111").
+						Return(storages.Link{ID: "1111", User: "xxxx", URL: "https://ya.ru", Deleted: true}, ErrLinkIsDeleted),
+					f.clicks.EXPECT().Series(gomock.Any(), "1111", clicks.Day, day, day.AddDate(0, 0, 1), true).
+						Return([]clicks.Bucket{{Start: day, Clicks: 5}}, nil),
+				)
+			},
+		},
+		{
+			name:  "invalid include_bots",
+			query: "?include_bots=maybe",
+			want:  want{status: http.StatusBadRequest},
+		},
+		{
+			name:  "too long range",
+			query: "?granularity=hour&from=2020-08-03T00:00:00Z&to=2022-08-01T00:00:00Z",
+			want:  want{status: http.StatusBadRequest},
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			f := fields{
+				repo:   mock_handlers.NewMockRepository(mockCtrl),
+				clicks: mock_handlers.NewMockClickRecorder(mockCtrl),
+			}
+			if tt.prepare != nil {
+				tt.prepare(&f)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/api/user/urls/1111/clicks"+tt.query, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "1111")
+			ctx := context.WithValue(r.Context(), chi.RouteCtxKey, rctx)
+			r = r.WithContext(context.WithValue(ctx, midware.ContextUserIDKey, "xxxx"))
+
+			h := NewURLShortener(baseURL, f.repo, WithClickRecorder(f.clicks))
+			w := httptest.NewRecorder()
+			h.HandleGetClickSeries(w, r)
+			result := w.Result()
+			assert.Equal(t, tt.want.status, result.StatusCode)
+
+			buf := new(bytes.Buffer)
+			_, err := buf.ReadFrom(result.Body)
+			require.NoError(t, err)
+			if tt.want.result != "" {
+				assert.JSONEq(t, tt.want.result, buf.String())
+			}
+			err = result.Body.Close()
+			require.NoError(t, err)
+		})
+	}
+}
+
+//nolint:funlen
+func TestURLShortener_HandleGetTopLinks(t *testing.T) {
+	type fields struct {
+		repo   *mock_handlers.MockRepository
+		clicks *mock_handlers.MockClickRecorder
+	}
+	type want struct {
+		status int
+		result string
+	}
+	tests := []struct {
+		name    string
+		query   string
+		want    want
+		prepare func(f *fields)
+	}{
+		{
+			name:  "top 2 of 3",
+			query: "?n=2",
+			want: want{
+				status: http.StatusOK,
+				result: `[
+					{"short_url": "http://localhost:8080/2222", "original_url": "https://yandex.ru", "clicks": 7},
+					{"short_url": "http://localhost:8080/1111", "original_url": "https://ya.ru", "clicks": 3}
+				]`,
+			},
+			prepare: func(f *fields) {
+				gomock.InOrder(
+					f.clicks.EXPECT().Top(gomock.Any(), "xxxx", 2, time.Time{}, time.Time{}, false).
+						Return([]clicks.Total{{ID: "2222", Clicks: 7}, {ID: "1111", Clicks: 3}}, nil),
+					f.repo.EXPECT().Restore(gomock.Any(), "2222").
+						Return(storages.Link{ID: "2222", User: "xxxx", URL: "https://yandex.ru"}, nil),
+					f.repo.EXPECT().Restore(gomock.Any(), "1111").
+						Return(storages.Link{ID: "1111", User: "xxxx", URL: "https://ya.ru", Deleted: true}, ErrLinkIsDeleted),
+				)
+			},
+		},
+		{
+			name: "no clicks",
+			want: want{status: http.StatusNoContent},
+			prepare: func(f *fields) {
+				f.clicks.EXPECT().Top(gomock.Any(), "xxxx", defaultTopSize, time.Time{}, time.Time{}, false).
+					Return([]clicks.Total{}, nil)
+			},
+		},
+		{
+			name:  "invalid n",
+			query: "?n=-1",
+			want:  want{status: http.StatusBadRequest},
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			f := fields{
+				repo:   mock_handlers.NewMockRepository(mockCtrl),
+				clicks: mock_handlers.NewMockClickRecorder(mockCtrl),
+			}
+			if tt.prepare != nil {
+				tt.prepare(&f)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/api/user/urls/top"+tt.query, nil)
+			r = r.WithContext(context.WithValue(r.Context(), midware.ContextUserIDKey, "xxxx"))
+			h := NewURLShortener(baseURL, f.repo, WithClickRecorder(f.clicks))
+			w := httptest.NewRecorder()
+			h.HandleGetTopLinks(w, r)
+			result := w.Result()
+			assert.Equal(t, tt.want.status, result.StatusCode)
+
+			buf := new(bytes.Buffer)
+			_, err := buf.ReadFrom(result.Body)
+			require.NoError(t, err)
+			if tt.want.result != "" {
+				assert.JSONEq(t, tt.want.result, buf.String())
+			}
+			err = result.Body.Close()
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+)
+
+const (
+	platformIOS     = "ios"
+	platformAndroid = "android"
+	platformDesktop = "desktop"
+
+	maxRules          = 20
+	maxLanguageLength = 35
+)
+
+var (
+	ErrTooManyRules    = errors.New("at most 20 rules are allowed")
+	ErrInvalidRule     = errors.New("rule must have a link and a platform or a language")
+	ErrUnknownPlatform = errors.New("platform must be ios, android or desktop")
+	ErrInvalidLanguage = errors.New("language must be a language tag like en or pt-br")
+	ErrInvalidRuleLink = errors.New("rule url must be a link")
+)
+
+// newRules создает правила выбора оригинальной ссылки из запроса, приводя платформу и язык к нижнему регистру.
+func newRules(req []LinkRule) []storages.Rule {
+	rules := make([]storages.Rule, 0, len(req))
+	for _, r := range req {
+		rules = append(rules, storages.Rule{
+			Platform: strings.ToLower(strings.TrimSpace(r.Platform)),
+			Language: strings.ToLower(strings.TrimSpace(r.Language)),
+			URL:      r.URL,
+		})
+	}
+	return rules
+}
+
+// validateRules проверяет количество правил и каждое правило. Правила должны быть уже нормализованы.
+func validateRules(rules []storages.Rule) error {
+	if len(rules) > maxRules {
+		return ErrTooManyRules
+	}
+	for _, r := range rules {
+		switch {
+		case r.URL == "" || (r.Platform == "" && r.Language == ""):
+			return ErrInvalidRule
+		case !utils.IsURL(r.URL):
+			return ErrInvalidRuleLink
+		case r.Platform != "" && r.Platform != platformIOS && r.Platform != platformAndroid && r.Platform != platformDesktop:
+			return ErrUnknownPlatform
+		case r.Language != "" && !isLanguageTag(r.Language):
+			return ErrInvalidLanguage
+		}
+	}
+	return nil
+}
+
+// isLanguageTag проверяет, что tag похож на языковой тег: латинские буквы и цифры, разделенные "-".
+func isLanguageTag(tag string) bool {
+	if len(tag) > maxLanguageLength {
+		return false
+	}
+	for _, part := range strings.Split(tag, "-") {
+		if part == "" {
+			return false
+		}
+		for _, c := range part {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// prepareRules проверяет и приводит к канонической форме оригинальные ссылки правил так же,
+// как основную оригинальную ссылку, см. prepareDestination. В ключ уникальности ссылки правила не входят.
+func (s URLShortener) prepareRules(ctx context.Context, rules []storages.Rule) error {
+	for i := range rules {
+		dest := storages.Link{URL: rules[i].URL}
+		if err := s.prepareDestination(ctx, &dest); err != nil {
+			return err
+		}
+		rules[i].URL = dest.URL
+	}
+	return nil
+}
+
+// applyRules возвращает ссылку, у которой URL заменен оригинальной ссылкой первого подходящего посетителю правила.
+// Платформа определяется по User-Agent, язык - самый предпочтительный из Accept-Language.
+// Язык правила подходит и к уточненному языку посетителя: правило "pt" подходит к "pt-BR", но не наоборот.
+// Если подходящего правила нет, возвращается сама ссылка и false.
+func applyRules(r *http.Request, link storages.Link) (storages.Link, bool) {
+	if len(link.Rules) == 0 {
+		return link, false
+	}
+	platform := platformOf(r.UserAgent())
+	language := preferredLanguage(r.Header.Get("Accept-Language"))
+	for _, rule := range link.Rules {
+		if rule.Platform != "" && rule.Platform != platform {
+			continue
+		}
+		if rule.Language != "" && rule.Language != language && !strings.HasPrefix(language, rule.Language+"-") {
+			continue
+		}
+		link.URL = rule.URL
+		return link, true
+	}
+	return link, false
+}
+
+// platformOf определяет платформу посетителя по User-Agent, все, что не iOS и не Android, считается desktop.
+func platformOf(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"), strings.Contains(userAgent, "iPod"):
+		return platformIOS
+	case strings.Contains(userAgent, "Android"):
+		return platformAndroid
+	}
+	return platformDesktop
+}
+
+// preferredLanguage возвращает самый предпочтительный язык из заголовка Accept-Language в нижнем регистре.
+// Языки с q=0 и "*" не учитываются, при равных q побеждает указанный раньше.
+func preferredLanguage(header string) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	langs := make([]weighted, 0)
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			var err error
+			if q, err = strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			langs = append(langs, weighted{tag: tag, q: q})
+		}
+	}
+	if len(langs) == 0 {
+		return ""
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	return langs[0].tag
+}
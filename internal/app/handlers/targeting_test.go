@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	iPhoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 15_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.6 Mobile/15E148 Safari/604.1"
+	androidUA = "Mozilla/5.0 (Linux; Android 12; Pixel 6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Mobile Safari/537.36"
+	desktopUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36"
+)
+
+func TestURLShortener_Rules(t *testing.T) {
+	link := storages.Link{ID: "1111", URL: "https://example.com", Rules: []storages.Rule{
+		{Platform: "ios", Language: "de", URL: "https://apps.apple.com/de/app"},
+		{Platform: "ios", URL: "https://apps.apple.com/app"},
+		{Platform: "android", URL: "https://play.google.com/app"},
+		{Language: "pt", URL: "https://example.com/pt"},
+	}}
+
+	tests := []struct {
+		name      string
+		userAgent string
+		language  string
+		location  string
+	}{
+		{
+			name:      "ios in german",
+			userAgent: iPhoneUA,
+			language:  "de-DE,de;q=0.9,en;q=0.8",
+			location:  "https://apps.apple.com/de/app",
+		},
+		{
+			name:      "ios",
+			userAgent: iPhoneUA,
+			language:  "en-US,en;q=0.9",
+			location:  "https://apps.apple.com/app",
+		},
+		{
+			name:      "android",
+			userAgent: androidUA,
+			location:  "https://play.google.com/app",
+		},
+		{
+			name:      "desktop in brazilian portuguese",
+			userAgent: desktopUA,
+			language:  "en;q=0.5, pt-BR",
+			location:  "https://example.com/pt",
+		},
+		{
+			name:      "fallback",
+			userAgent: desktopUA,
+			language:  "en-US,en;q=0.9,pt;q=0.8",
+			location:  "https://example.com",
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			repo.EXPECT().Restore(gomock.Any(), "1111").Return(link, nil)
+
+			h := NewURLShortener(baseURL, repo)
+			r := chi.NewRouter()
+			r.Get("/{id}", h.HandleGet)
+			req := httptest.NewRequest(http.MethodGet, "/1111", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			if tt.language != "" {
+				req.Header.Set("Accept-Language", tt.language)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			result := w.Result()
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, http.StatusTemporaryRedirect, result.StatusCode)
+			assert.Equal(t, tt.location, result.Header.Get("Location"))
+			assert.Equal(t, "User-Agent, Accept-Language", result.Header.Get("Vary"))
+		})
+	}
+}
+
+func TestURLShortener_ShortenWithRules(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	repo := mock_handlers.NewMockRepository(mockCtrl)
+	repo.EXPECT().Store(gomock.Any(), gomock.Any(), storages.Link{
+		URL:       "https://example.com",
+		Canonical: "https://example.com/",
+		Tags:      []string{},
+		Rules:     []storages.Rule{{Platform: "ios", Language: "pt-br", URL: "https://apps.apple.com/app"}},
+	}).Return("1111", nil)
+
+	h := NewURLShortener(baseURL, repo)
+	for body, status := range map[string]int{
+		`{"url": "https://example.com", "rules": [{"platform": "iOS", "language": "pt-BR", "url": "https://apps.apple.com/app"}]}`: http.StatusCreated,
+		`{"url": "https://example.com", "rules": [{"url": "https://apps.apple.com/app"}]}`:                                         http.StatusBadRequest,
+		`{"url": "https://example.com", "rules": [{"platform": "windows", "url": "https://apps.apple.com/app"}]}`:                  http.StatusBadRequest,
+		`{"url": "https://example.com", "rules": [{"language": "pt_BR", "url": "https://apps.apple.com/app"}]}`:                    http.StatusBadRequest,
+		`{"url": "https://example.com", "rules": [{"platform": "ios", "url": "apps.apple.com"}]}`:                                  http.StatusBadRequest,
+		`{"url": "https://example.com", "rules": [{"platform": "ios", "url": "http://127.0.0.1/app"}]}`:                            http.StatusUnprocessableEntity,
+	} {
+		w := httptest.NewRecorder()
+		h.HandlePostShortenJSON(w, httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body)))
+		result := w.Result()
+		require.NoError(t, result.Body.Close())
+		assert.Equal(t, status, result.StatusCode, body)
+	}
+}
+
+func Test_preferredLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: ""},
+		{header: "de-DE,de;q=0.9", want: "de-de"},
+		{header: "en;q=0.5, fr;q=0.8", want: "fr"},
+		{header: "*, ru;q=0.1", want: "ru"},
+		{header: "ru;q=0, en;q=0.3", want: "en"},
+		{header: "en;q=bad, es", want: "es"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			assert.Equal(t, tt.want, preferredLanguage(tt.header))
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	midware "github.com/UndeadDemidov/yandex-praktikum/internal/app/middleware"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utm"
+	"github.com/go-chi/chi/v5"
+)
+
+// WithUTMTemplates подключает хранилище шаблонов UTM меток, по умолчанию шаблоны хранятся в памяти.
+func WithUTMTemplates(store utm.Store) Option {
+	return func(s *URLShortener) {
+		s.templates = store
+	}
+}
+
+// applyTemplate добавляет в оригинальную ссылку параметры шаблона name пользователя.
+// Пустое имя означает, что шаблон не используется.
+func (s URLShortener) applyTemplate(ctx context.Context, user string, rawURL string, name string) (string, error) {
+	if name == "" {
+		return rawURL, nil
+	}
+	t, err := s.templates.Get(ctx, user, name)
+	if errors.Is(err, utm.ErrTemplateNotFound) {
+		return "", fmt.Errorf("%w: %s", err, name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return utm.Apply(rawURL, t)
+}
+
+// HandleGetUTMTemplates - метод для получения шаблонов UTM меток пользователя, упорядоченных по имени.
+func (s URLShortener) HandleGetUTMTemplates(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+	defer cancel()
+
+	user := midware.GetUserID(ctx)
+	templates, err := s.templates.List(ctx, user)
+	if err != nil {
+		utils.InternalServerError(w, err)
+		return
+	}
+	if len(templates) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(templates)
+	if err != nil {
+		utils.InternalServerError(w, err)
+	}
+}
+
+// HandlePutUTMTemplate - метод для создания или замены шаблона UTM меток пользователя.
+// Имя шаблона передается в пути, параметры - json объектом вида {"utm_source": "mail"}.
+func (s URLShortener) HandlePutUTMTemplate(w http.ResponseWriter, r *http.Request) {
+	t := utm.Template{Name: chi.URLParam(r, "name")}
+	err := json.NewDecoder(r.Body).Decode(&t.Params)
+	if err != nil {
+		http.Error(w, ErrProperJSONIsExpected.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = t.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+	defer cancel()
+
+	user := midware.GetUserID(ctx)
+	if err = s.templates.Save(ctx, user, t); err != nil {
+		utils.InternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(t)
+	if err != nil {
+		utils.InternalServerError(w, err)
+	}
+}
+
+// HandleDeleteUTMTemplate - метод для удаления шаблона UTM меток пользователя.
+// Ранее сокращенные по шаблону ссылки не изменяются.
+func (s URLShortener) HandleDeleteUTMTemplate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+	defer cancel()
+
+	name := chi.URLParam(r, "name")
+	user := midware.GetUserID(ctx)
+	err := s.templates.Delete(ctx, user, name)
+	switch {
+	case errors.Is(err, utm.ErrTemplateNotFound):
+		http.Error(w, fmt.Sprintf("%s: %s", err, name), http.StatusNotFound)
+		return
+	case err != nil:
+		utils.InternalServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utm"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLShortener_UTMTemplates(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	repo := mock_handlers.NewMockRepository(mockCtrl)
+	h := NewURLShortener(baseURL, repo, WithUTMTemplates(utm.NewMemoryStore()))
+
+	r := chi.NewRouter()
+	r.Get("/api/user/utm", h.HandleGetUTMTemplates)
+	r.Put("/api/user/utm/{name}", h.HandlePutUTMTemplate)
+	r.Delete("/api/user/utm/{name}", h.HandleDeleteUTMTemplate)
+	serve := func(method, target, body string) (int, string) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		result := w.Result()
+		buf := new(bytes.Buffer)
+		_, err := buf.ReadFrom(result.Body)
+		require.NoError(t, err)
+		require.NoError(t, result.Body.Close())
+		return result.StatusCode, buf.String()
+	}
+
+	status, _ := serve(http.MethodGet, "/api/user/utm", "")
+	assert.Equal(t, http.StatusNoContent, status)
+
+	status, body := serve(http.MethodPut, "/api/user/utm/news", `{"utm_source": "mail", "utm_medium": "email"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"name": "news", "params": {"utm_source": "mail", "utm_medium": "email"}}`, body)
+
+	status, body = serve(http.MethodPut, "/api/user/utm/ads", `{"ref": "ads"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "must start with utm_")
+
+	status, body = serve(http.MethodGet, "/api/user/utm", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `[{"name": "news", "params": {"utm_source": "mail", "utm_medium": "email"}}]`, body)
+
+	status, _ = serve(http.MethodDelete, "/api/user/utm/news", "")
+	assert.Equal(t, http.StatusNoContent, status)
+	status, body = serve(http.MethodDelete, "/api/user/utm/news", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "utm template is not found: news\n", body)
+}
+
+func TestURLShortener_ShortenWithUTMTemplate(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	templates := utm.NewMemoryStore()
+	err := templates.Save(context.Background(), "", utm.Template{Name: "news", Params: map[string]string{"utm_source": "mail"}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		target  string
+		body    string
+		status  int
+		result  string
+		prepare func(repo *mock_handlers.MockRepository)
+	}{
+		{
+			name:   "json",
+			target: "/api/shorten",
+			body:   `{"url": "https://ya.ru/?utm_medium=site", "utm_template": "news"}`,
+			status: http.StatusCreated,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().Store(gomock.Any(), gomock.Any(),
+					storages.Link{URL: "https://ya.ru/?utm_medium=site&utm_source=mail", Canonical: "https://ya.ru/?utm_medium=site&utm_source=mail", Tags: []string{}}).
+					Return("1111", nil)
+			},
+		},
+		{
+			name:   "json unknown template",
+			target: "/api/shorten",
+			body:   `{"url": "https://ya.ru", "utm_template": "ads"}`,
+			status: http.StatusBadRequest,
+			result: "utm template is not found: ads\n",
+		},
+		{
+			name:   "batch",
+			target: "/api/shorten/batch",
+			body: `[{"correlation_id": "1", "original_url": "https://ya.ru", "utm_template": "news"},
+					{"correlation_id": "2", "original_url": "https://go.dev"}]`,
+			status: http.StatusCreated,
+			prepare: func(repo *mock_handlers.MockRepository) {
+				repo.EXPECT().StoreBatch(gomock.Any(), gomock.Any(), map[string]storages.Link{
+					"1": {URL: "https://ya.ru?utm_source=mail", Canonical: "https://ya.ru/?utm_source=mail", Tags: []string{}},
+					"2": {URL: "https://go.dev", Canonical: "https://go.dev/", Tags: []string{}},
+				}).Return(map[string]string{"1": "1111", "2": "2222"}, nil)
+			},
+		},
+		{
+			name:   "batch unknown template",
+			target: "/api/shorten/batch",
+			body: `[{"correlation_id": "1", "original_url": "https://ya.ru"},
+					{"correlation_id": "2", "original_url": "https://go.dev", "utm_template": "ads"}]`,
+			status: http.StatusBadRequest,
+			result: "correlation_id 2: utm template is not found: ads\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			if tt.prepare != nil {
+				tt.prepare(repo)
+			}
+
+			h := NewURLShortener(baseURL, repo, WithUTMTemplates(templates))
+			r := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			if tt.target == "/api/shorten" {
+				h.HandlePostShortenJSON(w, r)
+			} else {
+				h.HandlePostShortenBatch(w, r)
+			}
+			result := w.Result()
+			buf := new(bytes.Buffer)
+			_, err := buf.ReadFrom(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, tt.status, result.StatusCode)
+			if tt.result != "" {
+				assert.Equal(t, tt.result, buf.String())
+			}
+		})
+	}
+}
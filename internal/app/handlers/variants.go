@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/clicks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+)
+
+const (
+	maxVariants      = 10
+	maxVariantWeight = 1000
+	// variantCookiePrefix - префикс cookie, в которой запоминается вариант ссылки, выпавший посетителю
+	variantCookiePrefix = "variant_"
+	variantCookieMaxAge = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidVariants = errors.New("variants must contain from 2 to 10 destinations")
+	ErrInvalidWeight   = errors.New("weight must be from 1 to 1000")
+	ErrURLAndVariants  = errors.New("either url or variants must be provided, not both")
+)
+
+// variantRandom - источник случайных чисел для выбора вариантов, криптостойкость здесь не нужна
+var (
+	variantRandom   = rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec
+	variantRandomMx sync.Mutex
+)
+
+// randomPoint возвращает случайное число из [0, n), по которому выбирается вариант ссылки.
+func randomPoint(n int) int {
+	variantRandomMx.Lock()
+	defer variantRandomMx.Unlock()
+	return variantRandom.Intn(n)
+}
+
+// newVariants создает варианты ссылки из запроса, вес по умолчанию - 1.
+func newVariants(req []LinkVariant) []storages.Variant {
+	variants := make([]storages.Variant, 0, len(req))
+	for _, v := range req {
+		if v.Weight == 0 {
+			v.Weight = 1
+		}
+		variants = append(variants, storages.Variant{URL: v.URL, Weight: v.Weight})
+	}
+	return variants
+}
+
+// validateVariants проверяет количество и веса вариантов ссылки, у обычной ссылки вариантов нет.
+func validateVariants(variants []storages.Variant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+	if len(variants) < 2 || len(variants) > maxVariants {
+		return ErrInvalidVariants
+	}
+	for _, v := range variants {
+		if v.Weight < 1 || v.Weight > maxVariantWeight {
+			return ErrInvalidWeight
+		}
+	}
+	return nil
+}
+
+// destinations возвращает все оригинальные ссылки: адреса вариантов или единственный URL.
+func destinations(link storages.Link) []string {
+	if len(link.Variants) == 0 {
+		return []string{link.URL}
+	}
+	urls := make([]string, 0, len(link.Variants))
+	for _, v := range link.Variants {
+		urls = append(urls, v.URL)
+	}
+	return urls
+}
+
+// rewriteDestinations заменяет каждую оригинальную ссылку результатом f, URL ссылки с вариантами
+// остается равным адресу первого варианта.
+func rewriteDestinations(link *storages.Link, f func(rawURL string) (string, error)) (err error) {
+	if len(link.Variants) == 0 {
+		link.URL, err = f(link.URL)
+		return err
+	}
+	for i := range link.Variants {
+		if link.Variants[i].URL, err = f(link.Variants[i].URL); err != nil {
+			return err
+		}
+	}
+	link.URL = link.Variants[0].URL
+	return nil
+}
+
+// prepareVariants готовит каждый вариант ссылки как отдельную оригинальную ссылку, см. prepareDestination.
+// Ключ уникальности ссылки с вариантами составляется из канонических форм и весов всех вариантов,
+// поэтому такая ссылка не совпадает ни с обычными ссылками на те же адреса, ни с другим набором вариантов.
+func (s URLShortener) prepareVariants(ctx context.Context, link *storages.Link) error {
+	keys := make([]string, 0, len(link.Variants))
+	err := rewriteDestinations(link, func(rawURL string) (string, error) {
+		v := storages.Link{URL: rawURL}
+		if err := s.prepareDestination(ctx, &v); err != nil {
+			return "", err
+		}
+		keys = append(keys, v.Canonical)
+		return v.URL, nil
+	})
+	if err != nil {
+		return err
+	}
+	for i, v := range link.Variants {
+		keys[i] = fmt.Sprintf("%d*%s", v.Weight, keys[i])
+	}
+	link.Canonical = "variants " + strings.Join(keys, " ")
+	return nil
+}
+
+// pickVariant возвращает ссылку, у которой URL заменен адресом выпавшего посетителю варианта, и номер варианта (с 1).
+// Вариант выбирается случайно пропорционально весам и запоминается в cookie, чтобы посетитель
+// при повторных переходах попадал на тот же адрес. У ссылки без вариантов возвращается она сама и 0.
+func (s URLShortener) pickVariant(w http.ResponseWriter, r *http.Request, link storages.Link) (storages.Link, int) {
+	if len(link.Variants) == 0 {
+		return link, 0
+	}
+	name := variantCookiePrefix + link.ID
+	i := -1
+	if c, err := r.Cookie(name); err == nil {
+		if n, err := strconv.Atoi(c.Value); err == nil && n >= 1 && n <= len(link.Variants) {
+			i = n - 1
+		}
+	}
+	if i < 0 {
+		i = link.VariantAt(s.pick(link.TotalWeight()))
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    strconv.Itoa(i + 1),
+			Path:     "/" + link.ID,
+			MaxAge:   int(variantCookieMaxAge.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	link.URL = link.Variants[i].URL
+	return link, i + 1
+}
+
+// variantClicks возвращает количество переходов по каждому варианту ссылки в промежутке [from, to),
+// у ссылки без вариантов - nil.
+func (s URLShortener) variantClicks(ctx context.Context, link storages.Link, from, to time.Time, withBots bool) ([]VariantClicks, error) {
+	if len(link.Variants) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(link.Variants))
+	for i := range link.Variants {
+		keys = append(keys, clicks.VariantKey(link.ID, i+1))
+	}
+	totals, err := s.clicks.Totals(ctx, keys, from, to, withBots)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]VariantClicks, 0, len(link.Variants))
+	for i, v := range link.Variants {
+		items = append(items, VariantClicks{URL: v.URL, Weight: v.Weight, Clicks: totals[keys[i]]})
+	}
+	return items, nil
+}
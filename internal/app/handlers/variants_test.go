@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/clicks"
+	mock_handlers "github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers/mocks"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLShortener_Variants(t *testing.T) {
+	link := storages.Link{ID: "1111", URL: "https://ya.ru", Redirect: http.StatusMovedPermanently, Variants: []storages.Variant{
+		{URL: "https://ya.ru", Weight: 3}, {URL: "https://go.dev", Weight: 1}}}
+
+	tests := []struct {
+		name     string
+		cookie   string
+		point    int
+		location string
+		variant  int
+		sticky   bool
+	}{
+		{
+			name:     "first visit to heavy variant",
+			point:    2,
+			location: "https://ya.ru",
+			variant:  1,
+		},
+		{
+			name:     "first visit to light variant",
+			point:    3,
+			location: "https://go.dev",
+			variant:  2,
+		},
+		{
+			name:     "returning visitor",
+			cookie:   "2",
+			location: "https://go.dev",
+			variant:  2,
+			sticky:   true,
+		},
+		{
+			name:     "returning visitor with unknown variant",
+			cookie:   "3",
+			point:    0,
+			location: "https://ya.ru",
+			variant:  1,
+		},
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			repo := mock_handlers.NewMockRepository(mockCtrl)
+			repo.EXPECT().Restore(gomock.Any(), "1111").Return(link, nil)
+			rec := mock_handlers.NewMockClickRecorder(mockCtrl)
+			done := make(chan clicks.Event, 1)
+			rec.EXPECT().Record(gomock.Any()).Do(func(e clicks.Event) { done <- e })
+
+			h := NewURLShortener(baseURL, repo, WithClickRecorder(rec))
+			h.pick = func(n int) int {
+				assert.Equal(t, 4, n)
+				return tt.point
+			}
+			r := chi.NewRouter()
+			r.Get("/{id}", h.HandleGet)
+			req := httptest.NewRequest(http.MethodGet, "/1111", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "variant_1111", Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			result := w.Result()
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, http.StatusMovedPermanently, result.StatusCode)
+			assert.Equal(t, tt.location, result.Header.Get("Location"))
+			// выпавший вариант не должен кэшироваться, иначе переходы мимо сервиса не попадут в статистику
+			assert.Equal(t, "private, no-cache", result.Header.Get("Cache-Control"))
+			if tt.sticky {
+				assert.Empty(t, result.Cookies())
+			} else {
+				require.Len(t, result.Cookies(), 1)
+				assert.Equal(t, "variant_1111", result.Cookies()[0].Name)
+				assert.Equal(t, "/1111", result.Cookies()[0].Path)
+			}
+			assert.Equal(t, tt.variant, (<-done).Variant)
+		})
+	}
+}
+
+func TestURLShortener_ShortenWithVariants(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	repo := mock_handlers.NewMockRepository(mockCtrl)
+	repo.EXPECT().Store(gomock.Any(), gomock.Any(), storages.Link{
+		URL:       "https://ya.ru",
+		Canonical: "variants 3*https://ya.ru/ 1*https://go.dev/",
+		Tags:      []string{},
+		Variants:  []storages.Variant{{URL: "https://ya.ru", Weight: 3}, {URL: "https://go.dev", Weight: 1}},
+	}).Return("1111", nil)
+
+	h := NewURLShortener(baseURL, repo)
+	for body, status := range map[string]int{
+		`{"variants": [{"url": "https://ya.ru", "weight": 3}, {"url": "https://go.dev"}]}`:            http.StatusCreated,
+		`{"url": "https://ya.ru", "variants": [{"url": "https://ya.ru"}, {"url": "https://go.dev"}]}`: http.StatusBadRequest,
+		`{"variants": [{"url": "https://ya.ru"}]}`:                                                    http.StatusBadRequest,
+		`{"variants": [{"url": "https://ya.ru", "weight": 1001}, {"url": "https://go.dev"}]}`:         http.StatusBadRequest,
+		`{"variants": [{"url": "https://ya.ru"}, {"url": "go.dev"}]}`:                                 http.StatusBadRequest,
+		`{"variants": [{"url": "https://ya.ru"}, {"url": "http://192.168.0.1/admin"}]}`:               http.StatusUnprocessableEntity,
+	} {
+		w := httptest.NewRecorder()
+		h.HandlePostShortenJSON(w, httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body)))
+		result := w.Result()
+		require.NoError(t, result.Body.Close())
+		assert.Equal(t, status, result.StatusCode, body)
+	}
+}
@@ -75,6 +75,20 @@ func GetUserID(ctx context.Context) string {
 	return ""
 }
 
+// RequestUserID возвращает ID пользователя, только если он пришел в запросе с валидной кукой.
+// Если кука была выдана в рамках текущего запроса, то возвращается пустая строка.
+func RequestUserID(r *http.Request) string {
+	user := GetUserID(r.Context())
+	if user == "" {
+		return ""
+	}
+	c, err := r.Cookie(UserIDCookie)
+	if err != nil || !strings.HasPrefix(c.Value, user+"|") {
+		return ""
+	}
+	return user
+}
+
 type SignedCookie struct {
 	*http.Cookie
 	BaseValue    string
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter ограничивает частоту запросов по ключу алгоритмом token bucket:
+// у каждого ключа есть корзина на burst запросов, которая пополняется со скоростью perMinute запросов в минуту.
+type RateLimiter struct {
+	rate    float64 // пополнение корзины, запросов в секунду
+	burst   float64
+	now     func() time.Time
+	buckets map[string]*bucket
+	// sweep - время следующей очистки полных корзин
+	sweep time.Time
+	mx    sync.Mutex
+}
+
+// bucket - корзина запросов одного ключа на момент last.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter создает RateLimiter. Если perMinute не больше нуля, запросы не ограничиваются.
+// Если burst не больше нуля, размер корзины равен perMinute.
+func NewRateLimiter(perMinute, burst int) *RateLimiter {
+	if burst <= 0 {
+		burst = perMinute
+	}
+	return &RateLimiter{
+		rate:    float64(perMinute) / time.Minute.Seconds(),
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow забирает по одному запросу из корзин всех ключей. Запрос пропускается, только если он есть в каждой корзине,
+// иначе ни одна корзина не тратится и возвращается время до появления запроса во всех корзинах.
+func (l *RateLimiter) Allow(keys ...string) (time.Duration, bool) {
+	if l == nil || l.rate <= 0 {
+		return 0, true
+	}
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	now := l.now()
+	l.cleanup(now)
+	buckets := make([]*bucket, 0, len(keys))
+	var wait time.Duration
+	for _, key := range keys {
+		b, ok := l.buckets[key]
+		if !ok {
+			b = &bucket{tokens: l.burst, last: now}
+			l.buckets[key] = b
+		}
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+		if b.tokens < 1 {
+			if w := time.Duration((1 - b.tokens) / l.rate * float64(time.Second)); w > wait {
+				wait = w
+			}
+		}
+		buckets = append(buckets, b)
+	}
+	if wait > 0 {
+		return wait, false
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return 0, true
+}
+
+// cleanup не чаще, чем наполняется пустая корзина, удаляет корзины, которые уже успели наполниться,
+// чтобы ключи не копились бесконечно. Полная корзина ничем не отличается от отсутствующей.
+func (l *RateLimiter) cleanup(now time.Time) {
+	if now.Before(l.sweep) {
+		return
+	}
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.sweep = now.Add(time.Duration(l.burst / l.rate * float64(time.Second)))
+}
+
+// RateLimit ограничивает частоту запросов клиента с помощью limiter, отклоненные запросы получают 429 и Retry-After.
+// Запрос тратит корзину IP клиента, а если в запросе пришла подписанная кука, то и корзину ее пользователя.
+// Новую куку можно получить в любой момент, поэтому без корзины IP смена куки обходила бы ограничение.
+// Кука, выданная в рамках текущего запроса, не учитывается.
+// Поэтому перед ним должны отработать UserCookie и middleware.RealIP из chi.
+func RateLimit(limiter *RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		middleware := func(w http.ResponseWriter, r *http.Request) {
+			keys := []string{"ip:" + ClientIP(r)}
+			if user := RequestUserID(r); user != "" {
+				keys = append(keys, "user:"+user)
+			}
+			if wait, ok := limiter.Allow(keys...); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(middleware)
+	}
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	now := time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC)
+	l := NewRateLimiter(6, 2)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		_, ok := l.Allow("a")
+		assert.True(t, ok, "burst request %d", i)
+	}
+	wait, ok := l.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 10*time.Second, wait)
+
+	// корзины ключей независимы
+	_, ok = l.Allow("b")
+	assert.True(t, ok)
+
+	// запрос по нескольким ключам не тратит корзины, если хоть одна пуста
+	wait, ok = l.Allow("c", "a")
+	assert.False(t, ok)
+	assert.Equal(t, 10*time.Second, wait)
+	_, ok = l.Allow("c", "c")
+	assert.True(t, ok)
+	_, ok = l.Allow("c")
+	assert.False(t, ok)
+
+	now = now.Add(5 * time.Second)
+	wait, ok = l.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 5*time.Second, wait)
+
+	now = now.Add(5 * time.Second)
+	_, ok = l.Allow("a")
+	assert.True(t, ok)
+
+	// после паузы корзина наполняется не больше, чем на burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		_, ok = l.Allow("a")
+		assert.True(t, ok)
+	}
+	_, ok = l.Allow("a")
+	assert.False(t, ok)
+}
+
+func TestRateLimiter_Cleanup(t *testing.T) {
+	now := time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC)
+	l := NewRateLimiter(60, 0)
+	l.now = func() time.Time { return now }
+
+	l.Allow("a")
+	l.Allow("b")
+	require.Len(t, l.buckets, 2)
+	now = now.Add(time.Minute)
+	l.Allow("c")
+	assert.Len(t, l.buckets, 1)
+}
+
+func TestRateLimiter_Disabled(t *testing.T) {
+	l := NewRateLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		_, ok := l.Allow("a")
+		require.True(t, ok)
+	}
+	assert.Empty(t, l.buckets)
+}
+
+func TestRateLimiter_Concurrent(t *testing.T) {
+	l := NewRateLimiter(1, 50)
+	var (
+		wg      sync.WaitGroup
+		mx      sync.Mutex
+		allowed int
+	)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := l.Allow("a"); ok {
+				mx.Lock()
+				allowed++
+				mx.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 50, allowed)
+}
+
+func TestRateLimit(t *testing.T) {
+	cookie, rotated := NewUserIDSignedCookie(), NewUserIDSignedCookie()
+	tests := []struct {
+		name       string
+		remoteAddr string
+		cookie     *http.Cookie
+		want       int
+	}{
+		{
+			name:       "first request by ip",
+			remoteAddr: "10.0.0.1:54321",
+			want:       http.StatusOK,
+		},
+		{
+			name:       "second request by ip",
+			remoteAddr: "10.0.0.1:54322",
+			want:       http.StatusTooManyRequests,
+		},
+		{
+			name:       "another ip",
+			remoteAddr: "10.0.0.2:54321",
+			want:       http.StatusOK,
+		},
+		{
+			name:       "user from limited ip",
+			remoteAddr: "10.0.0.1:54321",
+			cookie:     cookie.Cookie,
+			want:       http.StatusTooManyRequests,
+		},
+		{
+			name:       "user from another ip",
+			remoteAddr: "10.0.0.3:54321",
+			cookie:     cookie.Cookie,
+			want:       http.StatusOK,
+		},
+		{
+			name:       "same user from another ip",
+			remoteAddr: "10.0.0.4:54321",
+			cookie:     cookie.Cookie,
+			want:       http.StatusTooManyRequests,
+		},
+		{
+			name:       "rotated cookie from the same ip",
+			remoteAddr: "10.0.0.3:54321",
+			cookie:     rotated.Cookie,
+			want:       http.StatusTooManyRequests,
+		},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := UserCookie(RateLimit(NewRateLimiter(1, 0))(next))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/shorten", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			assert.Equal(t, tt.want, w.Code)
+			if tt.want == http.StatusTooManyRequests {
+				assert.Equal(t, "60", w.Header().Get("Retry-After"))
+			} else {
+				assert.Empty(t, w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP возвращает IP адрес клиента.
+// Рассчитывает на то, что перед ним отработал middleware.RealIP из chi,
+// который подменяет RemoteAddr значением из X-Real-IP или X-Forwarded-For.
+// RemoteAddr может прийти как с портом, так и без него.
+func ClientIP(r *http.Request) string {
+	addr := strings.TrimSpace(r.RemoteAddr)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TrustedSubnet пропускает только запросы клиентов из доверенной подсети, заданной в нотации CIDR.
+// IP клиента определяется через ClientIP, поэтому перед ним должен отработать middleware.RealIP из chi.
+// Если подсеть не задана или задана с ошибкой, то доступ запрещен всем.
+func TrustedSubnet(cidr string) func(http.Handler) http.Handler {
+	var subnet *net.IPNet
+	if cidr != "" {
+		var err error
+		_, subnet, err = net.ParseCIDR(cidr)
+		if err != nil {
+			log.Err(err).Msgf("invalid trusted subnet %s, access to internal API is denied", cidr)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		middleware := func(w http.ResponseWriter, r *http.Request) {
+			ip := net.ParseIP(ClientIP(r))
+			if subnet == nil || ip == nil || !subnet.Contains(ip) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(middleware)
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrustedSubnet(t *testing.T) {
+	tests := []struct {
+		name       string
+		cidr       string
+		remoteAddr string
+		want       int
+	}{
+		{
+			name:       "inside subnet",
+			cidr:       "192.168.1.0/24",
+			remoteAddr: "192.168.1.15:54321",
+			want:       http.StatusOK,
+		},
+		{
+			name:       "outside subnet",
+			cidr:       "192.168.1.0/24",
+			remoteAddr: "10.0.0.1:54321",
+			want:       http.StatusForbidden,
+		},
+		{
+			name:       "ipv6 without port",
+			cidr:       "fd00::/8",
+			remoteAddr: "fd00::1",
+			want:       http.StatusOK,
+		},
+		{
+			name:       "subnet is not set",
+			cidr:       "",
+			remoteAddr: "127.0.0.1:54321",
+			want:       http.StatusForbidden,
+		},
+		{
+			name:       "invalid subnet",
+			cidr:       "192.168.1.0",
+			remoteAddr: "192.168.1.0:54321",
+			want:       http.StatusForbidden,
+		},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+			r.RemoteAddr = tt.remoteAddr
+			w := httptest.NewRecorder()
+			TrustedSubnet(tt.cidr)(next).ServeHTTP(w, r)
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package password
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter ограничивает число неудачных попыток ввода пароля по ключу, например по ссылке и клиенту.
+// После limit попыток без успешной попытки запрещаются до конца окна window, которое отсчитывается от первой попытки.
+type Limiter struct {
+	limit    int
+	window   time.Duration
+	now      func() time.Time
+	failures map[string]*failures
+	// sweep - время следующей очистки истекших окон
+	sweep time.Time
+	mx    sync.Mutex
+}
+
+// failures - занятые и не отмененные успехом попытки в текущем окне.
+type failures struct {
+	count int
+	reset time.Time
+}
+
+// NewLimiter создает Limiter. Если limit не больше нуля, попытки не ограничиваются.
+func NewLimiter(limit int, window time.Duration) *Limiter {
+	return &Limiter{limit: limit, window: window, now: time.Now, failures: make(map[string]*failures)}
+}
+
+// Reserve занимает попытку ввода пароля до его проверки. Попытка считается неудачной,
+// пока ее не отменит Reset, поэтому параллельные попытки не могут обойти ограничение, пока идет медленная проверка.
+// Если попытки исчерпаны, то возвращает время до окончания запрета.
+func (l *Limiter) Reserve(key string) (time.Duration, bool) {
+	if l.limit <= 0 {
+		return 0, true
+	}
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	now := l.now()
+	l.cleanup(now)
+	f, ok := l.failures[key]
+	if !ok || !now.Before(f.reset) {
+		f = &failures{reset: now.Add(l.window)}
+		l.failures[key] = f
+	}
+	if f.count >= l.limit {
+		return f.reset.Sub(now), false
+	}
+	f.count++
+	return 0, true
+}
+
+// Reset забывает занятые попытки после успешной.
+func (l *Limiter) Reset(key string) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	delete(l.failures, key)
+}
+
+// cleanup не чаще раза в окно удаляет истекшие окна, чтобы ключи не копились бесконечно.
+func (l *Limiter) cleanup(now time.Time) {
+	if now.Before(l.sweep) {
+		return
+	}
+	for key, f := range l.failures {
+		if !now.Before(f.reset) {
+			delete(l.failures, key)
+		}
+	}
+	l.sweep = now.Add(l.window)
+}
@@ -0,0 +1,85 @@
+// Package password хранит пароли коротких ссылок в виде соленого хэша PBKDF2-HMAC-SHA256
+// и ограничивает число неудачных попыток ввода пароля.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	scheme = "pbkdf2-sha256"
+	// iterations - число итераций для новых хэшей, у сохраненных хэшей оно записано в самом хэше
+	iterations = 50000
+	saltLength = 16
+	keyLength  = 32
+	maxLength  = 128
+)
+
+var (
+	ErrPasswordIsTooLong = errors.New("password must be at most 128 characters")
+	ErrInvalidHash       = errors.New("invalid password hash")
+)
+
+// Validate проверяет длину пароля.
+func Validate(password string) error {
+	if utf8.RuneCountInString(password) > maxLength {
+		return ErrPasswordIsTooLong
+	}
+	return nil
+}
+
+// Hash возвращает соленый хэш пароля в виде pbkdf2-sha256$<итерации>$<соль>$<ключ>,
+// соль и ключ кодируются в base64 без выравнивания.
+func Hash(password string) (string, error) {
+	if err := Validate(password); err != nil {
+		return "", err
+	}
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2.Key([]byte(password), salt, iterations, keyLength, sha256.New)
+	return fmt.Sprintf("%s$%d$%s$%s", scheme, iterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify проверяет пароль по хэшу, полученному через Hash. Некорректный хэш не подходит ни к какому паролю.
+func Verify(hash, password string) bool {
+	iter, salt, key, err := parse(hash)
+	if err != nil {
+		return false
+	}
+	got := pbkdf2.Key([]byte(password), salt, iter, len(key), sha256.New)
+	return subtle.ConstantTimeCompare(got, key) == 1
+}
+
+// parse разбирает хэш на число итераций, соль и ключ.
+func parse(hash string) (iter int, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != scheme {
+		return 0, nil, nil, ErrInvalidHash
+	}
+	iter, err = strconv.Atoi(parts[1])
+	if err != nil || iter <= 0 {
+		return 0, nil, nil, ErrInvalidHash
+	}
+	salt, err = base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return 0, nil, nil, ErrInvalidHash
+	}
+	key, err = base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return 0, nil, nil, ErrInvalidHash
+	}
+	return iter, salt, key, nil
+}
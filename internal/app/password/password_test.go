@@ -0,0 +1,109 @@
+package password
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	// векторы RFC 7914, раздел 11, в формате Hash
+	tests := []struct {
+		password string
+		hash     string
+	}{
+		{
+			password: "passwd",
+			hash:     "pbkdf2-sha256$1$c2FsdA$VawEblbjCJ/sFpHCJUS2BflBhSFt3gRl5oudV8INrLxJypzM8Xm2RZkWZLOdd+8xfHG4RbHjC9UJESBB06GXgw",
+		},
+		{
+			password: "Password",
+			hash:     "pbkdf2-sha256$80000$TmFDbA$TdzY9guYviGDDO5e8icB+WQaRBjQTAQUrv8Ih2s0q1ah1CWhIlgzVJrbhBtRybMXaicr3ruh0HhHj2Kzl/M8jQ",
+		},
+	}
+	for _, tt := range tests {
+		assert.True(t, Verify(tt.hash, tt.password), tt.password)
+		assert.False(t, Verify(tt.hash, tt.password+"!"), tt.password)
+	}
+}
+
+func TestHashVerify(t *testing.T) {
+	hash, err := Hash("s3cret")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "pbkdf2-sha256$50000$"))
+	assert.True(t, Verify(hash, "s3cret"))
+	assert.False(t, Verify(hash, "S3cret"))
+	assert.False(t, Verify(hash, ""))
+
+	// одинаковые пароли дают разные хэши благодаря соли
+	other, err := Hash("s3cret")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+
+	for _, broken := range []string{"", "s3cret", "md5$1$c2FsdA$a2V5", "pbkdf2-sha256$0$c2FsdA$a2V5", "pbkdf2-sha256$1$c2FsdA$"} {
+		assert.False(t, Verify(broken, "s3cret"), broken)
+	}
+
+	_, err = Hash(strings.Repeat("п", maxLength+1))
+	assert.ErrorIs(t, err, ErrPasswordIsTooLong)
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter(2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	_, ok := l.Reserve("a")
+	assert.True(t, ok)
+	now = now.Add(10 * time.Second)
+	_, ok = l.Reserve("a")
+	assert.True(t, ok)
+	retry, ok := l.Reserve("a")
+	assert.False(t, ok)
+	assert.Equal(t, 50*time.Second, retry)
+	_, ok = l.Reserve("b")
+	assert.True(t, ok)
+
+	// окно отсчитывается от первой попытки
+	now = now.Add(50 * time.Second)
+	_, ok = l.Reserve("a")
+	assert.True(t, ok)
+
+	_, ok = l.Reserve("b")
+	assert.True(t, ok)
+	l.Reset("b")
+	_, ok = l.Reserve("b")
+	assert.True(t, ok)
+
+	unlimited := NewLimiter(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		_, ok = unlimited.Reserve("a")
+		assert.True(t, ok)
+	}
+}
+
+func TestLimiter_Concurrent(t *testing.T) {
+	l := NewLimiter(5, time.Minute)
+	var (
+		wg      sync.WaitGroup
+		mx      sync.Mutex
+		allowed int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := l.Reserve("a"); ok {
+				mx.Lock()
+				allowed++
+				mx.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 5, allowed)
+}
@@ -0,0 +1,238 @@
+// Package policy проверяет оригинальные ссылки на соответствие правилам сервиса:
+// допустимые схемы, списки разрешенных и запрещенных доменов, запрет ссылок на внутренние адреса
+// и на сам сервис.
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrDestinationNotAllowed = errors.New("destination is not allowed")
+	ErrSchemeNotAllowed      = fmt.Errorf("%w: scheme is not allowed", ErrDestinationNotAllowed)
+	ErrDomainNotAllowed      = fmt.Errorf("%w: domain is not allowed", ErrDestinationNotAllowed)
+	ErrPrivateAddress        = fmt.Errorf("%w: private or loopback address", ErrDestinationNotAllowed)
+	ErrSelfReference         = fmt.Errorf("%w: link points to the shortener itself", ErrDestinationNotAllowed)
+	ErrUnresolvableHost      = fmt.Errorf("%w: host can't be resolved", ErrDestinationNotAllowed)
+)
+
+// defaultPorts - порты по умолчанию, нужны для сравнения адресов с явным и неявным портом.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Resolver - разрешение имен хостов, реализуется net.Resolver.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// Policy - правила, которым должна соответствовать оригинальная ссылка. Нулевое значение разрешает все.
+type Policy struct {
+	// Schemes - разрешенные схемы в нижнем регистре, пустой список разрешает любые.
+	Schemes []string
+	// Allow - домены, на которые могут вести ссылки, пустой список разрешает любые домены не из Deny.
+	// Шаблон *.example.com соответствует поддоменам example.com, но не ему самому, * - любому домену.
+	// Интернационализованные домены задаются в Punycode.
+	Allow []string
+	// Deny - запрещенные домены в том же формате, запрет важнее разрешения.
+	Deny []string
+	// BlockPrivate - запрещать ссылки на loopback, частные, link-local и прочие внутренние адреса.
+	BlockPrivate bool
+	// Resolver - если задан, то адреса, в которые разрешается имя хоста, тоже проверяются на BlockPrivate.
+	Resolver Resolver
+	// Self - базовый адрес сервиса, ссылки на него запрещены, т.к. создают петли перенаправлений.
+	Self string
+}
+
+// Default возвращает правила по умолчанию: только http и https, без ссылок на внутренние адреса.
+func Default() Policy {
+	return Policy{Schemes: []string{"http", "https"}, BlockPrivate: true}
+}
+
+// Check проверяет оригинальную ссылку. Нарушения возвращаются ошибками, обернутыми в ErrDestinationNotAllowed.
+func (p Policy) Check(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if len(p.Schemes) > 0 && !contains(p.Schemes, scheme) {
+		return fmt.Errorf("%w: %s", ErrSchemeNotAllowed, scheme)
+	}
+
+	host := normalizeHost(u.Hostname())
+	if matchAny(p.Deny, host) || len(p.Allow) > 0 && !matchAny(p.Allow, host) {
+		return fmt.Errorf("%w: %s", ErrDomainNotAllowed, host)
+	}
+	if p.isSelf(scheme, host, u.Port()) {
+		return fmt.Errorf("%w: %s", ErrSelfReference, p.Self)
+	}
+	if p.BlockPrivate {
+		return p.checkAddress(ctx, host)
+	}
+	return nil
+}
+
+// isSelf проверяет, что ссылка ведет на тот же хост и порт, что и базовый адрес сервиса.
+func (p Policy) isSelf(scheme, host, port string) bool {
+	if p.Self == "" {
+		return false
+	}
+	self, err := url.Parse(p.Self)
+	if err != nil {
+		return false
+	}
+	if port == "" {
+		port = defaultPorts[scheme]
+	}
+	selfPort := self.Port()
+	if selfPort == "" {
+		selfPort = defaultPorts[strings.ToLower(self.Scheme)]
+	}
+	return host == normalizeHost(self.Hostname()) && port == selfPort
+}
+
+// checkAddress запрещает внутренние адреса, заданные явно, имена localhost и, если задан Resolver,
+// имена, которые разрешаются во внутренние адреса.
+func (p Policy) checkAddress(ctx context.Context, host string) error {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	if ip := parseIP(host); ip != nil {
+		if IsPrivate(ip) {
+			return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+		}
+		return nil
+	}
+	if p.Resolver == nil {
+		return nil
+	}
+
+	addrs, err := p.Resolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("%w: %s", ErrUnresolvableHost, host)
+	}
+	for _, addr := range addrs {
+		if IsPrivate(addr.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrPrivateAddress, host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// cgnat - адреса операторского NAT, RFC 6598.
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsPrivate проверяет, что адрес не маршрутизируется в интернете.
+func IsPrivate(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		cgnat.Contains(ip)
+}
+
+// parseIP разбирает IPv6 и IPv4 адреса, включая формы, которые браузеры тоже считают адресами:
+// 2130706433, 0x7f.1, 0177.0.0.1.
+func parseIP(host string) net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip
+	}
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return nil
+	}
+	nums := make([]uint64, len(parts))
+	for i, part := range parts {
+		n, ok := parseIPv4Part(part)
+		if !ok {
+			return nil
+		}
+		nums[i] = n
+	}
+	// последняя часть занимает все оставшиеся байты адреса
+	last := len(nums) - 1
+	if nums[last] >= 1<<(8*(4-last)) {
+		return nil
+	}
+	addr := nums[last]
+	for i := 0; i < last; i++ {
+		if nums[i] > 255 {
+			return nil
+		}
+		addr |= nums[i] << (8 * (3 - i))
+	}
+	return net.IPv4(byte(addr>>24), byte(addr>>16), byte(addr>>8), byte(addr))
+}
+
+// parseIPv4Part разбирает часть IPv4 адреса в десятичной, шестнадцатеричной (0x) или восьмеричной (0) записи.
+func parseIPv4Part(s string) (uint64, bool) {
+	base := uint64(10)
+	switch {
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		base, s = 16, s[2:]
+	case len(s) > 1 && s[0] == '0':
+		base, s = 8, s[1:]
+	}
+	if s == "" {
+		return 0, base == 16
+	}
+	var n uint64
+	for _, c := range s {
+		var d uint64
+		switch {
+		case c >= '0' && c <= '9':
+			d = uint64(c - '0')
+		case c >= 'a' && c <= 'f':
+			d = uint64(c-'a') + 10
+		case c >= 'A' && c <= 'F':
+			d = uint64(c-'A') + 10
+		default:
+			return 0, false
+		}
+		if d >= base {
+			return 0, false
+		}
+		n = n*base + d
+		if n > 1<<32 {
+			return 0, false
+		}
+	}
+	return n, true
+}
+
+// matchAny проверяет соответствие хоста хотя бы одному шаблону домена.
+func matchAny(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		pattern = normalizeHost(pattern)
+		switch {
+		case pattern == "*":
+			return true
+		case strings.HasPrefix(pattern, "*."):
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+		case host == pattern:
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHost приводит хост к нижнему регистру и убирает завершающую точку.
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
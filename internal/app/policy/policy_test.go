@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubResolver разрешает имена по таблице, остальные имена не разрешаются.
+type stubResolver map[string]string
+
+func (r stubResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	ip, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return []net.IPAddr{{IP: net.ParseIP(ip)}}, nil
+}
+
+func TestPolicy_Check(t *testing.T) {
+	p := Default()
+	p.Self = "http://localhost:8080/"
+	p.Deny = []string{"*.evil.example", "bad.example"}
+
+	allowList := Default()
+	allowList.Allow = []string{"*.ya.ru", "go.dev"}
+
+	resolving := Default()
+	resolving.Resolver = stubResolver{"public.example": "93.184.216.34", "internal.example": "10.0.0.1"}
+
+	tests := []struct {
+		name    string
+		policy  Policy
+		url     string
+		wantErr error
+	}{
+		{name: "public", policy: p, url: "https://ya.ru/search"},
+		{name: "javascript", policy: p, url: "javascript://x/%0Aalert(1)", wantErr: ErrSchemeNotAllowed},
+		{name: "file", policy: p, url: "file://host/etc/passwd", wantErr: ErrSchemeNotAllowed},
+		{name: "ftp", policy: p, url: "ftp://ftp.example/pub", wantErr: ErrSchemeNotAllowed},
+		{name: "upper case scheme", policy: p, url: "HTTPS://ya.ru"},
+		{name: "denied subdomain", policy: p, url: "http://a.b.EVIL.example/", wantErr: ErrDomainNotAllowed},
+		{name: "wildcard is not apex", policy: p, url: "http://evil.example/"},
+		{name: "denied exact", policy: p, url: "http://bad.example./", wantErr: ErrDomainNotAllowed},
+		{name: "allowed subdomain", policy: allowList, url: "https://mail.ya.ru/"},
+		{name: "not in allow list", policy: allowList, url: "https://ya.ru/", wantErr: ErrDomainNotAllowed},
+		{name: "allowed exact", policy: allowList, url: "https://go.dev/doc"},
+		{name: "self", policy: p, url: "http://LOCALHOST:8080/abc", wantErr: ErrSelfReference},
+		{name: "loopback", policy: p, url: "http://127.0.0.1/", wantErr: ErrPrivateAddress},
+		{name: "localhost other port", policy: p, url: "http://localhost:9000/", wantErr: ErrPrivateAddress},
+		{name: "private", policy: p, url: "http://192.168.1.1/admin", wantErr: ErrPrivateAddress},
+		{name: "ipv6 loopback", policy: p, url: "http://[::1]/", wantErr: ErrPrivateAddress},
+		{name: "link local", policy: p, url: "http://169.254.169.254/latest/meta-data", wantErr: ErrPrivateAddress},
+		{name: "decimal loopback", policy: p, url: "http://2130706433/", wantErr: ErrPrivateAddress},
+		{name: "hex loopback", policy: p, url: "http://0x7f.1/", wantErr: ErrPrivateAddress},
+		{name: "octal private", policy: p, url: "http://012.0.0.1/", wantErr: ErrPrivateAddress},
+		{name: "public ip", policy: p, url: "http://8.8.8.8/"},
+		{name: "private allowed", policy: Policy{}, url: "http://10.0.0.1/"},
+		{name: "resolves to public", policy: resolving, url: "http://public.example/"},
+		{name: "resolves to private", policy: resolving, url: "http://internal.example/", wantErr: ErrPrivateAddress},
+		{name: "unresolvable", policy: resolving, url: "http://nowhere.example/", wantErr: ErrUnresolvableHost},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(context.Background(), tt.url)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.ErrorIs(t, err, ErrDestinationNotAllowed)
+		})
+	}
+}
+
+func Test_parseIP(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "127.0.0.1", want: "127.0.0.1"},
+		{host: "2130706433", want: "127.0.0.1"},
+		{host: "0x7f.0.0.1", want: "127.0.0.1"},
+		{host: "127.1", want: "127.0.0.1"},
+		{host: "10.1.2", want: "10.1.0.2"},
+		{host: "0300.0250.0.1", want: "192.168.0.1"},
+		{host: "example.com"},
+		{host: "256.0.0.1"},
+		{host: "4294967296"},
+		{host: "1.2.3.4.5"},
+		{host: "08.0.0.1"},
+	}
+	for _, tt := range tests {
+		got := parseIP(tt.host)
+		if tt.want == "" {
+			assert.Nil(t, got, tt.host)
+			continue
+		}
+		assert.Equal(t, tt.want, got.String(), tt.host)
+	}
+}
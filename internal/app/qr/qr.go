@@ -0,0 +1,75 @@
+// Package qr кодирует данные в QR код с помощью github.com/skip2/go-qrcode
+// и отрисовывает его в PNG и SVG с заданными размером и полем.
+package qr
+
+import (
+	"errors"
+	"fmt"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Level - уровень коррекции ошибок: какую долю поврежденных модулей код переживает.
+type Level int
+
+const (
+	// L - около 7%.
+	L Level = iota
+	// M - около 15%.
+	M
+	// Q - около 25%.
+	Q
+	// H - около 30%.
+	H
+)
+
+var ErrDataTooLong = errors.New("data is too long for QR code")
+
+// ParseLevel возвращает уровень коррекции по его букве L, M, Q или H.
+func ParseLevel(s string) (Level, error) {
+	switch s {
+	case "L", "l":
+		return L, nil
+	case "M", "m":
+		return M, nil
+	case "Q", "q":
+		return Q, nil
+	case "H", "h":
+		return H, nil
+	}
+	return 0, fmt.Errorf("unknown error correction level %q, must be L, M, Q or H", s)
+}
+
+// recoveryLevel возвращает соответствующий уровень коррекции библиотеки.
+func (l Level) recoveryLevel() qrcode.RecoveryLevel {
+	return [...]qrcode.RecoveryLevel{qrcode.Low, qrcode.Medium, qrcode.High, qrcode.Highest}[l]
+}
+
+// Code - матрица модулей QR кода без поля, true - темный модуль.
+type Code struct {
+	// Version - версия кода от 1 до 40, размер стороны 17 + 4*Version модулей.
+	Version int
+	Level   Level
+	modules [][]bool
+}
+
+// Size возвращает размер стороны кода в модулях без учета поля.
+func (c *Code) Size() int {
+	return len(c.modules)
+}
+
+// Dark проверяет, что модуль в столбце x и строке y темный.
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// Encode кодирует данные в QR код минимальной версии, вмещающей их на уровне коррекции level.
+func Encode(data []byte, level Level) (*Code, error) {
+	q, err := qrcode.New(string(data), level.recoveryLevel())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDataTooLong, err)
+	}
+	// поле рисуется при отрисовке, его размер задает пользователь
+	q.DisableBorder = true
+	return &Code{Version: q.VersionNumber, Level: level, modules: q.Bitmap()}, nil
+}
@@ -0,0 +1,87 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		level       Level
+		wantVersion int
+	}{
+		{name: "short url", data: "http://localhost:8080/abc123", level: M, wantVersion: 3},
+		{name: "low correction", data: "http://localhost:8080/abc123", level: L, wantVersion: 2},
+		{name: "high correction", data: "http://localhost:8080/abc123", level: H, wantVersion: 4},
+		{name: "version info", data: strings.Repeat("x", 150), level: Q, wantVersion: 10},
+		{name: "long count", data: strings.Repeat("y", 500), level: L, wantVersion: 15},
+		{name: "max", data: strings.Repeat("z", 2953), level: L, wantVersion: 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Encode([]byte(tt.data), tt.level)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVersion, c.Version)
+			assert.Equal(t, 17+4*tt.wantVersion, c.Size())
+			assertFinders(t, c)
+		})
+	}
+
+	_, err := Encode(make([]byte, 2954), L)
+	assert.ErrorIs(t, err, ErrDataTooLong)
+}
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]Level{"L": L, "m": M, "Q": Q, "h": H} {
+		level, err := ParseLevel(s)
+		require.NoError(t, err)
+		assert.Equal(t, want, level)
+	}
+	_, err := ParseLevel("X")
+	assert.Error(t, err)
+}
+
+func TestRender(t *testing.T) {
+	c, err := Encode([]byte("http://localhost:8080/abc123"), M)
+	require.NoError(t, err)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, c.PNG(&buf, 256, 4))
+	img, err := png.Decode(&buf)
+	require.NoError(t, err)
+	// 29 модулей и 8 на поле, по 6 пикселей на модуль
+	assert.Equal(t, 222, img.Bounds().Dx())
+	assert.Equal(t, 222, img.Bounds().Dy())
+	r, _, _, _ := img.At(0, 0).RGBA()
+	assert.Equal(t, uint32(0xffff), r, "margin is light")
+	r, _, _, _ = img.At(4*6, 4*6).RGBA()
+	assert.Equal(t, uint32(0), r, "finder corner is dark")
+
+	buf.Reset()
+	require.NoError(t, c.SVG(&buf, 300, 2))
+	svg := buf.String()
+	assert.Contains(t, svg, `width="300" height="300" viewBox="0 0 33 33"`)
+	assert.Contains(t, svg, `M2,2h1v1h-1z`)
+}
+
+// assertFinders проверяет поисковые узоры в трех углах: код начинается сразу с них, без поля.
+func assertFinders(t *testing.T, c *Code) {
+	t.Helper()
+	size := c.Size()
+	for _, corner := range [][2]int{{0, 0}, {size - 7, 0}, {0, size - 7}} {
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				ring := dx == 0 || dx == 6 || dy == 0 || dy == 6
+				center := dx >= 2 && dx <= 4 && dy >= 2 && dy <= 4
+				assert.Equal(t, ring || center, c.Dark(corner[0]+dx, corner[1]+dy))
+			}
+		}
+	}
+}
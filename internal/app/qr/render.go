@@ -0,0 +1,56 @@
+package qr
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// PNG рисует код в изображение со стороной не больше size пикселей и полем margin модулей.
+// Модуль занимает целое число пикселей, но не меньше одного, поэтому изображение может выйти меньше size.
+func (c *Code) PNG(w io.Writer, size int, margin int) error {
+	modules := c.Size() + 2*margin
+	scale := size / modules
+	if scale < 1 {
+		scale = 1
+	}
+	side := modules * scale
+
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < c.Size(); y++ {
+		for x := 0; x < c.Size(); x++ {
+			if !c.modules[y][x] {
+				continue
+			}
+			for py := (y + margin) * scale; py < (y+margin+1)*scale; py++ {
+				for px := (x + margin) * scale; px < (x+margin+1)*scale; px++ {
+					img.SetColorIndex(px, py, 1)
+				}
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// SVG рисует код векторно: каждый темный модуль - квадрат 1x1 в координатах viewBox, size задает размер в пикселях.
+func (c *Code) SVG(w io.Writer, size int, margin int) error {
+	modules := c.Size() + 2*margin
+	var path strings.Builder
+	for y := 0; y < c.Size(); y++ {
+		for x := 0; x < c.Size(); x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(&path, "M%d,%dh1v1h-1z", x+margin, y+margin)
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%[1]d" height="%[1]d" viewBox="0 0 %[2]d %[2]d" shape-rendering="crispEdges">
+<rect width="100%%" height="100%%" fill="#FFFFFF"/>
+<path d="%[3]s" fill="#000000"/>
+</svg>
+`, size, modules, path.String())
+	return err
+}
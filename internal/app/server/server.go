@@ -4,6 +4,7 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 
+	"github.com/UndeadDemidov/yandex-praktikum/cfg"
 	"github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers"
 	midware "github.com/UndeadDemidov/yandex-praktikum/internal/app/middleware"
 	"github.com/go-chi/chi/v5"
@@ -12,10 +13,11 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-// NewServer создает и возвращает новый сервер с указанным репозиторием коротких ссылок
-func NewServer(baseURL string, addr string, repo handlers.Repository) *http.Server {
+// NewServer создает и возвращает новый сервер с указанным репозиторием коротких ссылок.
+// Дополнительные зависимости обработчиков передаются через opts.
+func NewServer(config *cfg.Config, repo handlers.Repository, opts ...handlers.Option) *http.Server {
 	linkStore := repo
-	handler := handlers.NewURLShortener(baseURL, linkStore)
+	handler := handlers.NewURLShortener(config.BaseUrl, linkStore, opts...)
 
 	r := chi.NewRouter()
 	r.Use(middleware.Heartbeat("/health"))
@@ -25,26 +27,63 @@ func NewServer(baseURL string, addr string, repo handlers.Repository) *http.Serv
 	r.Use(midware.Decompress)
 	r.Use(midware.UserCookie)
 
+	// у каждой группы маршрутов свой лимит запросов, корзины клиентов в группах независимы;
+	// удаление, восстановление и изменение ссылок ограничиваются общим лимитом deleteLimit
+	shortenLimit := midware.RateLimit(midware.NewRateLimiter(config.RateLimitShorten, 0))
+	batchLimit := midware.RateLimit(midware.NewRateLimiter(config.RateLimitBatch, 0))
+	deleteLimit := midware.RateLimit(midware.NewRateLimiter(config.RateLimitDelete, 0))
+	redirectLimit := midware.RateLimit(midware.NewRateLimiter(config.RateLimitRedirect, 0))
+
 	r.Group(func(r chi.Router) {
-		r.Post("/", handler.HandlePostShortenPlain)
-		r.Post("/api/shorten", handler.HandlePostShortenJSON)
-		r.Get("/{id}", handler.HandleGet)
+		r.With(shortenLimit).Post("/", handler.HandlePostShortenPlain)
+		r.With(shortenLimit).Post("/api/shorten", handler.HandlePostShortenJSON)
+		r.Group(func(r chi.Router) {
+			r.Use(redirectLimit)
+			r.Get("/{id}", handler.HandleGet)
+			r.Head("/{id}", handler.HandleGet)
+			r.Get("/{id}/*", handler.HandleGet)
+			r.Head("/{id}/*", handler.HandleGet)
+			r.Post("/{id}", handler.HandleGet)
+			r.Post("/{id}/*", handler.HandleGet)
+		})
+		// /{id}/qr и /{id}/report точнее /{id}/*, поэтому хвосты qr и report зарезервированы
+		// за своими методами и в оригинальную ссылку не пробрасываются
+		r.Get("/{id}/qr", handler.HandleGetQR)
+		r.Post("/{id}/report", handler.HandlePostReport)
 		r.Get("/ping", handler.HeartBeat)
-		r.Delete("/api/user/urls", handler.HandleDelete)
+		r.With(deleteLimit).Delete("/api/user/urls", handler.HandleDelete)
+		r.With(deleteLimit).Post("/api/user/urls/restore", handler.HandleRestore)
 		r.NotFound(handler.HandleNotFound)
 		r.MethodNotAllowed(handler.HandleMethodNotAllowed)
 	})
 
 	r.Group(func(r chi.Router) {
 		r.Use(middleware.Compress(5))
-		r.Post("/api/shorten/batch", handler.HandlePostShortenBatch)
+		r.With(batchLimit).Post("/api/shorten/batch", handler.HandlePostShortenBatch)
 		r.Get("/api/user/urls", handler.HandleGetUserURLsBucket)
+		r.Get("/api/user/urls/top", handler.HandleGetTopLinks)
+		r.Get("/api/user/urls/{id}/clicks", handler.HandleGetClickSeries)
+		r.With(deleteLimit).Patch("/api/user/urls/{id}", handler.HandlePatchUserURL)
+		r.Get("/api/user/utm", handler.HandleGetUTMTemplates)
+		r.Put("/api/user/utm/{name}", handler.HandlePutUTMTemplate)
+		r.Delete("/api/user/utm/{name}", handler.HandleDeleteUTMTemplate)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(midware.TrustedSubnet(config.TrustedSubnet))
+		r.Get("/api/internal/stats", handler.HandleGetInternalStats)
+		r.Get("/api/internal/reports", handler.HandleGetReports)
+		r.Put("/api/internal/urls/{id}/block", handler.HandleBlock)
+		r.Delete("/api/internal/urls/{id}/block", handler.HandleUnblock)
+		r.Get("/api/internal/quotas/{user}", handler.HandleGetQuota)
+		r.Put("/api/internal/quotas/{user}", handler.HandlePutQuota)
+		r.Delete("/api/internal/quotas/{user}", handler.HandleDeleteQuota)
 	})
 
 	r.Mount("/", http.DefaultServeMux)
 
 	s := &http.Server{
-		Addr:    addr,
+		Addr:    config.ServerAddress,
 		Handler: r,
 	}
 	return s
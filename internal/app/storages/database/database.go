@@ -3,13 +3,17 @@ package database
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers"
 	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
 	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+	"github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 )
 
@@ -29,22 +33,91 @@ const (
 						CREATE UNIQUE INDEX shortened_urls_id_uindex ON shortened_urls (id);
 						CREATE UNIQUE INDEX shortened_urls_original_url_uindex ON shortened_urls (original_url);
 						CREATE INDEX shortened_urls_user_id ON shortened_urls (user_id);`
+	// Миграции идемпотентны и выполняются при каждом старте, чтобы подтянуть структуру ранее созданной БД.
+	// Ссылкам, сохраненным до появления created_at и updated_at, достается нулевое время Go, как и в файловом хранилище,
+	// а now() становится значением по умолчанию только для новых ссылок
+	migrateStatement = `ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00';
+						ALTER TABLE shortened_urls ALTER COLUMN created_at SET DEFAULT now();
+						ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00';
+						ALTER TABLE shortened_urls ALTER COLUMN updated_at SET DEFAULT now();
+						CREATE INDEX IF NOT EXISTS shortened_urls_user_id_created_at ON shortened_urls (user_id, created_at, id);
+						ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS title VARCHAR NOT NULL DEFAULT '';
+						ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+						ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS notes VARCHAR NOT NULL DEFAULT '';
+						CREATE INDEX IF NOT EXISTS shortened_urls_tags ON shortened_urls USING GIN (tags);
+						ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS redirect_code SMALLINT NOT NULL DEFAULT 0;
+						ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS forward_query BOOLEAN NOT NULL DEFAULT FALSE;
+						ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS forward_path BOOLEAN NOT NULL DEFAULT FALSE;
+						ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS canonical_url VARCHAR;
+						UPDATE shortened_urls SET canonical_url = original_url WHERE canonical_url IS NULL;
+						ALTER TABLE shortened_urls ALTER COLUMN canonical_url SET NOT NULL;
+						CREATE UNIQUE INDEX IF NOT EXISTS shortened_urls_canonical_url_uindex ON shortened_urls (canonical_url);
+						DROP INDEX IF EXISTS shortened_urls_original_url_uindex;
+						ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS is_blocked BOOLEAN NOT NULL DEFAULT FALSE;
+						ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS password_hash VARCHAR NOT NULL DEFAULT '';
+						ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS max_clicks INTEGER NOT NULL DEFAULT 0;
+						ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS remaining_clicks INTEGER NOT NULL DEFAULT 0;
+						ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS not_before TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00';
+						ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS not_after TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00';
+						ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS variants JSONB NOT NULL DEFAULT '[]';
+						ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS rules JSONB NOT NULL DEFAULT '[]';`
+	// linkColumns - колонки ссылки в порядке приемников linkFields.
+	// Отсутствие границы окна активности хранится как нулевое время Go, чтобы не возиться с NULL
+	linkColumns = `id, original_url, canonical_url, created_at, updated_at, is_deleted, title, tags, notes, redirect_code, forward_query, forward_path, is_blocked, password_hash, max_clicks, remaining_clicks, not_before, not_after, variants, rules`
 	// Как говорит великий Том Кайт - если можно сделать одним SQL statement - сделай это!
-	// Если original_url уже есть, то возвращается его ID (независимо от user_id),
-	// Если original_url еще нет, то возвращается пустой row set
+	// Если canonical_url уже есть, то возвращается его ID (независимо от user_id),
+	// Если canonical_url еще нет, то возвращается пустой row set
 	storeQuery = `WITH inserted_rows AS (
-						INSERT INTO shortened_urls (id, user_id, original_url)
-        				VALUES ($1, $2, $3)
-        				ON CONFLICT (original_url) DO NOTHING
+						INSERT INTO shortened_urls (id, user_id, original_url, title, tags, notes, redirect_code, forward_query, forward_path, canonical_url, password_hash, max_clicks, remaining_clicks, not_before, not_after, variants, rules)
+        				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $12, $13, $14, $15, $16)
+        				ON CONFLICT (canonical_url) DO NOTHING
 						RETURNING id
 					  )
 						SELECT id
 						FROM shortened_urls
    						 WHERE NOT EXISTS (SELECT 1 FROM inserted_rows)
-   						   AND original_url=$3;`
-	restoreQuery    = `SELECT original_url, is_deleted FROM shortened_urls WHERE id=$1`
-	deleteStatement = `UPDATE shortened_urls SET is_deleted=TRUE WHERE user_id=$1 AND id=$2`
-	userBucketQuery = `SELECT id, original_url FROM shortened_urls WHERE user_id=$1`
+   						   AND canonical_url=$10;`
+	restoreQuery         = `SELECT user_id, ` + linkColumns + ` FROM shortened_urls WHERE id=$1`
+	markDeletedStatement = `UPDATE shortened_urls SET is_deleted=$3, updated_at=now() WHERE user_id=$1 AND id=$2 AND is_deleted<>$3`
+	// Условия и порядок подставляются в userLinksQuery только из заранее заданных вариантов, см. buildUserLinksQuery
+	userLinksQuery = `SELECT ` + linkColumns + `
+						FROM shortened_urls
+					   WHERE user_id=$1
+						 AND ($2 = '' OR lower(substring(original_url from '^[^:]+://(?:[^/?#@]*@)?([^/?#:]*)')) LIKE '%%' || $2 || '%%' ESCAPE '\')
+						 AND ($3 = '%s' OR is_deleted = ($3 = '%s'))
+						 AND ($5 = '' OR tags @> ARRAY[$5::TEXT])
+						 %s
+					   ORDER BY %s
+					   LIMIT $4`
+	selectForUpdateQuery = `SELECT ` + linkColumns + `
+							  FROM shortened_urls
+							 WHERE id=$1 AND user_id=$2
+							   FOR UPDATE`
+	findURLQuery    = `SELECT user_id, ` + linkColumns + ` FROM shortened_urls WHERE canonical_url=$1 AND id<>$2`
+	updateStatement = `UPDATE shortened_urls
+						  SET original_url=COALESCE($3, original_url),
+						      title=COALESCE($4, title),
+						      tags=COALESCE($5, tags),
+						      notes=COALESCE($6, notes),
+						      canonical_url=COALESCE($7, canonical_url),
+						      not_before=COALESCE($8, not_before),
+						      not_after=COALESCE($9, not_after),
+						      variants=CASE WHEN $3::VARCHAR IS NULL THEN variants ELSE '[]' END,
+						      rules=COALESCE($10, rules),
+						      updated_at=now()
+						WHERE id=$1 AND user_id=$2
+					RETURNING ` + linkColumns
+	setBlockedStatement = `UPDATE shortened_urls SET is_blocked=$2 WHERE id=$1`
+	// Остаток уменьшается одним UPDATE, поэтому параллельные переходы не расходуют больше max_clicks
+	consumeClickQuery = `UPDATE shortened_urls
+							SET remaining_clicks = remaining_clicks - 1
+						  WHERE id=$1 AND max_clicks > 0 AND remaining_clicks > 0
+					  RETURNING remaining_clicks`
+	clickLimitQuery = `SELECT max_clicks FROM shortened_urls WHERE id=$1`
+	findKeysQuery   = `SELECT canonical_url, id FROM shortened_urls WHERE canonical_url = ANY($1)`
+	countQuery      = `SELECT COUNT(1) FROM shortened_urls WHERE user_id=$1 AND NOT is_deleted`
+	statsQuery      = `SELECT COUNT(1), COUNT(DISTINCT user_id), COUNT(1) FILTER (WHERE is_deleted)
+						 FROM shortened_urls`
 
 	batchSize = 10
 )
@@ -53,7 +126,7 @@ const (
 // Выполнена простейшая реализация для сдачи работы.
 type Storage struct {
 	database *sql.DB
-	delBatch chan userID
+	marks    chan userID
 	done     chan bool
 }
 
@@ -67,11 +140,11 @@ func NewStorage(db *sql.DB) (st *Storage, err error) {
 		return &Storage{}, err
 	}
 
-	st.delBatch = make(chan userID)
+	st.marks = make(chan userID)
 	st.done = make(chan bool)
 	// Запускаем единственный consumer fanIn, в теории можно сделать пул consumers
 	// ToDo Нужно вырезать слой Service и там делать метод Run, где и будет запущен этот consumer
-	go st.unstoreConsume()
+	go st.markConsume()
 
 	return st, nil
 }
@@ -97,17 +170,18 @@ func createDB(db *sql.DB) (err error) {
 			return err
 		}
 	}
-	return nil
+	_, err = db.ExecContext(ctx, migrateStatement)
+	return err
 }
 
 // Store сохраняет ссылку в хранилище с указанным id. В случае конфликта c уже ранее сохраненным link
 // возвращает ошибку handlers.ErrLinkIsAlreadyShortened и id с раннего сохранения.
-func (s *Storage) Store(ctx context.Context, user string, link string) (id string, err error) {
+func (s *Storage) Store(ctx context.Context, user string, link storages.Link) (id string, err error) {
 	var actualID string
 	// две попытки для генерации уникального id
 	for i := 0; i < 2; i++ {
 		id = utils.NewUniqueID()
-		err = s.database.QueryRowContext(ctx, storeQuery, id, user, link).Scan(&actualID)
+		err = s.database.QueryRowContext(ctx, storeQuery, storeArgs(id, user, link)...).Scan(&actualID)
 		if err == nil || errors.Is(err, sql.ErrNoRows) {
 			break
 		}
@@ -124,51 +198,115 @@ func (s *Storage) Store(ctx context.Context, user string, link string) (id strin
 	return actualID, handlers.ErrLinkIsAlreadyShortened
 }
 
+// storeArgs возвращает параметры storeQuery
+func storeArgs(id, user string, link storages.Link) []interface{} {
+	tags := link.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return []interface{}{id, user, link.URL, link.Title, pq.Array(tags), link.Notes, link.Redirect,
+		link.ForwardQuery, link.ForwardPath, link.DedupKey(), link.PasswordHash, link.MaxClicks,
+		link.NotBefore, link.NotAfter, jsonField{&link.Variants}, jsonField{&link.Rules}}
+}
+
+// linkFields возвращает приемники для колонок linkColumns
+func linkFields(l *storages.Link) []interface{} {
+	return []interface{}{&l.ID, &l.URL, &l.Canonical, &l.Created, &l.Updated, &l.Deleted, &l.Title, pq.Array(&l.Tags), &l.Notes, &l.Redirect,
+		&l.ForwardQuery, &l.ForwardPath, &l.Blocked, &l.PasswordHash,
+		&l.MaxClicks, &l.RemainingClicks, &l.NotBefore, &l.NotAfter, jsonField{&l.Variants}, jsonField{&l.Rules}}
+}
+
+// jsonField - параметр и приемник JSONB колонки со списком, v - указатель на слайс.
+// Пустой список хранится как пустой JSON массив, а не NULL.
+type jsonField struct {
+	v interface{}
+}
+
+// Value возвращает JSON массив
+func (f jsonField) Value() (driver.Value, error) {
+	b, err := json.Marshal(f.v)
+	if err != nil {
+		return nil, err
+	}
+	if string(b) == "null" {
+		return "[]", nil
+	}
+	return string(b), nil
+}
+
+// Scan читает список из JSON массива
+func (f jsonField) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, f.v)
+	case string:
+		return json.Unmarshal([]byte(v), f.v)
+	}
+	return fmt.Errorf("can't scan JSON list from %T", src)
+}
+
+// foundLinkFields возвращает приемники для колонок user_id и далее как в linkFields
+func foundLinkFields(l *storages.Link) []interface{} {
+	return append([]interface{}{&l.User}, linkFields(l)...)
+}
+
 // Restore возвращает исходную ссылку по переданному короткому ID
-func (s *Storage) Restore(ctx context.Context, id string) (link string, err error) {
-	var deleted bool
-	err = s.database.QueryRowContext(ctx, restoreQuery, id).Scan(&link, &deleted)
+func (s *Storage) Restore(ctx context.Context, id string) (link storages.Link, err error) {
+	err = s.database.QueryRowContext(ctx, restoreQuery, id).Scan(foundLinkFields(&link)...)
 	switch {
 	case errors.Is(err, sql.ErrNoRows):
-		return "", fmt.Errorf(storages.ErrLinkNotFound, id)
+		return storages.Link{}, fmt.Errorf(storages.ErrLinkNotFound, handlers.ErrLinkIsNotFound, id)
 	case err != nil:
-		return "", err
-	case deleted:
-		return "", handlers.ErrLinkIsDeleted
+		return storages.Link{}, err
 	}
-
-	return
+	link.Created, link.Updated = link.Created.UTC(), link.Updated.UTC()
+	link.NotBefore, link.NotAfter = link.NotBefore.UTC(), link.NotAfter.UTC()
+	if link.Deleted {
+		return link, handlers.ErrLinkIsDeleted
+	}
+	return link, nil
 }
 
 // Unstore - помечает список ранее сохраненных ссылок удаленными
 // только тех ссылок, которые принадлежат пользователю
 func (s *Storage) Unstore(ctx context.Context, user string, ids []string) {
+	s.mark(ctx, user, ids, true)
+}
+
+// Undelete - снимает пометку удаления со списка ранее удаленных ссылок
+// только тех ссылок, которые принадлежат пользователю
+func (s *Storage) Undelete(ctx context.Context, user string, ids []string) {
+	s.mark(ctx, user, ids, false)
+}
+
+// mark отправляет ссылки на установку признака удаления deleted в общий consumer
+func (s *Storage) mark(ctx context.Context, user string, ids []string, deleted bool) {
 	ch := make(chan userID)
-	go s.unstoreProduce(ctx, ch, user, ids)
+	go s.markProduce(ctx, ch, user, ids, deleted)
 	// на каждого продюсера один воркер
 	// ToDo можно сделать пул воркеров
-	go s.unstoreWork(ch)
+	go s.markWork(ch)
 }
 
-func (s *Storage) unstoreProduce(_ context.Context, ch chan userID, user string, ids []string) {
+func (s *Storage) markProduce(_ context.Context, ch chan userID, user string, ids []string, deleted bool) {
 	// Делаем for и шлем каждый элемент в channel.
 	// Что успеет заслаться - то и обработается.
 	for i, id := range ids {
-		ch <- userID{User: user, ID: id}
+		ch <- userID{User: user, ID: id, Deleted: deleted}
 		log.Debug().Msgf("%v", i)
 	}
 	close(ch)
 }
 
-func (s *Storage) unstoreWork(ch chan userID) {
+func (s *Storage) markWork(ch chan userID) {
 	for uID := range ch {
-		s.delBatch <- uID
+		s.marks <- uID
 	}
 }
 
-// unstoreConsume собирает пакет определенного размера и выталкивает в БД.
+// markConsume собирает пакет определенного размера и выталкивает в БД.
 // Чтобы хвосты неполных пакетов не застревали, регулярно делаем flush
-func (s *Storage) unstoreConsume() {
+func (s *Storage) markConsume() {
 	flush := func() {
 		for {
 			time.Sleep(time.Second)
@@ -185,19 +323,19 @@ func (s *Storage) unstoreConsume() {
 		case <-s.done:
 			if i != 0 {
 				log.Debug().Msg(fmt.Sprint(buf[:i]))
-				err := s.unstoreBatch(buf[:i])
+				err := s.markBatch(buf[:i])
 				if err != nil {
 					log.Err(err).Send()
 				}
 				i = 0
 			}
-		case id, ok := <-s.delBatch:
+		case id, ok := <-s.marks:
 			if !ok {
 				return
 			}
 			if i == len(buf) {
 				log.Debug().Msg(fmt.Sprint(buf))
-				err := s.unstoreBatch(buf)
+				err := s.markBatch(buf)
 				if err != nil {
 					log.Err(err).Send()
 				}
@@ -209,7 +347,7 @@ func (s *Storage) unstoreConsume() {
 	}
 }
 
-func (s *Storage) unstoreBatch(ids []userID) error {
+func (s *Storage) markBatch(ids []userID) error {
 	// шаг 1 — объявляем транзакцию
 	tx, err := s.database.Begin()
 	if err != nil {
@@ -226,7 +364,7 @@ func (s *Storage) unstoreBatch(ids []userID) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
 	defer cancel()
 	// шаг 2 — готовим инструкцию
-	stmt, err := tx.PrepareContext(ctx, deleteStatement)
+	stmt, err := tx.PrepareContext(ctx, markDeletedStatement)
 	if err != nil {
 		return err
 	}
@@ -239,7 +377,7 @@ func (s *Storage) unstoreBatch(ids []userID) error {
 
 	// шаг 3 - выполняем задачу
 	for _, id := range ids {
-		_, err = stmt.ExecContext(ctx, id.User, id.ID)
+		_, err = stmt.ExecContext(ctx, id.User, id.ID, id.Deleted)
 		if err != nil {
 			return err
 		}
@@ -253,12 +391,143 @@ func (s *Storage) unstoreBatch(ids []userID) error {
 	return nil
 }
 
-// GetUserStorage возвращает map[id]link ранее сокращенных ссылок указанным пользователем
-func (s *Storage) GetUserStorage(ctx context.Context, user string) map[string]string {
-	rows, err := s.database.QueryContext(ctx, userBucketQuery, user)
+// Update изменяет ссылку id, принадлежащую пользователю.
+// В случае конфликта c уже ранее сохраненной оригинальной ссылкой возвращает ошибку handlers.ErrLinkIsAlreadyShortened
+// и ранее сохраненную ссылку.
+func (s *Storage) Update(ctx context.Context, user string, id string, patch storages.LinkPatch) (storages.Link, error) {
+	// шаг 1 — объявляем транзакцию
+	tx, err := s.database.BeginTx(ctx, nil)
+	if err != nil {
+		return storages.Link{}, err
+	}
+	// шаг 1.1 — если возникает ошибка, откатываем изменения
+	defer func() {
+		if err = tx.Rollback(); err != nil {
+			log.Debug().Err(err).Msg("can't rollback transaction, this error will be omitted")
+		}
+	}()
+
+	// шаг 2 — блокируем ссылку и проверяем владельца
+	l := storages.Link{User: user}
+	err = tx.QueryRowContext(ctx, selectForUpdateQuery, id, user).Scan(linkFields(&l)...)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return storages.Link{}, handlers.ErrLinkIsNotFound
+	case err != nil:
+		return storages.Link{}, err
+	case l.Deleted:
+		return storages.Link{}, handlers.ErrLinkIsDeleted
+	case patch.EmptiesWindow(l):
+		return storages.Link{}, handlers.ErrInvalidWindow
+	}
+
+	// шаг 3 — проверяем уникальность новой оригинальной ссылки
+	var canonical *string
+	if patch.URL != nil && *patch.URL != l.URL {
+		key := patch.DedupKey()
+		canonical = &key
+		var other storages.Link
+		err = tx.QueryRowContext(ctx, findURLQuery, key, id).
+			Scan(foundLinkFields(&other)...)
+		switch {
+		case err == nil:
+			return other, handlers.ErrLinkIsAlreadyShortened
+		case !errors.Is(err, sql.ErrNoRows):
+			return storages.Link{}, err
+		}
+	}
+
+	// шаг 4 — изменяем ссылку
+	var tags interface{}
+	if patch.Tags != nil {
+		tags = pq.Array(*patch.Tags)
+	}
+	err = tx.QueryRowContext(ctx, updateStatement, id, user,
+		nullString(patch.URL), nullString(patch.Title), tags, nullString(patch.Notes), nullString(canonical),
+		nullTime(patch.NotBefore), nullTime(patch.NotAfter), nullRules(patch.Rules)).Scan(linkFields(&l)...)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+		// ссылку успели сохранить параллельно
+		return storages.Link{}, handlers.ErrLinkIsAlreadyShortened
+	}
+	if err != nil {
+		return storages.Link{}, err
+	}
+	l.Created, l.Updated = l.Created.UTC(), l.Updated.UTC()
+	l.NotBefore, l.NotAfter = l.NotBefore.UTC(), l.NotAfter.UTC()
+
+	// шаг 5 — сохраняем изменения
+	return l, tx.Commit()
+}
+
+// SetBlocked устанавливает или снимает блокировку ссылки id, кому бы она ни принадлежала
+func (s *Storage) SetBlocked(ctx context.Context, id string, blocked bool) error {
+	res, err := s.database.ExecContext(ctx, setBlockedStatement, id, blocked)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf(storages.ErrLinkNotFound, handlers.ErrLinkIsNotFound, id)
+	}
+	return nil
+}
+
+// ConsumeClick уменьшает остаток переходов по ссылке id с ограничением переходов
+func (s *Storage) ConsumeClick(ctx context.Context, id string) (remaining int, err error) {
+	err = s.database.QueryRowContext(ctx, consumeClickQuery, id).Scan(&remaining)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return remaining, err
+	}
+
+	// ничего не изменилось: ссылки нет, переходы не ограничены или закончились
+	var limit int
+	err = s.database.QueryRowContext(ctx, clickLimitQuery, id).Scan(&limit)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return 0, fmt.Errorf(storages.ErrLinkNotFound, handlers.ErrLinkIsNotFound, id)
+	case err != nil:
+		return 0, err
+	case limit > 0:
+		return 0, handlers.ErrLinkIsExhausted
+	}
+	return 0, nil
+}
+
+// nullString превращает неизменяемое поле изменения в NULL
+func nullString(v *string) sql.NullString {
+	if v == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *v, Valid: true}
+}
+
+// nullTime превращает неизменяемое время изменения в NULL
+func nullTime(v *time.Time) sql.NullTime {
+	if v == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *v, Valid: true}
+}
+
+// nullRules превращает неизменяемые правила в NULL
+func nullRules(v *[]storages.Rule) interface{} {
+	if v == nil {
+		return nil
+	}
+	return jsonField{v}
+}
+
+// GetUserLinks возвращает страницу ссылок пользователя, отобранных и упорядоченных согласно q.
+// Страницы выбираются по ключу (created_at, id) или (id) - без OFFSET, поэтому глубина страницы не влияет на скорость.
+func (s *Storage) GetUserLinks(ctx context.Context, user string, q storages.LinkQuery) (storages.LinkPage, error) {
+	query, args := buildUserLinksQuery(user, q)
+	rows, err := s.database.QueryContext(ctx, query, args...)
 	if err != nil {
-		log.Err(err).Send()
-		return map[string]string{}
+		return storages.LinkPage{}, err
 	}
 	defer func() {
 		err = rows.Close()
@@ -267,32 +536,67 @@ func (s *Storage) GetUserStorage(ctx context.Context, user string) map[string]st
 		}
 	}()
 
-	m := map[string]string{}
+	links := make([]storages.Link, 0)
 	for rows.Next() {
-		var (
-			id          string
-			originalURL string
-		)
-		err = rows.Scan(&id, &originalURL)
+		l := storages.Link{User: user}
+		err = rows.Scan(linkFields(&l)...)
 		if err != nil {
-			log.Err(err).Send()
-			return map[string]string{}
+			return storages.LinkPage{}, err
 		}
-		m[id] = originalURL
+		l.Created, l.Updated = l.Created.UTC(), l.Updated.UTC()
+		l.NotBefore, l.NotAfter = l.NotBefore.UTC(), l.NotAfter.UTC()
+		links = append(links, l)
+	}
+	if err = rows.Err(); err != nil {
+		return storages.LinkPage{}, err
 	}
 
-	err = rows.Err()
-	if err != nil {
-		log.Err(err).Send()
-		return map[string]string{}
+	// выбираем на одну запись больше, чтобы понять, есть ли следующая страница
+	if q.Limit <= 0 || len(links) <= q.Limit {
+		return storages.LinkPage{Links: links}, nil
 	}
+	links = links[:q.Limit]
+	return storages.LinkPage{Links: links, Next: storages.CursorOf(links[len(links)-1])}, nil
+}
 
-	return m
+// buildUserLinksQuery собирает запрос страницы ссылок пользователя и его параметры
+func buildUserLinksQuery(user string, q storages.LinkQuery) (string, []interface{}) {
+	cmp, dir := ">", "ASC"
+	if q.Desc {
+		cmp, dir = "<", "DESC"
+	}
+	limit := interface{}(nil)
+	if q.Limit > 0 {
+		limit = q.Limit + 1
+	}
+	status := q.Status
+	if status == "" {
+		status = storages.StatusAll
+	}
+	domain := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(q.Domain))
+	args := []interface{}{user, domain, string(status), limit, q.Tag}
+
+	var after, order string
+	switch {
+	case q.Sort == storages.SortByID:
+		order = fmt.Sprintf("id %s", dir)
+		if q.After != nil {
+			after = fmt.Sprintf("AND id %s $6", cmp)
+			args = append(args, q.After.ID)
+		}
+	default:
+		order = fmt.Sprintf("created_at %[1]s, id %[1]s", dir)
+		if q.After != nil {
+			after = fmt.Sprintf("AND (created_at, id) %s ($6, $7)", cmp)
+			args = append(args, q.After.Created, q.After.ID)
+		}
+	}
+	return fmt.Sprintf(userLinksQuery, storages.StatusAll, storages.StatusDeleted, after, order), args
 }
 
 // StoreBatch сохраняет пакет ссылок из map[correlation_id]original_link и возвращает map[correlation_id]short_link.
 // В случае конфликта c уже ранее сохраненным link возвращает ошибку handlers.ErrLinkIsAlreadyShortened и id с раннего сохранения.
-func (s *Storage) StoreBatch(ctx context.Context, user string, batchIn map[string]string) (map[string]string, error) {
+func (s *Storage) StoreBatch(ctx context.Context, user string, batchIn map[string]storages.Link) (map[string]string, error) {
 	// шаг 1 — объявляем транзакцию
 	tx, err := s.database.Begin()
 	if err != nil {
@@ -328,7 +632,7 @@ func (s *Storage) StoreBatch(ctx context.Context, user string, batchIn map[strin
 		// две попытки для генерации уникального id
 		for i := 0; i < 2; i++ {
 			id = utils.NewUniqueID()
-			err = query.QueryRowContext(ctx, id, user, link).Scan(&actualID)
+			err = query.QueryRowContext(ctx, storeArgs(id, user, link)...).Scan(&actualID)
 			if err == nil || errors.Is(err, sql.ErrNoRows) {
 				break
 			}
@@ -360,6 +664,48 @@ func (s *Storage) StoreBatch(ctx context.Context, user string, batchIn map[strin
 	return batchOut, err // err либо nil, либо ErrLinkIsAlreadyShortened
 }
 
+// FindShortened возвращает id ранее сохраненных ссылок с ключами уникальности keys
+func (s *Storage) FindShortened(ctx context.Context, keys []string) (map[string]string, error) {
+	rows, err := s.database.QueryContext(ctx, findKeysQuery, pq.Array(keys))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			log.Err(err).Send()
+		}
+	}()
+
+	found := make(map[string]string)
+	for rows.Next() {
+		var key, id string
+		if err = rows.Scan(&key, &id); err != nil {
+			return nil, err
+		}
+		found[key] = id
+	}
+	return found, rows.Err()
+}
+
+// CountLinks возвращает количество не удаленных ссылок пользователя
+func (s *Storage) CountLinks(ctx context.Context, user string) (count int, err error) {
+	err = s.database.QueryRowContext(ctx, countQuery, user).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Stats возвращает количество ссылок, пользователей и удаленных ссылок
+func (s *Storage) Stats(ctx context.Context) (stats storages.Stats, err error) {
+	err = s.database.QueryRowContext(ctx, statsQuery).Scan(&stats.URLs, &stats.Users, &stats.Deleted)
+	if err != nil {
+		return storages.Stats{}, err
+	}
+	return stats, nil
+}
+
 // Ping проверяет доступность БД
 func (s *Storage) Ping(ctx context.Context) error {
 	return s.database.PingContext(ctx)
@@ -369,12 +715,14 @@ func (s *Storage) Ping(ctx context.Context) error {
 func (s *Storage) Close() error {
 	s.done <- true
 	// важен порядок закрытия!
-	close(s.delBatch)
+	close(s.marks)
 	close(s.done)
 	return s.database.Close()
 }
 
+// userID - ссылка пользователя и признак удаления, который требуется ей установить
 type userID struct {
-	User string
-	ID   string
+	User    string
+	ID      string
+	Deleted bool
 }
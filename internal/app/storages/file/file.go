@@ -1,32 +1,45 @@
 package file
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"strings"
 	"sync"
+	"time"
 
 	"github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers"
 	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
 	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+	"github.com/rs/zerolog/log"
 )
 
 // Storage реализует хранение ссылок в файле.
-// Выполнена простейшая реализация для сдачи работы.
+// Файл - журнал записей в формате JSON, новые записи только дописываются в конец.
+// При открытии журнал целиком читается в индекс в памяти, все чтения идут из индекса.
+// Если в журнале несколько записей с одним ID, то актуальной считается последняя,
+// а смены оригинальной ссылки собираются в историю.
 type Storage struct {
-	// Ридер один, но в теории правильней было бы сделать пул ридеров,
-	// так как в таком сервисе кол-во чтений в разы (десятки/сотни раз) больше,
-	// чем записей
-	storageReader *Reader
+	// links - map[id]link
+	links map[string]*storages.Link
+	// users - map[user]map[id]link, ссылаются на те же записи, что и links
+	users map[string]map[string]*storages.Link
+	// pages - map[user]позиции ссылок пользователя для постраничной выборки
+	pages map[string]*storages.LinkIndex
+	// history - map[id][]Destination, все оригинальные ссылки от первой до текущей
+	history       map[string][]Destination
 	storageWriter *Writer
 	mx            sync.Mutex
 }
 
+// Destination - оригинальная ссылка, на которую вела короткая ссылка начиная с момента Since.
+type Destination struct {
+	URL   string
+	Since time.Time
+}
+
 var _ handlers.Repository = (*Storage)(nil)
 
 // NewStorage cоздаёт и возвращает экземпляр Storage
@@ -34,9 +47,13 @@ func NewStorage(filename string) (fs *Storage, err error) {
 	if err = utils.CheckFilename(filename); err != nil {
 		return nil, err
 	}
-	fs = &Storage{}
-	fs.storageReader, err = NewReader(filename)
-	if err != nil {
+	fs = &Storage{
+		links:   make(map[string]*storages.Link),
+		users:   make(map[string]map[string]*storages.Link),
+		pages:   make(map[string]*storages.LinkIndex),
+		history: make(map[string][]Destination),
+	}
+	if err = fs.load(filename); err != nil {
 		return nil, err
 	}
 	fs.storageWriter, err = NewWriter(filename)
@@ -46,40 +63,75 @@ func NewStorage(filename string) (fs *Storage, err error) {
 	return fs, nil
 }
 
-// isExist проверяет наличие в файле указанного ID
-// Если такой ID входит как подстрока в ссылку, то результат будет такой же, как если бы был найден ID
-func (s *Storage) isExist(_ context.Context, id string) bool {
-	_, err := s.storageReader.file.Seek(0, io.SeekStart)
+// load читает журнал в индекс
+func (s *Storage) load(filename string) error {
+	reader, err := NewReader(filename)
 	if err != nil {
-		return false
+		return err
 	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Err(err).Send()
+		}
+	}()
 
-	scanner := bufio.NewScanner(s.storageReader.file)
-	for scanner.Scan() {
-		if strings.Contains(scanner.Text(), id) {
-			// Не обрабатывается ситуация, когда в одной из ссылок может быть подстрока равная ID
-			// Для этого можно сделать decoding JSON или захардкодить `"Key:"id"`
-			return true
+	for {
+		alias, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
 		}
+		s.index(alias.toLink())
 	}
+}
 
-	if err := scanner.Err(); err != nil {
-		return false
+// index добавляет или заменяет запись в индексе
+func (s *Storage) index(l storages.Link) {
+	prev, ok := s.links[l.ID]
+	switch {
+	case !ok:
+		s.history[l.ID] = []Destination{{URL: l.URL, Since: l.Created}}
+	case prev.URL != l.URL:
+		s.history[l.ID] = append(s.history[l.ID], Destination{URL: l.URL, Since: l.Updated})
+	}
+	if ok && prev.User != l.User {
+		delete(s.users[prev.User], l.ID)
+	}
+	if ok && (prev.User != l.User || !prev.Created.Equal(l.Created)) {
+		s.pages[prev.User].Remove(*storages.CursorOf(*prev))
+	}
+	s.links[l.ID] = &l
+	if _, ok := s.users[l.User]; !ok {
+		s.users[l.User] = make(map[string]*storages.Link)
+		s.pages[l.User] = &storages.LinkIndex{}
 	}
-	return false
+	s.users[l.User][l.ID] = &l
+	s.pages[l.User].Add(*storages.CursorOf(l))
 }
 
-// Store - сохраняет ID и ссылку в формате JSON во внешнем файле
-func (s *Storage) Store(ctx context.Context, user string, link string) (id string, err error) {
+// isExist проверяет наличие указанного ID
+func (s *Storage) isExist(_ context.Context, id string) bool {
+	_, ok := s.links[id]
+	return ok
+}
+
+// Store - сохраняет ID и ссылку в формате JSON во внешнем файле. Если ссылка с тем же ключом уникальности уже есть,
+// возвращает ее id и ошибку handlers.ErrLinkIsAlreadyShortened.
+func (s *Storage) Store(ctx context.Context, user string, link storages.Link) (id string, err error) {
 	s.mx.Lock()
 	defer s.mx.Unlock()
 
+	if other, ok := s.findKey(link.DedupKey()); ok {
+		return other.ID, handlers.ErrLinkIsAlreadyShortened
+	}
 	id, err = utils.CreateShortID(ctx, s.isExist)
 	if err != nil {
 		return "", err
 	}
 
-	err = s.store(user, id, link)
+	err = s.store(newLink(id, user, link))
 	if err != nil {
 		return "", err
 	}
@@ -87,120 +139,297 @@ func (s *Storage) Store(ctx context.Context, user string, link string) (id strin
 	return id, err
 }
 
-func (s *Storage) store(user string, id string, link string) error {
-	a := Alias{User: user, Key: id, URL: link}
-	err := s.storageWriter.Write(&a)
+// newLink возвращает новую ссылку пользователя с оригинальной ссылкой и метаданными из link
+func newLink(id, user string, link storages.Link) storages.Link {
+	now := time.Now().UTC()
+	return storages.Link{
+		ID:              id,
+		User:            user,
+		URL:             link.URL,
+		Canonical:       link.Canonical,
+		Created:         now,
+		Updated:         now,
+		Title:           link.Title,
+		Tags:            link.Tags,
+		Notes:           link.Notes,
+		Redirect:        link.Redirect,
+		ForwardQuery:    link.ForwardQuery,
+		ForwardPath:     link.ForwardPath,
+		PasswordHash:    link.PasswordHash,
+		MaxClicks:       link.MaxClicks,
+		RemainingClicks: link.MaxClicks,
+		NotBefore:       link.NotBefore,
+		NotAfter:        link.NotAfter,
+		Variants:        link.Variants,
+		Rules:           link.Rules,
+	}
+}
+
+// findKey ищет ссылку по ключу уникальности среди всех пользователей
+func (s *Storage) findKey(key string) (*storages.Link, bool) {
+	for _, l := range s.links {
+		if l.DedupKey() == key {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// store дописывает запись в журнал и обновляет индекс
+func (s *Storage) store(l storages.Link) error {
+	err := s.storageWriter.Write(newAlias(l))
 	if err != nil {
 		return err
 	}
+	s.index(l)
 	return nil
 }
 
-// Restore - находит по ID ссылку во внешнем файле, где данные хранятся в формате JSON
-func (s *Storage) Restore(_ context.Context, id string) (link string, err error) {
+// Restore - находит по ID ссылку
+func (s *Storage) Restore(_ context.Context, id string) (link storages.Link, err error) {
 	s.mx.Lock()
 	defer s.mx.Unlock()
 
-	_, err = s.storageReader.file.Seek(0, io.SeekStart)
-	if err != nil {
-		return "", err
+	l, ok := s.links[id]
+	if !ok {
+		return storages.Link{}, fmt.Errorf(storages.ErrLinkNotFound, handlers.ErrLinkIsNotFound, id)
+	}
+	if l.Deleted {
+		return *l, handlers.ErrLinkIsDeleted
 	}
+	return *l, nil
+}
 
-	for {
-		alias, err := s.storageReader.Read()
-		if err != nil {
-			return "", fmt.Errorf(storages.ErrLinkNotFound, id)
-		}
+// Unstore - помечает список ранее сохраненных ссылок удаленными
+// только тех ссылок, которые принадлежат пользователю.
+// В журнал дописываются новые версии записей с признаком удаления.
+func (s *Storage) Unstore(_ context.Context, user string, ids []string) {
+	s.mark(user, ids, true)
+}
+
+// Undelete - снимает пометку удаления со списка ранее удаленных ссылок
+// только тех ссылок, которые принадлежат пользователю.
+// В журнал дописываются новые версии записей без признака удаления.
+func (s *Storage) Undelete(_ context.Context, user string, ids []string) {
+	s.mark(user, ids, false)
+}
+
+// mark устанавливает признак удаления deleted ссылкам пользователя
+func (s *Storage) mark(user string, ids []string, deleted bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
 
-		if alias.Key == id {
-			return alias.URL, nil
+	now := time.Now().UTC()
+	for _, id := range ids {
+		l, ok := s.users[user][id]
+		if !ok || l.Deleted == deleted {
+			continue
+		}
+		marked := *l
+		marked.Deleted = deleted
+		marked.Updated = now
+		if err := s.store(marked); err != nil {
+			log.Err(err).Msgf("can't mark link %s as deleted=%v", id, deleted)
+			return
 		}
 	}
 }
 
-// Unstore - помечает список ранее сохраненных ссылок удаленными
-// только тех ссылок, которые принадлежат пользователю
-// Только для совместимости контракта
-func (s *Storage) Unstore(_ context.Context, _ string, _ []string) {
-	panic("not implemented for file storage")
+// Update изменяет ссылку id, принадлежащую пользователю, дописывая в журнал ее новую версию.
+// Оригинальная ссылка должна оставаться уникальной среди всех ссылок хранилища.
+func (s *Storage) Update(_ context.Context, user string, id string, patch storages.LinkPatch) (storages.Link, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	l, ok := s.users[user][id]
+	switch {
+	case !ok:
+		return storages.Link{}, handlers.ErrLinkIsNotFound
+	case l.Deleted:
+		return storages.Link{}, handlers.ErrLinkIsDeleted
+	case patch.EmptiesWindow(*l):
+		return storages.Link{}, handlers.ErrInvalidWindow
+	}
+
+	updated := *l
+	if patch.URL != nil && *patch.URL != l.URL {
+		if other, ok := s.findKey(patch.DedupKey()); ok && other.ID != id {
+			return *other, handlers.ErrLinkIsAlreadyShortened
+		}
+		patch.ApplyURL(&updated)
+	}
+	patch.ApplyMeta(&updated)
+	updated.Updated = time.Now().UTC()
+	if err := s.store(updated); err != nil {
+		return storages.Link{}, err
+	}
+	return updated, nil
 }
 
-// GetUserStorage возвращает map[id]link ранее сокращенных ссылок указанным пользователем
-func (s *Storage) GetUserStorage(_ context.Context, user string) map[string]string {
+// SetBlocked устанавливает или снимает блокировку ссылки id, кому бы она ни принадлежала,
+// дописывая в журнал ее новую версию.
+func (s *Storage) SetBlocked(_ context.Context, id string, blocked bool) error {
 	s.mx.Lock()
 	defer s.mx.Unlock()
 
-	m := map[string]string{}
-	_, err := s.storageReader.file.Seek(0, io.SeekStart)
-	if err != nil {
-		return map[string]string{}
+	l, ok := s.links[id]
+	if !ok {
+		return fmt.Errorf(storages.ErrLinkNotFound, handlers.ErrLinkIsNotFound, id)
+	}
+	if l.Blocked == blocked {
+		return nil
 	}
+	marked := *l
+	marked.Blocked = blocked
+	return s.store(marked)
+}
 
-	scanner := bufio.NewScanner(s.storageReader.file)
-	for scanner.Scan() {
-		txt := scanner.Text()
-		if strings.Contains(txt, user) {
-			alias := &Alias{}
-			dec := json.NewDecoder(bytes.NewBufferString(txt))
-			if err := dec.Decode(&alias); err != nil {
-				return map[string]string{}
-			}
-			m[alias.Key] = alias.URL
-		}
+// ConsumeClick уменьшает остаток переходов по ссылке id с ограничением переходов,
+// дописывая в журнал ее новую версию.
+func (s *Storage) ConsumeClick(_ context.Context, id string) (int, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	l, ok := s.links[id]
+	switch {
+	case !ok:
+		return 0, fmt.Errorf(storages.ErrLinkNotFound, handlers.ErrLinkIsNotFound, id)
+	case l.IsExhausted():
+		return 0, handlers.ErrLinkIsExhausted
+	case l.MaxClicks == 0:
+		return 0, nil
+	}
+	consumed := *l
+	consumed.RemainingClicks--
+	if err := s.store(consumed); err != nil {
+		return 0, err
 	}
+	return consumed.RemainingClicks, nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return map[string]string{}
+// History возвращает все оригинальные ссылки, на которые вела короткая ссылка id, от первой до текущей.
+// У ссылок, сохраненных до появления времени создания, Since первой ссылки нулевое.
+func (s *Storage) History(_ context.Context, id string) ([]Destination, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	h, ok := s.history[id]
+	if !ok {
+		return nil, fmt.Errorf(storages.ErrLinkNotFound, handlers.ErrLinkIsNotFound, id)
 	}
+	out := make([]Destination, len(h))
+	copy(out, h)
+	return out, nil
+}
 
-	return m
+// GetUserLinks возвращает страницу ссылок пользователя, отобранных и упорядоченных согласно q
+func (s *Storage) GetUserLinks(_ context.Context, user string, q storages.LinkQuery) (storages.LinkPage, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	x, ok := s.pages[user]
+	if !ok {
+		return storages.LinkPage{Links: []storages.Link{}}, nil
+	}
+	return x.Page(q, func(id string) (storages.Link, bool) {
+		l, ok := s.users[user][id]
+		if !ok {
+			return storages.Link{}, false
+		}
+		return *l, true
+	}), nil
 }
 
-// StoreBatch сохраняет пакет ссылок из map[correlation_id]original_link и возвращает map[correlation_id]short_link
-func (s *Storage) StoreBatch(ctx context.Context, user string, batchIn map[string]string) (batchOut map[string]string, err error) {
+// StoreBatch сохраняет пакет ссылок из map[correlation_id]original_link и возвращает map[correlation_id]short_link.
+// Для уже сохраненных ссылок возвращается их id и ошибка handlers.ErrLinkIsAlreadyShortened.
+func (s *Storage) StoreBatch(ctx context.Context, user string, batchIn map[string]storages.Link) (batchOut map[string]string, err error) {
 	s.mx.Lock()
 	defer s.mx.Unlock()
 
 	batchOut = make(map[string]string)
 	var id string
+	conflict := false
 	for corrID, link := range batchIn {
+		if other, ok := s.findKey(link.DedupKey()); ok {
+			batchOut[corrID] = other.ID
+			conflict = true
+			continue
+		}
 		id, err = utils.CreateShortID(ctx, s.isExist)
 		if err != nil {
 			return nil, err
 		}
-		err = s.store(user, id, link)
+		err = s.store(newLink(id, user, link))
 		if err != nil {
 			return nil, err
 		}
 		batchOut[corrID] = id
 	}
+	if conflict {
+		return batchOut, handlers.ErrLinkIsAlreadyShortened
+	}
 	return batchOut, nil
 }
 
-// Ping проверяет, что файл хранения доступен и экземпляры инициализированы
+// FindShortened возвращает id ранее сохраненных ссылок с ключами уникальности keys
+func (s *Storage) FindShortened(_ context.Context, keys []string) (map[string]string, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	found := make(map[string]string)
+	for _, key := range keys {
+		if l, ok := s.findKey(key); ok {
+			found[key] = l.ID
+		}
+	}
+	return found, nil
+}
+
+// CountLinks возвращает количество не удаленных ссылок пользователя
+func (s *Storage) CountLinks(_ context.Context, user string) (count int, err error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for _, l := range s.users[user] {
+		if !l.Deleted {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Stats возвращает количество ссылок, пользователей и удаленных ссылок
+func (s *Storage) Stats(_ context.Context) (stats storages.Stats, err error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for _, links := range s.users {
+		if len(links) == 0 {
+			continue
+		}
+		stats.Users++
+		stats.URLs += len(links)
+		for _, l := range links {
+			if l.Deleted {
+				stats.Deleted++
+			}
+		}
+	}
+	return stats, nil
+}
+
+// Ping проверяет, что файл хранения доступен
 func (s *Storage) Ping(_ context.Context) error {
 	_, err := s.storageWriter.file.Stat()
 	if err != nil {
 		return storages.ErrStorageIsUnavailable
 	}
-	_, err = s.storageReader.file.Stat()
-	if err != nil {
-		return storages.ErrStorageIsUnavailable
-	}
 	return nil
 }
 
-// Close закрывает все файлы, открытых для записи и чтения
+// Close закрывает файл журнала
 func (s *Storage) Close() error {
-	err1 := s.storageReader.Close()
-	err2 := s.storageWriter.Close()
-	if err1 != nil {
-		return err1
-	}
-	if err2 != nil {
-		return err2
-	}
-	return nil
+	return s.storageWriter.Close()
 }
 
 type Writer struct {
@@ -255,9 +484,100 @@ func (c *Reader) Close() error {
 	return c.file.Close()
 }
 
-// Alias - структура хранения ID и URL во внешнем файле
+// Alias - структура хранения ID и URL во внешнем файле.
+// У записей, сохраненных до появления полей Created, Updated, Deleted и более поздних, они нулевые,
+// поэтому старые файлы читаются без преобразования.
 type Alias struct {
-	User string
-	Key  string
-	URL  string
+	User      string
+	Key       string
+	URL       string
+	Canonical string `json:",omitempty"`
+	Created   time.Time
+	Updated   time.Time
+	Deleted   bool
+	Title     string   `json:",omitempty"`
+	Tags      []string `json:",omitempty"`
+	Notes     string   `json:",omitempty"`
+	// Redirect - HTTP код перенаправления, 0 - код по умолчанию сервиса
+	Redirect     int    `json:",omitempty"`
+	ForwardQuery bool   `json:",omitempty"`
+	ForwardPath  bool   `json:",omitempty"`
+	Blocked      bool   `json:",omitempty"`
+	PasswordHash string `json:",omitempty"`
+	// MaxClicks и RemainingClicks - ограничение переходов и их остаток, каждый переход дописывает новую версию записи
+	MaxClicks       int `json:",omitempty"`
+	RemainingClicks int `json:",omitempty"`
+	// NotBefore и NotAfter - границы окна активности, отсутствующая граница не пишется
+	NotBefore *time.Time         `json:",omitempty"`
+	NotAfter  *time.Time         `json:",omitempty"`
+	Variants  []storages.Variant `json:",omitempty"`
+	Rules     []storages.Rule    `json:",omitempty"`
+}
+
+func newAlias(l storages.Link) *Alias {
+	return &Alias{
+		User:            l.User,
+		Key:             l.ID,
+		URL:             l.URL,
+		Canonical:       l.Canonical,
+		Created:         l.Created,
+		Updated:         l.Updated,
+		Deleted:         l.Deleted,
+		Title:           l.Title,
+		Tags:            l.Tags,
+		Notes:           l.Notes,
+		Redirect:        l.Redirect,
+		ForwardQuery:    l.ForwardQuery,
+		ForwardPath:     l.ForwardPath,
+		Blocked:         l.Blocked,
+		PasswordHash:    l.PasswordHash,
+		MaxClicks:       l.MaxClicks,
+		RemainingClicks: l.RemainingClicks,
+		NotBefore:       optionalTime(l.NotBefore),
+		NotAfter:        optionalTime(l.NotAfter),
+		Variants:        l.Variants,
+		Rules:           l.Rules,
+	}
+}
+
+func (a *Alias) toLink() storages.Link {
+	return storages.Link{
+		ID:              a.Key,
+		User:            a.User,
+		URL:             a.URL,
+		Canonical:       a.Canonical,
+		Created:         a.Created,
+		Updated:         a.Updated,
+		Deleted:         a.Deleted,
+		Title:           a.Title,
+		Tags:            a.Tags,
+		Notes:           a.Notes,
+		Redirect:        a.Redirect,
+		ForwardQuery:    a.ForwardQuery,
+		ForwardPath:     a.ForwardPath,
+		Blocked:         a.Blocked,
+		PasswordHash:    a.PasswordHash,
+		MaxClicks:       a.MaxClicks,
+		RemainingClicks: a.RemainingClicks,
+		NotBefore:       timeOf(a.NotBefore),
+		NotAfter:        timeOf(a.NotAfter),
+		Variants:        a.Variants,
+		Rules:           a.Rules,
+	}
+}
+
+// optionalTime возвращает nil для нулевого времени, чтобы оно не попадало в журнал
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// timeOf возвращает время или нулевое время для nil
+func timeOf(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
 }
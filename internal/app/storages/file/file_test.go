@@ -5,8 +5,14 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -100,7 +106,7 @@ func TestFileStorage_Restore(t *testing.T) {
 			if !tt.wantErr(t, err, fmt.Sprintf("Restore(%v)", tt.args.id)) {
 				return
 			}
-			assert.Equalf(t, tt.wantLink, gotLink, "Restore(%v)", tt.args.id)
+			assert.Equalf(t, tt.wantLink, gotLink.URL, "Restore(%v)", tt.args.id)
 		})
 	}
 }
@@ -152,7 +158,7 @@ func TestFileStorage_Store(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := context.Background()
-			_, err := fs.Store(ctx, tt.args.user, tt.args.link)
+			_, err := fs.Store(ctx, tt.args.user, storages.Link{URL: tt.args.link})
 			if !tt.wantErr(t, err, fmt.Sprintf("Store(%v, %v, %v)", ctx, tt.args.user, tt.args.link)) {
 				return
 			}
@@ -161,40 +167,296 @@ func TestFileStorage_Store(t *testing.T) {
 	}
 }
 
-func TestStorage_GetUserStorage(t *testing.T) {
-	type args struct {
-		user string
-	}
-	tests := []struct {
-		want map[string]string
-		name string
-		args args
-	}{
-		{
-			name: "empty bucket",
-			args: args{user: "yyyy"},
-			want: make(map[string]string),
-		},
-		{
-			name: "full bucket",
-			args: args{user: "xxxx"},
-			want: map[string]string{"1111": "https://ya.ru", "2222": "https://yandex.ru", "3333": "https://go.dev", "4444": "https://github.com/spf13/afero"},
-		},
-	}
+func TestFileStorage_Stats(t *testing.T) {
+	fs, err := NewStorage("file_storage.json")
+	require.NoError(t, err)
+	defer func(fs *Storage) {
+		err := fs.Close()
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}(fs)
 
-	filename := "file_storage.json"
+	stats, err := fs.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, storages.Stats{URLs: 4, Users: 1}, stats)
+}
+
+func TestStorage_GetUserLinks(t *testing.T) {
+	fs, err := NewStorage("file_storage.json")
+	require.NoError(t, err)
+	defer func(fs *Storage) {
+		err := fs.Close()
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}(fs)
+
+	// у записей без времени создания порядок определяется id
+	ctx := context.Background()
+	page, err := fs.GetUserLinks(ctx, "xxxx", storages.LinkQuery{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Links, 2)
+	assert.Equal(t, "1111", page.Links[0].ID)
+	assert.Equal(t, "2222", page.Links[1].ID)
+	require.NotNil(t, page.Next)
+
+	page, err = fs.GetUserLinks(ctx, "xxxx", storages.LinkQuery{Limit: 2, After: page.Next, Domain: "github"})
+	require.NoError(t, err)
+	require.Len(t, page.Links, 1)
+	assert.Equal(t, "https://github.com/spf13/afero", page.Links[0].URL)
+	assert.Nil(t, page.Next)
+
+	page, err = fs.GetUserLinks(ctx, "yyyy", storages.LinkQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Links)
+}
+
+func TestFileStorage_Unstore(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "storage.json")
 	fs, err := NewStorage(filename)
 	require.NoError(t, err)
+
+	ctx := context.Background()
+	id, err := fs.Store(ctx, "xxxx", storages.Link{URL: "https://ya.ru"})
+	require.NoError(t, err)
+	fs.Unstore(ctx, "xxxx", []string{id})
+	require.NoError(t, fs.Close())
+
+	// признак удаления переживает перезапуск
+	fs, err = NewStorage(filename)
+	require.NoError(t, err)
 	defer func(fs *Storage) {
 		err := fs.Close()
 		if err != nil {
 			log.Fatalln(err)
 		}
 	}(fs)
+	deleted, err := fs.Restore(ctx, id)
+	assert.ErrorIs(t, err, handlers.ErrLinkIsDeleted)
+	assert.Equal(t, "xxxx", deleted.User)
+	count, err := fs.CountLinks(ctx, "xxxx")
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			assert.Equalf(t, tt.want, fs.GetUserStorage(context.Background(), tt.args.user), "GetUserStorage(context.Background(), %v)", tt.args.user)
-		})
+	page, err := fs.GetUserLinks(ctx, "xxxx", storages.LinkQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Links, 1)
+	assert.True(t, page.Links[0].Deleted)
+	assert.False(t, page.Links[0].Created.IsZero())
+	assert.False(t, page.Links[0].Updated.Before(page.Links[0].Created))
+
+	// восстановление тоже пишется в журнал
+	fs.Undelete(ctx, "yyyy", []string{id})
+	_, err = fs.Restore(ctx, id)
+	assert.ErrorIs(t, err, handlers.ErrLinkIsDeleted)
+	fs.Undelete(ctx, "xxxx", []string{id})
+	link, err := fs.Restore(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", link.URL)
+	count, err = fs.CountLinks(ctx, "xxxx")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestFileStorage_Update(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "storage.json")
+	fs, err := NewStorage(filename)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	id, err := fs.Store(ctx, "xxxx", storages.Link{URL: "https://ya.ru"})
+	require.NoError(t, err)
+	_, err = fs.Store(ctx, "yyyy", storages.Link{URL: "https://go.dev"})
+	require.NoError(t, err)
+
+	newURL := "https://yandex.ru"
+	_, err = fs.Update(ctx, "xxxx", id, storages.LinkPatch{URL: &newURL})
+	require.NoError(t, err)
+	taken := "https://go.dev"
+	_, err = fs.Update(ctx, "xxxx", id, storages.LinkPatch{URL: &taken})
+	assert.ErrorIs(t, err, handlers.ErrLinkIsAlreadyShortened)
+	_, err = fs.Update(ctx, "yyyy", id, storages.LinkPatch{URL: &newURL})
+	assert.ErrorIs(t, err, handlers.ErrLinkIsNotFound)
+	require.NoError(t, fs.Close())
+
+	// новая ссылка и история переживают перезапуск
+	fs, err = NewStorage(filename)
+	require.NoError(t, err)
+	defer func(fs *Storage) {
+		err := fs.Close()
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}(fs)
+	link, err := fs.Restore(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, newURL, link.URL)
+
+	history, err := fs.History(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "https://ya.ru", history[0].URL)
+	assert.Equal(t, newURL, history[1].URL)
+	assert.False(t, history[1].Since.Before(history[0].Since))
+}
+
+func TestFileStorage_Metadata(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "storage.json")
+	fs, err := NewStorage(filename)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	launch := time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC)
+	id, err := fs.Store(ctx, "xxxx", storages.Link{URL: "https://ya.ru", Title: "Yandex", Tags: []string{"search"}, Redirect: 301, ForwardPath: true,
+		PasswordHash: "pbkdf2-sha256$1$c2FsdA$a2V5", NotBefore: launch})
+	require.NoError(t, err)
+	variants := []storages.Variant{{URL: "https://go.dev", Weight: 3}, {URL: "https://go.dev/doc", Weight: 1}}
+	abID, err := fs.Store(ctx, "xxxx", storages.Link{URL: "https://go.dev", Canonical: "variants", Variants: variants})
+	require.NoError(t, err)
+
+	notes := "main page"
+	tags := []string{"search", "ru"}
+	finish := launch.AddDate(0, 1, 0)
+	rules := []storages.Rule{{Platform: "ios", Language: "ru", URL: "https://apps.apple.com/ru/app"}}
+	_, err = fs.Update(ctx, "xxxx", id, storages.LinkPatch{Tags: &tags, Notes: &notes, NotAfter: &finish, Rules: &rules})
+	require.NoError(t, err)
+	require.NoError(t, fs.Close())
+
+	// метаданные переживают перезапуск
+	fs, err = NewStorage(filename)
+	require.NoError(t, err)
+	defer func(fs *Storage) {
+		err := fs.Close()
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}(fs)
+	page, err := fs.GetUserLinks(ctx, "xxxx", storages.LinkQuery{Tag: "ru"})
+	require.NoError(t, err)
+	require.Len(t, page.Links, 1)
+	assert.Equal(t, "Yandex", page.Links[0].Title)
+	assert.Equal(t, tags, page.Links[0].Tags)
+	assert.Equal(t, notes, page.Links[0].Notes)
+	assert.Equal(t, 301, page.Links[0].Redirect)
+	assert.True(t, page.Links[0].ForwardPath)
+	assert.Equal(t, "pbkdf2-sha256$1$c2FsdA$a2V5", page.Links[0].PasswordHash)
+	assert.True(t, launch.Equal(page.Links[0].NotBefore))
+	assert.True(t, finish.Equal(page.Links[0].NotAfter))
+	assert.Equal(t, rules, page.Links[0].Rules)
+	ab, err := fs.Restore(ctx, abID)
+	require.NoError(t, err)
+	assert.Equal(t, variants, ab.Variants)
+}
+
+func TestFileStorage_Dedup(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "storage.json")
+	fs, err := NewStorage(filename)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	id, err := fs.Store(ctx, "xxxx", storages.Link{URL: "HTTP://Example.com:80/a", Canonical: "http://example.com/a"})
+	require.NoError(t, err)
+	require.NoError(t, fs.Close())
+
+	// каноническая форма переживает перезапуск
+	fs, err = NewStorage(filename)
+	require.NoError(t, err)
+	defer func(fs *Storage) {
+		err := fs.Close()
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}(fs)
+	link, err := fs.Restore(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP://Example.com:80/a", link.URL)
+	assert.Equal(t, "http://example.com/a", link.Canonical)
+
+	otherID, err := fs.Store(ctx, "yyyy", storages.Link{URL: "http://example.com/./a", Canonical: "http://example.com/a"})
+	assert.ErrorIs(t, err, handlers.ErrLinkIsAlreadyShortened)
+	assert.Equal(t, id, otherID)
+
+	batch, err := fs.StoreBatch(ctx, "yyyy", map[string]storages.Link{
+		"1": {URL: "http://example.com:80/a", Canonical: "http://example.com/a"},
+		"2": {URL: "https://go.dev", Canonical: "https://go.dev/"},
+	})
+	assert.ErrorIs(t, err, handlers.ErrLinkIsAlreadyShortened)
+	assert.Equal(t, id, batch["1"])
+	assert.NotEqual(t, id, batch["2"])
+}
+
+func TestFileStorage_SetBlocked(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "storage.json")
+	fs, err := NewStorage(filename)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	id, err := fs.Store(ctx, "xxxx", storages.Link{URL: "https://ya.ru"})
+	require.NoError(t, err)
+	require.NoError(t, fs.SetBlocked(ctx, id, true))
+	assert.ErrorIs(t, fs.SetBlocked(ctx, "5555", true), handlers.ErrLinkIsNotFound)
+	require.NoError(t, fs.Close())
+
+	// блокировка переживает перезапуск
+	fs, err = NewStorage(filename)
+	require.NoError(t, err)
+	defer func(fs *Storage) {
+		err := fs.Close()
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}(fs)
+	link, err := fs.Restore(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, link.Blocked)
+
+	require.NoError(t, fs.SetBlocked(ctx, id, false))
+	link, err = fs.Restore(ctx, id)
+	require.NoError(t, err)
+	assert.False(t, link.Blocked)
+}
+
+func TestFileStorage_ConsumeClick(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "storage.json")
+	fs, err := NewStorage(filename)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	id, err := fs.Store(ctx, "xxxx", storages.Link{URL: "https://ya.ru", MaxClicks: 5})
+	require.NoError(t, err)
+
+	// параллельные переходы не расходуют больше ограничения
+	var (
+		wg       sync.WaitGroup
+		consumed int32
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := fs.ConsumeClick(ctx, id); err == nil {
+				atomic.AddInt32(&consumed, 1)
+			}
+		}()
 	}
+	wg.Wait()
+	assert.Equal(t, int32(5), consumed)
+	require.NoError(t, fs.Close())
+
+	// остаток переживает перезапуск
+	fs, err = NewStorage(filename)
+	require.NoError(t, err)
+	defer func(fs *Storage) {
+		err := fs.Close()
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}(fs)
+	_, err = fs.ConsumeClick(ctx, id)
+	assert.ErrorIs(t, err, handlers.ErrLinkIsExhausted)
+	link, err := fs.Restore(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, 5, link.MaxClicks)
+	assert.Equal(t, 0, link.RemainingClicks)
 }
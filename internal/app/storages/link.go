@@ -0,0 +1,427 @@
+package storages
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Link - запись о сокращенной ссылке.
+// Created и Updated нулевые у ссылок, сохраненных до появления этих полей.
+type Link struct {
+	ID   string
+	User string
+	URL  string
+	// Canonical - каноническая форма URL, по которой ссылки проверяются на уникальность, см. DedupKey.
+	Canonical string
+	Created   time.Time
+	Updated   time.Time
+	Deleted   bool
+	// Title, Tags и Notes - необязательные описание ссылки, метки и заметки пользователя.
+	Title string
+	Tags  []string
+	Notes string
+	// Redirect - HTTP код перенаправления, 0 - код по умолчанию сервиса.
+	Redirect int
+	// ForwardQuery и ForwardPath - проброс параметров запроса и хвоста пути короткой ссылки в оригинальную.
+	// Хвосты, состоящие ровно из qr (GET) и report (POST), зарезервированы за служебными методами и не пробрасываются.
+	ForwardQuery bool
+	ForwardPath  bool
+	// Blocked - ссылка заблокирована администратором по жалобам, переход по ней запрещен.
+	Blocked bool
+	// PasswordHash - соленый хэш пароля, без которого переход по ссылке запрещен, пустой - пароля нет.
+	PasswordHash string
+	// MaxClicks - сколько раз можно перейти по ссылке, 0 - без ограничения.
+	// RemainingClicks - сколько переходов осталось, имеет смысл только при MaxClicks > 0.
+	MaxClicks       int
+	RemainingClicks int
+	// NotBefore и NotAfter - окно активности ссылки: до NotBefore переход по ссылке еще не работает,
+	// а начиная с NotAfter - уже не работает. Нулевое время означает отсутствие границы.
+	NotBefore time.Time
+	NotAfter  time.Time
+	// Variants - варианты оригинальной ссылки, между которыми переходы распределяются по весам.
+	// У ссылки с вариантами URL совпадает с адресом первого варианта, у обычной ссылки вариантов нет.
+	Variants []Variant
+	// Rules - правила выбора оригинальной ссылки по устройству и языку посетителя.
+	// Применяется первое подходящее правило, если подходящих нет - переход выполняется как обычно.
+	Rules []Rule
+}
+
+// Rule - правило выбора оригинальной ссылки URL для посетителей с платформой Platform и языком Language.
+// Пустое условие подходит любому посетителю.
+type Rule struct {
+	Platform string
+	Language string
+	URL      string
+}
+
+// Variant - один из адресов ссылки с несколькими оригинальными ссылками и его вес.
+type Variant struct {
+	URL    string
+	Weight int
+}
+
+// TotalWeight возвращает сумму весов вариантов ссылки.
+func (l Link) TotalWeight() (total int) {
+	for _, v := range l.Variants {
+		total += v.Weight
+	}
+	return total
+}
+
+// VariantAt возвращает индекс варианта, на долю веса которого приходится point из [0, TotalWeight).
+func (l Link) VariantAt(point int) int {
+	for i, v := range l.Variants {
+		if point < v.Weight {
+			return i
+		}
+		point -= v.Weight
+	}
+	return len(l.Variants) - 1
+}
+
+// IsExhausted проверяет, что переходы по ссылке с ограничением закончились.
+func (l Link) IsExhausted() bool {
+	return l.MaxClicks > 0 && l.RemainingClicks <= 0
+}
+
+// DedupKey возвращает ключ уникальности ссылки: каноническую форму URL,
+// а у ссылок, сохраненных без нее, сам URL.
+func (l Link) DedupKey() string {
+	if l.Canonical != "" {
+		return l.Canonical
+	}
+	return l.URL
+}
+
+// HasTag проверяет наличие у ссылки метки tag.
+func (l Link) HasTag(tag string) bool {
+	for _, t := range l.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPending проверяет, что окно активности ссылки в момент now еще не началось.
+func (l Link) IsPending(now time.Time) bool {
+	return !l.NotBefore.IsZero() && now.Before(l.NotBefore)
+}
+
+// IsExpired проверяет, что окно активности ссылки в момент now уже закончилось.
+func (l Link) IsExpired(now time.Time) bool {
+	return !l.NotAfter.IsZero() && !now.Before(l.NotAfter)
+}
+
+// LinkPatch - изменение ссылки, nil поля не изменяются.
+// Canonical задается вместе с URL и содержит его каноническую форму.
+// Указатель на нулевое время в NotBefore и NotAfter снимает границу окна активности,
+// а указатель на пустой список Rules - все правила выбора оригинальной ссылки.
+type LinkPatch struct {
+	URL       *string
+	Canonical *string
+	Title     *string
+	Tags      *[]string
+	Notes     *string
+	NotBefore *time.Time
+	NotAfter  *time.Time
+	Rules     *[]Rule
+}
+
+// IsEmpty проверяет, что изменение ничего не меняет.
+func (p LinkPatch) IsEmpty() bool {
+	return p.URL == nil && p.Title == nil && p.Tags == nil && p.Notes == nil &&
+		p.NotBefore == nil && p.NotAfter == nil && p.Rules == nil
+}
+
+// DedupKey возвращает ключ уникальности новой оригинальной ссылки, см. Link.DedupKey.
+func (p LinkPatch) DedupKey() string {
+	if p.Canonical != nil && *p.Canonical != "" {
+		return *p.Canonical
+	}
+	if p.URL != nil {
+		return *p.URL
+	}
+	return ""
+}
+
+// ApplyURL применяет к ссылке новую оригинальную ссылку вместе с ее канонической формой.
+// Варианты ссылки при этом заменяются одной новой оригинальной ссылкой.
+func (p LinkPatch) ApplyURL(l *Link) {
+	if p.URL == nil {
+		return
+	}
+	l.URL = *p.URL
+	l.Variants = nil
+	l.Canonical = ""
+	if p.Canonical != nil {
+		l.Canonical = *p.Canonical
+	}
+}
+
+// ApplyMeta применяет к ссылке изменения всего, кроме оригинальной ссылки,
+// т.к. ее смена требует проверки уникальности.
+func (p LinkPatch) ApplyMeta(l *Link) {
+	if p.Title != nil {
+		l.Title = *p.Title
+	}
+	if p.Tags != nil {
+		l.Tags = *p.Tags
+	}
+	if p.Notes != nil {
+		l.Notes = *p.Notes
+	}
+	if p.NotBefore != nil {
+		l.NotBefore = *p.NotBefore
+	}
+	if p.NotAfter != nil {
+		l.NotAfter = *p.NotAfter
+	}
+	if p.Rules != nil {
+		l.Rules = *p.Rules
+	}
+}
+
+// EmptiesWindow проверяет, что после изменения окно активности ссылки l окажется пустым:
+// его конец будет не позже начала. Учитывается и граница, которая не изменяется.
+func (p LinkPatch) EmptiesWindow(l Link) bool {
+	p.ApplyMeta(&l)
+	return !l.NotBefore.IsZero() && !l.NotAfter.IsZero() && !l.NotAfter.After(l.NotBefore)
+}
+
+// LinkSort - поле, по которому упорядочиваются ссылки пользователя.
+type LinkSort string
+
+const (
+	SortByCreated LinkSort = "created"
+	SortByID      LinkSort = "id"
+)
+
+// LinkStatus - фильтр ссылок пользователя по признаку удаления.
+type LinkStatus string
+
+const (
+	StatusAll     LinkStatus = "all"
+	StatusActive  LinkStatus = "active"
+	StatusDeleted LinkStatus = "deleted"
+)
+
+// LinkQuery - параметры выборки страницы ссылок пользователя.
+// Порядок всегда однозначный: при равенстве поля сортировки ссылки упорядочиваются по ID.
+type LinkQuery struct {
+	// Limit - максимальный размер страницы.
+	Limit int
+	// After - позиция последней ссылки предыдущей страницы, nil - первая страница.
+	After *Cursor
+	// Sort - поле сортировки, по умолчанию время создания.
+	Sort LinkSort
+	// Desc - сортировка по убыванию.
+	Desc bool
+	// Domain - подстрока домена оригинальной ссылки без учета регистра.
+	Domain string
+	// Tag - метка, которая должна быть у ссылки.
+	Tag string
+	// Status - фильтр по признаку удаления, по умолчанию все ссылки.
+	Status LinkStatus
+}
+
+// LinkPage - страница ссылок пользователя.
+type LinkPage struct {
+	Links []Link
+	// Next - позиция для запроса следующей страницы, nil - страница последняя.
+	Next *Cursor
+}
+
+// Cursor - позиция ссылки в выборке, по ней запрашивается следующая страница.
+type Cursor struct {
+	Created time.Time
+	ID      string
+}
+
+// CursorOf возвращает позицию ссылки l.
+func CursorOf(l Link) *Cursor {
+	return &Cursor{Created: l.Created, ID: l.ID}
+}
+
+// String кодирует позицию в непрозрачную для клиента строку.
+// Нулевое время создания (у ссылок, сохраненных до его появления) кодируется пустой строкой.
+func (c Cursor) String() string {
+	var nanos string
+	if !c.Created.IsZero() {
+		nanos = strconv.FormatInt(c.Created.UnixNano(), 10)
+	}
+	raw := nanos + ":" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor декодирует позицию, полученную через Cursor.String.
+func ParseCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return nil, ErrInvalidCursor
+	}
+	if nanos == "" {
+		return &Cursor{ID: id}, nil
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{Created: time.Unix(0, n).UTC(), ID: id}, nil
+}
+
+// Domain возвращает домен оригинальной ссылки в нижнем регистре.
+func Domain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// Match проверяет, что ссылка проходит фильтры выборки.
+func (q LinkQuery) Match(l Link) bool {
+	switch q.Status {
+	case StatusActive:
+		if l.Deleted {
+			return false
+		}
+	case StatusDeleted:
+		if !l.Deleted {
+			return false
+		}
+	}
+	if q.Domain != "" && !strings.Contains(Domain(l.URL), strings.ToLower(q.Domain)) {
+		return false
+	}
+	if q.Tag != "" && !l.HasTag(q.Tag) {
+		return false
+	}
+	return true
+}
+
+// before проверяет, что в выборке позиция a идет раньше позиции b.
+func (q LinkQuery) before(a, b Cursor) bool {
+	cmp := 0
+	if q.Sort != SortByID {
+		switch {
+		case a.Created.Before(b.Created):
+			cmp = -1
+		case a.Created.After(b.Created):
+			cmp = 1
+		}
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(a.ID, b.ID)
+	}
+	if q.Desc {
+		cmp = -cmp
+	}
+	return cmp < 0
+}
+
+// LinkIndex - позиции ссылок одного пользователя для постраничной выборки в хранилищах,
+// у которых нет собственного механизма выборки. Позиции упорядочены по времени создания и ID и отдельно по ID,
+// поэтому страница по курсору находится двоичным поиском и просмотром только тех ссылок, что нужны для ее заполнения.
+// Сами ссылки в индексе не хранятся, поэтому их изменения, кроме времени создания, индекс не затрагивают.
+type LinkIndex struct {
+	byCreated []Cursor
+	byID      []Cursor
+}
+
+var (
+	createdOrder = LinkQuery{Sort: SortByCreated}
+	idOrder      = LinkQuery{Sort: SortByID}
+)
+
+// Add добавляет позицию ссылки, если ее еще нет в индексе.
+func (x *LinkIndex) Add(c Cursor) {
+	x.byCreated = insertCursor(x.byCreated, c, createdOrder)
+	x.byID = insertCursor(x.byID, c, idOrder)
+}
+
+// Remove удаляет позицию ссылки из индекса.
+func (x *LinkIndex) Remove(c Cursor) {
+	x.byCreated = removeCursor(x.byCreated, c, createdOrder)
+	x.byID = removeCursor(x.byID, c, idOrder)
+}
+
+// Len возвращает количество ссылок в индексе.
+func (x *LinkIndex) Len() int {
+	return len(x.byCreated)
+}
+
+// Page возвращает страницу ссылок, отобранных и упорядоченных согласно q. Ссылки по ID возвращает link,
+// позиции, для которых ссылки не нашлось, пропускаются.
+func (x *LinkIndex) Page(q LinkQuery, link func(id string) (Link, bool)) LinkPage {
+	cursors, order := x.byCreated, createdOrder
+	if q.Sort == SortByID {
+		cursors, order = x.byID, idOrder
+	}
+	// позиции просматриваются от start по возрастанию, а при Desc - по убыванию
+	start, step := 0, 1
+	if q.Desc {
+		start, step = len(cursors)-1, -1
+	}
+	if q.After != nil {
+		i := searchCursor(cursors, *q.After, order)
+		switch {
+		case q.Desc:
+			start = i - 1
+		case i < len(cursors) && !order.before(*q.After, cursors[i]):
+			start = i + 1
+		default:
+			start = i
+		}
+	}
+
+	page := make([]Link, 0)
+	for i := start; i >= 0 && i < len(cursors); i += step {
+		l, ok := link(cursors[i].ID)
+		if !ok || !q.Match(l) {
+			continue
+		}
+		if q.Limit > 0 && len(page) == q.Limit {
+			return LinkPage{Links: page, Next: CursorOf(page[len(page)-1])}
+		}
+		page = append(page, l)
+	}
+	return LinkPage{Links: page}
+}
+
+// searchCursor возвращает место позиции c среди упорядоченных по order позиций cursors.
+func searchCursor(cursors []Cursor, c Cursor, order LinkQuery) int {
+	return sort.Search(len(cursors), func(i int) bool {
+		return !order.before(cursors[i], c)
+	})
+}
+
+func insertCursor(cursors []Cursor, c Cursor, order LinkQuery) []Cursor {
+	i := searchCursor(cursors, c, order)
+	if i < len(cursors) && !order.before(c, cursors[i]) {
+		return cursors
+	}
+	cursors = append(cursors, Cursor{})
+	copy(cursors[i+1:], cursors[i:])
+	cursors[i] = c
+	return cursors
+}
+
+func removeCursor(cursors []Cursor, c Cursor, order LinkQuery) []Cursor {
+	i := searchCursor(cursors, c, order)
+	if i == len(cursors) || order.before(c, cursors[i]) {
+		return cursors
+	}
+	return append(cursors[:i], cursors[i+1:]...)
+}
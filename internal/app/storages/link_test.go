@@ -0,0 +1,109 @@
+package storages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor Cursor
+	}{
+		{
+			name:   "with created",
+			cursor: Cursor{Created: time.Date(2022, 8, 1, 10, 0, 0, 123456789, time.UTC), ID: "1111"},
+		},
+		{
+			name:   "legacy link without created",
+			cursor: Cursor{ID: "2222"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCursor(tt.cursor.String())
+			require.NoError(t, err)
+			assert.Equal(t, tt.cursor, *got)
+		})
+	}
+
+	for _, s := range []string{"", "!!!", "MTIz", "YWJjOjExMTE"} {
+		_, err := ParseCursor(s)
+		assert.ErrorIs(t, err, ErrInvalidCursor, s)
+	}
+}
+
+func TestLinkIndex_Page(t *testing.T) {
+	day := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	links := map[string]Link{
+		"1111": {ID: "1111", URL: "https://ya.ru", Created: day.Add(2 * time.Hour)},
+		"2222": {ID: "2222", URL: "https://yandex.ru", Created: day.Add(time.Hour)},
+		"3333": {ID: "3333", URL: "https://go.dev", Created: day.Add(time.Hour)},
+		"4444": {ID: "4444", URL: "https://ya.ru/maps", Created: day},
+		"5555": {ID: "5555", URL: "https://go.dev/doc", Created: day.Add(3 * time.Hour)},
+	}
+	x := &LinkIndex{}
+	for _, l := range links {
+		x.Add(*CursorOf(l))
+	}
+	// повторное добавление позиции ничего не меняет, удаленная позиция не попадает в выборку
+	x.Add(*CursorOf(links["1111"]))
+	x.Remove(*CursorOf(links["5555"]))
+	require.Equal(t, 4, x.Len())
+
+	link := func(id string) (Link, bool) {
+		l, ok := links[id]
+		return l, ok
+	}
+	ids := func(page LinkPage) []string {
+		out := make([]string, 0, len(page.Links))
+		for _, l := range page.Links {
+			out = append(out, l.ID)
+		}
+		return out
+	}
+	tests := []struct {
+		name string
+		q    LinkQuery
+		want []string
+		next *Cursor
+	}{
+		{
+			name: "first page",
+			q:    LinkQuery{Limit: 2},
+			want: []string{"4444", "2222"},
+			next: CursorOf(links["2222"]),
+		},
+		{
+			name: "after cursor",
+			q:    LinkQuery{Limit: 2, After: CursorOf(links["2222"])},
+			want: []string{"3333", "1111"},
+		},
+		{
+			name: "descending after cursor",
+			q:    LinkQuery{Desc: true, After: CursorOf(links["3333"])},
+			want: []string{"2222", "4444"},
+		},
+		{
+			name: "by id after removed link",
+			q:    LinkQuery{Sort: SortByID, Limit: 1, After: &Cursor{ID: "1112"}},
+			want: []string{"2222"},
+			next: CursorOf(links["2222"]),
+		},
+		{
+			name: "filtered",
+			q:    LinkQuery{Domain: "ya.ru", Limit: 2},
+			want: []string{"4444", "1111"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := x.Page(tt.q, link)
+			assert.Equal(t, tt.want, ids(page))
+			assert.Equal(t, tt.next, page.Next)
+		})
+	}
+}
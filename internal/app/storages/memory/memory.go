@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers"
 	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
@@ -13,8 +14,11 @@ import (
 // Storage реализует хранение ссылок в памяти.
 // Является потоко безопасной реализацией Repository
 type Storage struct {
-	storage map[string]map[string]string
-	mx      sync.Mutex
+	// storage - map[user]map[id]link
+	storage map[string]map[string]*storages.Link
+	// pages - map[user]позиции ссылок пользователя для постраничной выборки
+	pages map[string]*storages.LinkIndex
+	mx    sync.Mutex
 }
 
 var _ handlers.Repository = (*Storage)(nil)
@@ -22,26 +26,57 @@ var _ handlers.Repository = (*Storage)(nil)
 // NewStorage cоздает и возвращает экземпляр Storage
 func NewStorage() *Storage {
 	s := Storage{}
-	s.storage = make(map[string]map[string]string)
+	s.storage = make(map[string]map[string]*storages.Link)
+	s.pages = make(map[string]*storages.LinkIndex)
 	return &s
 }
 
-// Store сохраняет ссылку в хранилище с указанным id
-func (s *Storage) Store(ctx context.Context, user string, link string) (id string, err error) {
+// Store сохраняет ссылку в хранилище с указанным id. Если ссылка с тем же ключом уникальности уже есть,
+// возвращает ее id и ошибку handlers.ErrLinkIsAlreadyShortened.
+func (s *Storage) Store(ctx context.Context, user string, link storages.Link) (id string, err error) {
 	s.mx.Lock()
 	defer s.mx.Unlock()
 
+	if other, ok := s.findKey(link.DedupKey()); ok {
+		return other.ID, handlers.ErrLinkIsAlreadyShortened
+	}
 	id, err = utils.CreateShortID(ctx, s.isExist)
 	if err != nil {
 		return "", err
 	}
 
+	s.store(user, id, link)
+	return id, nil
+}
+
+func (s *Storage) store(user string, id string, link storages.Link) {
 	if _, ok := s.storage[user]; !ok {
-		s.storage[user] = make(map[string]string)
+		s.storage[user] = make(map[string]*storages.Link)
+		s.pages[user] = &storages.LinkIndex{}
+	}
+	now := time.Now().UTC()
+	s.pages[user].Add(storages.Cursor{Created: now, ID: id})
+	s.storage[user][id] = &storages.Link{
+		ID:              id,
+		User:            user,
+		URL:             link.URL,
+		Canonical:       link.Canonical,
+		Created:         now,
+		Updated:         now,
+		Title:           link.Title,
+		Tags:            link.Tags,
+		Notes:           link.Notes,
+		Redirect:        link.Redirect,
+		ForwardQuery:    link.ForwardQuery,
+		ForwardPath:     link.ForwardPath,
+		PasswordHash:    link.PasswordHash,
+		MaxClicks:       link.MaxClicks,
+		RemainingClicks: link.MaxClicks,
+		NotBefore:       link.NotBefore,
+		NotAfter:        link.NotAfter,
+		Variants:        link.Variants,
+		Rules:           link.Rules,
 	}
-
-	s.storage[user][id] = link
-	return id, nil
 }
 
 // isExist проверяет наличие id в сторадже
@@ -56,65 +91,228 @@ func (s *Storage) isExist(_ context.Context, id string) bool {
 }
 
 // Restore возвращает исходную ссылку по переданному короткому ID
-func (s *Storage) Restore(_ context.Context, id string) (link string, err error) {
+func (s *Storage) Restore(_ context.Context, id string) (link storages.Link, err error) {
 	s.mx.Lock()
 	defer s.mx.Unlock()
 
 	for _, user := range s.storage {
 		l, ok := user[id]
-		if ok {
-			return l, nil
+		if !ok {
+			continue
+		}
+		if l.Deleted {
+			return *l, handlers.ErrLinkIsDeleted
 		}
+		return *l, nil
 	}
 
-	return "", fmt.Errorf(storages.ErrLinkNotFound, id)
+	return storages.Link{}, fmt.Errorf(storages.ErrLinkNotFound, handlers.ErrLinkIsNotFound, id)
 }
 
 // Unstore - помечает список ранее сохраненных ссылок удаленными
 // только тех ссылок, которые принадлежат пользователю
-// Только для совместимости контракта
-func (s *Storage) Unstore(_ context.Context, _ string, _ []string) {
-	// ToDo реализовать для практики
-	panic("not implemented for memory storage")
+func (s *Storage) Unstore(_ context.Context, user string, ids []string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	now := time.Now().UTC()
+	for _, id := range ids {
+		l, ok := s.storage[user][id]
+		if !ok || l.Deleted {
+			continue
+		}
+		l.Deleted = true
+		l.Updated = now
+	}
 }
 
-// GetUserStorage возвращает map[id]link ранее сокращенных ссылок указанным пользователем
-func (s *Storage) GetUserStorage(_ context.Context, user string) map[string]string {
+// Update изменяет ссылку id, принадлежащую пользователю.
+// Оригинальная ссылка должна оставаться уникальной среди всех ссылок хранилища.
+func (s *Storage) Update(_ context.Context, user string, id string, patch storages.LinkPatch) (storages.Link, error) {
 	s.mx.Lock()
 	defer s.mx.Unlock()
 
-	ub, ok := s.storage[user]
-	if !ok {
-		return map[string]string{}
+	l, ok := s.storage[user][id]
+	switch {
+	case !ok:
+		return storages.Link{}, handlers.ErrLinkIsNotFound
+	case l.Deleted:
+		return storages.Link{}, handlers.ErrLinkIsDeleted
+	case patch.EmptiesWindow(*l):
+		return storages.Link{}, handlers.ErrInvalidWindow
+	}
+
+	if patch.URL != nil && *patch.URL != l.URL {
+		if other, ok := s.findKey(patch.DedupKey()); ok && other.ID != id {
+			return *other, handlers.ErrLinkIsAlreadyShortened
+		}
+		patch.ApplyURL(l)
 	}
-	return ub
+	patch.ApplyMeta(l)
+	l.Updated = time.Now().UTC()
+	return *l, nil
 }
 
-// StoreBatch сохраняет пакет ссылок из map[correlation_id]original_link и возвращает map[correlation_id]short_link
-func (s *Storage) StoreBatch(ctx context.Context, user string, batchIn map[string]string) (batchOut map[string]string, err error) {
+// SetBlocked устанавливает или снимает блокировку ссылки id, кому бы она ни принадлежала.
+func (s *Storage) SetBlocked(_ context.Context, id string, blocked bool) error {
 	s.mx.Lock()
 	defer s.mx.Unlock()
 
-	if _, ok := s.storage[user]; !ok {
-		s.storage[user] = make(map[string]string)
+	for _, user := range s.storage {
+		if l, ok := user[id]; ok {
+			l.Blocked = blocked
+			return nil
+		}
 	}
+	return fmt.Errorf(storages.ErrLinkNotFound, handlers.ErrLinkIsNotFound, id)
+}
+
+// ConsumeClick уменьшает остаток переходов по ссылке id с ограничением переходов
+func (s *Storage) ConsumeClick(_ context.Context, id string) (int, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for _, user := range s.storage {
+		l, ok := user[id]
+		if !ok {
+			continue
+		}
+		if l.IsExhausted() {
+			return 0, handlers.ErrLinkIsExhausted
+		}
+		if l.MaxClicks > 0 {
+			l.RemainingClicks--
+		}
+		return l.RemainingClicks, nil
+	}
+	return 0, fmt.Errorf(storages.ErrLinkNotFound, handlers.ErrLinkIsNotFound, id)
+}
+
+// findKey ищет ссылку по ключу уникальности среди всех пользователей
+func (s *Storage) findKey(key string) (*storages.Link, bool) {
+	for _, user := range s.storage {
+		for _, l := range user {
+			if l.DedupKey() == key {
+				return l, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Undelete - снимает пометку удаления со списка ранее удаленных ссылок
+// только тех ссылок, которые принадлежат пользователю
+func (s *Storage) Undelete(_ context.Context, user string, ids []string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	now := time.Now().UTC()
+	for _, id := range ids {
+		l, ok := s.storage[user][id]
+		if !ok || !l.Deleted {
+			continue
+		}
+		l.Deleted = false
+		l.Updated = now
+	}
+}
+
+// GetUserLinks возвращает страницу ссылок пользователя, отобранных и упорядоченных согласно q
+func (s *Storage) GetUserLinks(_ context.Context, user string, q storages.LinkQuery) (storages.LinkPage, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	x, ok := s.pages[user]
+	if !ok {
+		return storages.LinkPage{Links: []storages.Link{}}, nil
+	}
+	return x.Page(q, func(id string) (storages.Link, bool) {
+		l, ok := s.storage[user][id]
+		if !ok {
+			return storages.Link{}, false
+		}
+		return *l, true
+	}), nil
+}
+
+// StoreBatch сохраняет пакет ссылок из map[correlation_id]original_link и возвращает map[correlation_id]short_link.
+// Для уже сохраненных ссылок возвращается их id и ошибка handlers.ErrLinkIsAlreadyShortened.
+func (s *Storage) StoreBatch(ctx context.Context, user string, batchIn map[string]storages.Link) (batchOut map[string]string, err error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
 
 	batchOut = make(map[string]string)
 	var id string
+	conflict := false
 	// требуется go 1.18, а в yandex_practicum видимо еще не обновили go
 	// maps.Copy(s.storage[user], batch)
 	for corrID, link := range batchIn {
+		if other, ok := s.findKey(link.DedupKey()); ok {
+			batchOut[corrID] = other.ID
+			conflict = true
+			continue
+		}
 		id, err = utils.CreateShortID(ctx, s.isExist)
 		if err != nil {
 			return nil, err
 		}
-		s.storage[user][id] = link
+		s.store(user, id, link)
 		batchOut[corrID] = id
 	}
 
+	if conflict {
+		return batchOut, handlers.ErrLinkIsAlreadyShortened
+	}
 	return batchOut, nil
 }
 
+// FindShortened возвращает id ранее сохраненных ссылок с ключами уникальности keys
+func (s *Storage) FindShortened(_ context.Context, keys []string) (map[string]string, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	found := make(map[string]string)
+	for _, key := range keys {
+		if l, ok := s.findKey(key); ok {
+			found[key] = l.ID
+		}
+	}
+	return found, nil
+}
+
+// CountLinks возвращает количество не удаленных ссылок пользователя
+func (s *Storage) CountLinks(_ context.Context, user string) (count int, err error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for _, l := range s.storage[user] {
+		if !l.Deleted {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Stats возвращает количество ссылок, пользователей и удаленных ссылок
+func (s *Storage) Stats(_ context.Context) (stats storages.Stats, err error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for _, links := range s.storage {
+		if len(links) == 0 {
+			continue
+		}
+		stats.Users++
+		stats.URLs += len(links)
+		for _, l := range links {
+			if l.Deleted {
+				stats.Deleted++
+			}
+		}
+	}
+	return stats, nil
+}
+
 // Ping проверяет, что экземпляр Storage создан корректно, например с помощью NewStorage()
 func (s *Storage) Ping(_ context.Context) error {
 	if s.storage == nil {
@@ -2,12 +2,32 @@ package memory
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/handlers"
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/storages"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// fromMap строит хранилище из map[user]map[id]link
+func fromMap(m map[string]map[string]string) *Storage {
+	s := NewStorage()
+	for user, links := range m {
+		s.storage[user] = make(map[string]*storages.Link, len(links))
+		s.pages[user] = &storages.LinkIndex{}
+		for id, link := range links {
+			s.storage[user][id] = &storages.Link{ID: id, User: user, URL: link}
+			s.pages[user].Add(storages.Cursor{ID: id})
+		}
+	}
+	return s
+}
+
 func TestLinkStorage_Restore(t *testing.T) {
 	type fields struct {
 		storage map[string]map[string]string
@@ -51,14 +71,12 @@ func TestLinkStorage_Restore(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ls := Storage{
-				storage: tt.fields.storage,
-			}
+			ls := fromMap(tt.fields.storage)
 			gotLink, err := ls.Restore(context.Background(), tt.args.id)
 			if !tt.wantErr(t, err, fmt.Sprintf("Restore(%v)", tt.args.id)) {
 				return
 			}
-			assert.Equalf(t, tt.wantLink, gotLink, "Restore(%v)", tt.args.id)
+			assert.Equalf(t, tt.wantLink, gotLink.URL, "Restore(%v)", tt.args.id)
 		})
 	}
 }
@@ -98,11 +116,9 @@ func TestLinkStorage_Store(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ms := &Storage{
-				storage: tt.fields.storage,
-			}
+			ms := fromMap(tt.fields.storage)
 			ctx := context.Background()
-			_, err := ms.Store(ctx, tt.args.user, tt.args.link)
+			_, err := ms.Store(ctx, tt.args.user, storages.Link{URL: tt.args.link})
 			if !tt.wantErr(t, err, fmt.Sprintf("Store(%v, %v, %v)", ctx, tt.args.user, tt.args.link)) {
 				return
 			}
@@ -150,62 +166,279 @@ func TestMemoryStorage_isExist(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ms := &Storage{
-				storage: tt.fields.storage,
-			}
+			ms := fromMap(tt.fields.storage)
 			ctx := context.Background()
 			assert.Equalf(t, tt.want, ms.isExist(ctx, tt.args.id), "IsExist(%v, %v)", ctx, tt.args.id)
 		})
 	}
 }
 
-func TestStorage_GetUserStorage(t *testing.T) {
-	type fields struct {
-		storage map[string]map[string]string
+func TestLinkStorage_Stats(t *testing.T) {
+	ls := fromMap(map[string]map[string]string{
+		"xxxx": {"1111": "https://ya.ru", "2222": "https://yandex.ru"},
+		"yyyy": {"3333": "https://go.dev"},
+		"zzzz": {},
+	})
+	stats, err := ls.Stats(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, storages.Stats{URLs: 3, Users: 2}, stats)
+}
+
+func TestStorage_GetUserLinks(t *testing.T) {
+	day := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	s := NewStorage()
+	s.storage["xxxx"] = map[string]*storages.Link{
+		"1111": {ID: "1111", URL: "https://ya.ru", Created: day.Add(2 * time.Hour)},
+		"2222": {ID: "2222", URL: "https://yandex.ru/maps", Created: day.Add(time.Hour)},
+		"3333": {ID: "3333", URL: "https://go.dev", Created: day.Add(time.Hour), Deleted: true},
+		"4444": {ID: "4444", URL: "https://YANDEX.ru", Created: day},
 	}
-	type args struct {
-		user string
+	s.pages["xxxx"] = &storages.LinkIndex{}
+	for _, l := range s.storage["xxxx"] {
+		s.pages["xxxx"].Add(*storages.CursorOf(*l))
 	}
-	tests := []struct {
-		want   map[string]string
-		fields fields
-		name   string
-		args   args
-	}{
-		{
-			name: "empty bucket",
-			fields: fields{storage: map[string]map[string]string{
-				"xxxx": {
-					"1111": "https://ya.ru",
-					"2222": "https://yandex.ru",
-					"3333": "https://practicum.yandex.ru/",
-				},
-			}},
-			args: args{user: "yyyy"},
-			want: make(map[string]string),
-		},
-		{
-			name: "full bucket",
-			fields: fields{storage: map[string]map[string]string{
-				"xxxx": {
-					"1111": "https://ya.ru",
-					"2222": "https://yandex.ru",
-					"3333": "https://practicum.yandex.ru/",
-				},
-			}},
-			args: args{user: "xxxx"},
-			want: map[string]string{"1111": "https://ya.ru", "2222": "https://yandex.ru", "3333": "https://practicum.yandex.ru/"},
-		},
+	ids := func(page storages.LinkPage) []string {
+		out := make([]string, 0, len(page.Links))
+		for _, l := range page.Links {
+			out = append(out, l.ID)
+		}
+		return out
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			s := &Storage{
-				storage: tt.fields.storage,
+	ctx := context.Background()
+
+	// постраничный обход по времени создания, при равенстве - по id
+	page, err := s.GetUserLinks(ctx, "xxxx", storages.LinkQuery{Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"4444", "2222", "3333"}, ids(page))
+	require.NotNil(t, page.Next)
+	page, err = s.GetUserLinks(ctx, "xxxx", storages.LinkQuery{Limit: 3, After: page.Next})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1111"}, ids(page))
+	assert.Nil(t, page.Next)
+
+	page, err = s.GetUserLinks(ctx, "xxxx", storages.LinkQuery{Sort: storages.SortByID, Desc: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"4444", "3333", "2222", "1111"}, ids(page))
+
+	page, err = s.GetUserLinks(ctx, "xxxx", storages.LinkQuery{Domain: "Yandex", Status: storages.StatusActive})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"4444", "2222"}, ids(page))
+
+	page, err = s.GetUserLinks(ctx, "xxxx", storages.LinkQuery{Status: storages.StatusDeleted})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3333"}, ids(page))
+
+	page, err = s.GetUserLinks(ctx, "yyyy", storages.LinkQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Links)
+}
+
+func TestStorage_Unstore(t *testing.T) {
+	s := NewStorage()
+	ctx := context.Background()
+	id, err := s.Store(ctx, "xxxx", storages.Link{URL: "https://ya.ru"})
+	require.NoError(t, err)
+
+	// чужие ссылки не удаляются
+	s.Unstore(ctx, "yyyy", []string{id})
+	_, err = s.Restore(ctx, id)
+	require.NoError(t, err)
+
+	count, err := s.CountLinks(ctx, "xxxx")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	s.Unstore(ctx, "xxxx", []string{id})
+	link, err := s.Restore(ctx, id)
+	assert.ErrorIs(t, err, handlers.ErrLinkIsDeleted)
+	// удаленная ссылка возвращается вместе с ошибкой, чтобы можно было проверить ее владельца
+	assert.Equal(t, "xxxx", link.User)
+	assert.True(t, link.Deleted)
+
+	// удаленные ссылки не занимают квоту пользователя
+	count, err = s.CountLinks(ctx, "xxxx")
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	page, err := s.GetUserLinks(ctx, "xxxx", storages.LinkQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Links, 1)
+	assert.True(t, page.Links[0].Deleted)
+	assert.False(t, page.Links[0].Updated.Before(page.Links[0].Created))
+
+	stats, err := s.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, storages.Stats{URLs: 1, Users: 1, Deleted: 1}, stats)
+}
+
+func TestStorage_Update(t *testing.T) {
+	s := NewStorage()
+	ctx := context.Background()
+	id, err := s.Store(ctx, "xxxx", storages.Link{URL: "https://ya.ru"})
+	require.NoError(t, err)
+	otherID, err := s.Store(ctx, "yyyy", storages.Link{URL: "https://go.dev"})
+	require.NoError(t, err)
+
+	newURL := "https://yandex.ru"
+	l, err := s.Update(ctx, "xxxx", id, storages.LinkPatch{URL: &newURL})
+	require.NoError(t, err)
+	assert.Equal(t, newURL, l.URL)
+	link, err := s.Restore(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, newURL, link.URL)
+
+	// чужие ссылки не изменяются
+	_, err = s.Update(ctx, "yyyy", id, storages.LinkPatch{URL: &newURL})
+	assert.ErrorIs(t, err, handlers.ErrLinkIsNotFound)
+
+	// оригинальные ссылки уникальны
+	taken := "https://go.dev"
+	l, err = s.Update(ctx, "xxxx", id, storages.LinkPatch{URL: &taken})
+	assert.ErrorIs(t, err, handlers.ErrLinkIsAlreadyShortened)
+	assert.Equal(t, otherID, l.ID)
+
+	// метаданные изменяются независимо от оригинальной ссылки
+	title, tags := "Yandex", []string{"search"}
+	l, err = s.Update(ctx, "xxxx", id, storages.LinkPatch{Title: &title, Tags: &tags})
+	require.NoError(t, err)
+	assert.Equal(t, newURL, l.URL)
+	assert.Equal(t, title, l.Title)
+	page, err := s.GetUserLinks(ctx, "xxxx", storages.LinkQuery{Tag: "search"})
+	require.NoError(t, err)
+	assert.Len(t, page.Links, 1)
+
+	// одна граница окна активности сверяется с уже сохраненной
+	finish := time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC)
+	_, err = s.Update(ctx, "xxxx", id, storages.LinkPatch{NotAfter: &finish})
+	require.NoError(t, err)
+	start := finish.Add(time.Hour)
+	_, err = s.Update(ctx, "xxxx", id, storages.LinkPatch{NotBefore: &start})
+	assert.ErrorIs(t, err, handlers.ErrInvalidWindow)
+	link, err = s.Restore(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, link.NotBefore.IsZero())
+
+	s.Unstore(ctx, "xxxx", []string{id})
+	_, err = s.Update(ctx, "xxxx", id, storages.LinkPatch{URL: &newURL})
+	assert.ErrorIs(t, err, handlers.ErrLinkIsDeleted)
+}
+
+func TestStorage_Undelete(t *testing.T) {
+	s := NewStorage()
+	ctx := context.Background()
+	id, err := s.Store(ctx, "xxxx", storages.Link{URL: "https://ya.ru"})
+	require.NoError(t, err)
+	s.Unstore(ctx, "xxxx", []string{id})
+
+	// чужие ссылки не восстанавливаются
+	s.Undelete(ctx, "yyyy", []string{id})
+	_, err = s.Restore(ctx, id)
+	assert.ErrorIs(t, err, handlers.ErrLinkIsDeleted)
+
+	s.Undelete(ctx, "xxxx", []string{id, "5555"})
+	link, err := s.Restore(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", link.URL)
+
+	page, err := s.GetUserLinks(ctx, "xxxx", storages.LinkQuery{Status: storages.StatusActive})
+	require.NoError(t, err)
+	assert.Len(t, page.Links, 1)
+}
+
+func TestStorage_Dedup(t *testing.T) {
+	s := NewStorage()
+	ctx := context.Background()
+	id, err := s.Store(ctx, "xxxx", storages.Link{URL: "HTTP://Example.com:80/a", Canonical: "http://example.com/a"})
+	require.NoError(t, err)
+
+	// повтор ищется по канонической форме, независимо от пользователя
+	otherID, err := s.Store(ctx, "yyyy", storages.Link{URL: "http://example.com/./a", Canonical: "http://example.com/a"})
+	assert.ErrorIs(t, err, handlers.ErrLinkIsAlreadyShortened)
+	assert.Equal(t, id, otherID)
+
+	batch, err := s.StoreBatch(ctx, "yyyy", map[string]storages.Link{
+		"1": {URL: "http://example.com:80/a", Canonical: "http://example.com/a"},
+		"2": {URL: "https://go.dev", Canonical: "https://go.dev/"},
+	})
+	assert.ErrorIs(t, err, handlers.ErrLinkIsAlreadyShortened)
+	assert.Equal(t, id, batch["1"])
+	assert.NotEqual(t, id, batch["2"])
+
+	found, err := s.FindShortened(ctx, []string{"http://example.com/a", "https://go.dev/", "https://ya.ru/"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"http://example.com/a": id, "https://go.dev/": batch["2"]}, found)
+
+	// смена ссылки на эквивалентную себе не конфликтует
+	newURL, canonical := "http://EXAMPLE.com/a", "http://example.com/a"
+	l, err := s.Update(ctx, "xxxx", id, storages.LinkPatch{URL: &newURL, Canonical: &canonical})
+	require.NoError(t, err)
+	assert.Equal(t, newURL, l.URL)
+	assert.Equal(t, canonical, l.Canonical)
+
+	taken, canonical := "https://go.dev", "https://go.dev/"
+	l, err = s.Update(ctx, "xxxx", id, storages.LinkPatch{URL: &taken, Canonical: &canonical})
+	assert.ErrorIs(t, err, handlers.ErrLinkIsAlreadyShortened)
+	assert.Equal(t, batch["2"], l.ID)
+}
+
+func TestStorage_SetBlocked(t *testing.T) {
+	s := NewStorage()
+	ctx := context.Background()
+	id, err := s.Store(ctx, "xxxx", storages.Link{URL: "https://ya.ru"})
+	require.NoError(t, err)
+
+	require.NoError(t, s.SetBlocked(ctx, id, true))
+	link, err := s.Restore(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, link.Blocked)
+	assert.ErrorIs(t, s.SetBlocked(ctx, "5555", true), handlers.ErrLinkIsNotFound)
+
+	require.NoError(t, s.SetBlocked(ctx, id, false))
+	link, err = s.Restore(ctx, id)
+	require.NoError(t, err)
+	assert.False(t, link.Blocked)
+}
+
+func TestStorage_ConsumeClick(t *testing.T) {
+	s := NewStorage()
+	ctx := context.Background()
+	limited, err := s.Store(ctx, "xxxx", storages.Link{URL: "https://ya.ru", MaxClicks: 10})
+	require.NoError(t, err)
+	unlimited, err := s.Store(ctx, "xxxx", storages.Link{URL: "https://go.dev"})
+	require.NoError(t, err)
+
+	// параллельные переходы не расходуют больше ограничения
+	var (
+		wg        sync.WaitGroup
+		mx        sync.Mutex
+		consumed  int
+		exhausted int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.ConsumeClick(ctx, limited)
+			mx.Lock()
+			defer mx.Unlock()
+			if errors.Is(err, handlers.ErrLinkIsExhausted) {
+				exhausted++
+				return
 			}
-			assert.Equalf(
-				t, tt.want,
-				s.GetUserStorage(context.Background(), tt.args.user),
-				"GetUserStorage(context.Background(), %v)", tt.args.user)
-		})
+			assert.NoError(t, err)
+			consumed++
+		}()
 	}
+	wg.Wait()
+	assert.Equal(t, 10, consumed)
+	assert.Equal(t, 40, exhausted)
+
+	link, err := s.Restore(ctx, limited)
+	require.NoError(t, err)
+	assert.True(t, link.IsExhausted())
+
+	_, err = s.ConsumeClick(ctx, unlimited)
+	assert.NoError(t, err)
+	_, err = s.ConsumeClick(ctx, "5555")
+	assert.ErrorIs(t, err, handlers.ErrLinkIsNotFound)
 }
@@ -10,5 +10,16 @@ var (
 )
 
 const (
-	ErrLinkNotFound = "link not found with passed id %s"
+	// ErrLinkNotFound - формат ошибки поиска ссылки, первым аргументом ожидается handlers.ErrLinkIsNotFound.
+	ErrLinkNotFound = "%w with passed id %s"
 )
+
+// Stats - сводная статистика хранилища ссылок.
+type Stats struct {
+	// URLs - количество сокращенных ссылок, включая удаленные.
+	URLs int
+	// Users - количество пользователей, сокращавших ссылки.
+	Users int
+	// Deleted - количество удаленных ссылок.
+	Deleted int
+}
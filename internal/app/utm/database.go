@@ -0,0 +1,129 @@
+package utm
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	createTemplatesStatement = `CREATE TABLE IF NOT EXISTS utm_templates
+								(
+								    user_id VARCHAR NOT NULL,
+								    name    VARCHAR NOT NULL,
+								    params  JSONB   NOT NULL,
+								    CONSTRAINT utm_templates_pk PRIMARY KEY (user_id, name)
+								);`
+	saveTemplateStatement = `INSERT INTO utm_templates (user_id, name, params)
+							 VALUES ($1, $2, $3)
+							 ON CONFLICT (user_id, name) DO UPDATE SET params = EXCLUDED.params`
+	getTemplateQuery        = `SELECT name, params FROM utm_templates WHERE user_id = $1 AND name = $2`
+	listTemplatesQuery      = `SELECT name, params FROM utm_templates WHERE user_id = $1 ORDER BY name`
+	deleteTemplateStatement = `DELETE FROM utm_templates WHERE user_id = $1 AND name = $2`
+)
+
+// DatabaseStore реализует хранение шаблонов в таблице PostgreSQL.
+// Соединение с БД разделяется с хранилищем ссылок, поэтому DatabaseStore его не закрывает.
+type DatabaseStore struct {
+	database *sql.DB
+}
+
+var _ Store = (*DatabaseStore)(nil)
+
+// NewDatabaseStore создает и возвращает DatabaseStore, при необходимости создает таблицу шаблонов.
+func NewDatabaseStore(db *sql.DB) (*DatabaseStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, createTemplatesStatement); err != nil {
+		return nil, err
+	}
+	return &DatabaseStore{database: db}, nil
+}
+
+// Save создает или заменяет шаблон пользователя.
+func (s *DatabaseStore) Save(ctx context.Context, user string, t Template) error {
+	params, err := json.Marshal(t.Params)
+	if err != nil {
+		return err
+	}
+	_, err = s.database.ExecContext(ctx, saveTemplateStatement, user, t.Name, params)
+	return err
+}
+
+// Get возвращает шаблон пользователя по имени.
+func (s *DatabaseStore) Get(ctx context.Context, user string, name string) (Template, error) {
+	t, err := scanTemplate(s.database.QueryRowContext(ctx, getTemplateQuery, user, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Template{}, ErrTemplateNotFound
+	}
+	return t, err
+}
+
+// List возвращает шаблоны пользователя, упорядоченные по имени.
+func (s *DatabaseStore) List(ctx context.Context, user string) ([]Template, error) {
+	rows, err := s.database.QueryContext(ctx, listTemplatesQuery, user)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Err(err).Send()
+		}
+	}()
+
+	templates := make([]Template, 0)
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// Delete удаляет шаблон пользователя.
+func (s *DatabaseStore) Delete(ctx context.Context, user string, name string) error {
+	res, err := s.database.ExecContext(ctx, deleteTemplateStatement, user, name)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+// Close ничего не делает: соединение с БД закрывает хранилище ссылок.
+func (s *DatabaseStore) Close() error {
+	return nil
+}
+
+// scanner - общее у *sql.Row и *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTemplate читает шаблон из строки результата с колонками name, params.
+func scanTemplate(row scanner) (Template, error) {
+	var (
+		t      Template
+		params []byte
+	)
+	if err := row.Scan(&t.Name, &params); err != nil {
+		return Template{}, err
+	}
+	if err := json.Unmarshal(params, &t.Params); err != nil {
+		return Template{}, err
+	}
+	return t, nil
+}
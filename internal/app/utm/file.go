@@ -0,0 +1,106 @@
+package utm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/UndeadDemidov/yandex-praktikum/internal/app/utils"
+)
+
+// FileStore реализует хранение шаблонов в файле - журнале изменений в формате JSON lines.
+// При открытии журнал целиком читается в память, каждое изменение дописывается в конец файла.
+type FileStore struct {
+	MemoryStore
+	file    *os.File
+	encoder *json.Encoder
+}
+
+var _ Store = (*FileStore)(nil)
+
+// change - запись журнала: сохранение шаблона или его удаление.
+type change struct {
+	User     string   `json:"user"`
+	Template Template `json:"template"`
+	Deleted  bool     `json:"deleted,omitempty"`
+}
+
+// NewFileStore создает и возвращает FileStore, изменения дописываются в конец указанного файла.
+func NewFileStore(filename string) (s *FileStore, err error) {
+	if err = utils.CheckFilename(filename); err != nil {
+		return nil, err
+	}
+	s = &FileStore{MemoryStore: MemoryStore{templates: make(map[string]map[string]Template)}}
+	if err = s.load(filename); err != nil {
+		return nil, err
+	}
+	s.file, err = os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s.encoder = json.NewEncoder(s.file)
+	return s, nil
+}
+
+// load применяет журнал к пустому хранилищу.
+func (s *FileStore) load(filename string) error {
+	f, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	for {
+		var c change
+		err = decoder.Decode(&c)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if c.Deleted {
+			delete(s.templates[c.User], c.Template.Name)
+			continue
+		}
+		s.save(c.User, c.Template)
+	}
+}
+
+// Save дописывает шаблон в журнал и сохраняет его в памяти.
+func (s *FileStore) Save(_ context.Context, user string, t Template) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if err := s.encoder.Encode(change{User: user, Template: t}); err != nil {
+		return err
+	}
+	s.save(user, t)
+	return nil
+}
+
+// Delete дописывает удаление шаблона в журнал и удаляет его из памяти.
+func (s *FileStore) Delete(_ context.Context, user string, name string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if _, ok := s.templates[user][name]; !ok {
+		return ErrTemplateNotFound
+	}
+	if err := s.encoder.Encode(change{User: user, Template: Template{Name: name}, Deleted: true}); err != nil {
+		return err
+	}
+	delete(s.templates[user], name)
+	return nil
+}
+
+// Close закрывает файл журнала.
+func (s *FileStore) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,45 @@
+package utm
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "utm.json")
+	s, err := NewFileStore(filename)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	news := Template{Name: "news", Params: map[string]string{"utm_source": "mail"}}
+	ads := Template{Name: "ads", Params: map[string]string{"utm_source": "ads"}}
+	require.NoError(t, s.Save(ctx, "xxxx", news))
+	require.NoError(t, s.Save(ctx, "xxxx", ads))
+	require.NoError(t, s.Save(ctx, "yyyy", news))
+	news.Params = map[string]string{"utm_source": "mail", "utm_medium": "email"}
+	require.NoError(t, s.Save(ctx, "xxxx", news))
+	require.NoError(t, s.Delete(ctx, "yyyy", "news"))
+	assert.ErrorIs(t, s.Delete(ctx, "yyyy", "news"), ErrTemplateNotFound)
+	require.NoError(t, s.Close())
+
+	// журнал переживает перезапуск
+	s, err = NewFileStore(filename)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, s.Close())
+	}()
+
+	list, err := s.List(ctx, "xxxx")
+	require.NoError(t, err)
+	assert.Equal(t, []Template{ads, news}, list)
+
+	_, err = s.Get(ctx, "yyyy", "news")
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+	got, err := s.Get(ctx, "xxxx", "news")
+	require.NoError(t, err)
+	assert.Equal(t, news, got)
+}
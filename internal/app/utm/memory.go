@@ -0,0 +1,81 @@
+package utm
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore реализует хранение шаблонов в памяти.
+type MemoryStore struct {
+	// templates - map[user]map[name]Template
+	templates map[string]map[string]Template
+	mx        sync.RWMutex
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore создает и возвращает пустой MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{templates: make(map[string]map[string]Template)}
+}
+
+// Save создает или заменяет шаблон пользователя.
+func (s *MemoryStore) Save(_ context.Context, user string, t Template) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.save(user, t)
+	return nil
+}
+
+func (s *MemoryStore) save(user string, t Template) {
+	if _, ok := s.templates[user]; !ok {
+		s.templates[user] = make(map[string]Template)
+	}
+	params := make(map[string]string, len(t.Params))
+	for k, v := range t.Params {
+		params[k] = v
+	}
+	s.templates[user][t.Name] = Template{Name: t.Name, Params: params}
+}
+
+// Get возвращает шаблон пользователя по имени.
+func (s *MemoryStore) Get(_ context.Context, user string, name string) (Template, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	t, ok := s.templates[user][name]
+	if !ok {
+		return Template{}, ErrTemplateNotFound
+	}
+	return t, nil
+}
+
+// List возвращает шаблоны пользователя, упорядоченные по имени.
+func (s *MemoryStore) List(_ context.Context, user string) ([]Template, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	templates := make([]Template, 0, len(s.templates[user]))
+	for _, t := range s.templates[user] {
+		templates = append(templates, t)
+	}
+	return sortTemplates(templates), nil
+}
+
+// Delete удаляет шаблон пользователя.
+func (s *MemoryStore) Delete(_ context.Context, user string, name string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if _, ok := s.templates[user][name]; !ok {
+		return ErrTemplateNotFound
+	}
+	delete(s.templates[user], name)
+	return nil
+}
+
+// Close ничего не делает, требуется только для совместимости с контрактом.
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,112 @@
+// Package utm реализует именованные шаблоны UTM меток пользователя.
+// При сокращении ссылки пользователь может сослаться на свой шаблон,
+// и его параметры будут добавлены в оригинальную ссылку до сохранения.
+package utm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	paramPrefix    = "utm_"
+	maxNameLength  = 64
+	maxParamsCount = 10
+	maxValueLength = 256
+)
+
+var (
+	ErrTemplateNotFound  = errors.New("utm template is not found")
+	ErrInvalidName       = errors.New("utm template name must be 1-64 latin letters, digits, '-' or '_'")
+	ErrEmptyTemplate     = errors.New("utm template must have at least one parameter")
+	ErrTooManyParams     = errors.New("utm template must have at most 10 parameters")
+	ErrInvalidParamValue = errors.New("utm parameter value must be 1-256 characters")
+
+	nameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+)
+
+// Template - именованный набор UTM параметров, например {"utm_source": "mail", "utm_medium": "email"}.
+type Template struct {
+	Name   string            `json:"name"`
+	Params map[string]string `json:"params"`
+}
+
+// Validate проверяет имя шаблона и его параметры: все ключи должны начинаться с utm_, значения не пустые.
+func (t Template) Validate() error {
+	if len(t.Name) > maxNameLength || !nameRe.MatchString(t.Name) {
+		return ErrInvalidName
+	}
+	if len(t.Params) == 0 {
+		return ErrEmptyTemplate
+	}
+	if len(t.Params) > maxParamsCount {
+		return ErrTooManyParams
+	}
+	for k, v := range t.Params {
+		if !strings.HasPrefix(k, paramPrefix) || len(k) == len(paramPrefix) {
+			return fmt.Errorf("utm parameter %q must start with %s", k, paramPrefix)
+		}
+		if v == "" || len(v) > maxValueLength {
+			return ErrInvalidParamValue
+		}
+	}
+	return nil
+}
+
+// Apply добавляет параметры шаблона в ссылку rawURL.
+// Параметры, уже заданные в ссылке, имеют приоритет и шаблоном не перезаписываются.
+// Остальные дописываются в конец строки запроса в порядке имен, исходная строка запроса и фрагмент сохраняются.
+func Apply(rawURL string, t Template) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	own := u.Query()
+	keys := make([]string, 0, len(t.Params))
+	for k := range t.Params {
+		if _, ok := own[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return rawURL, nil
+	}
+	sort.Strings(keys)
+
+	extra := make([]string, 0, len(keys))
+	for _, k := range keys {
+		extra = append(extra, url.QueryEscape(k)+"="+url.QueryEscape(t.Params[k]))
+	}
+	if u.RawQuery != "" {
+		u.RawQuery += "&"
+	}
+	u.RawQuery += strings.Join(extra, "&")
+	return u.String(), nil
+}
+
+// Store описывает контракт хранилища шаблонов. Имена шаблонов уникальны в пределах пользователя.
+type Store interface {
+	// Save создает или заменяет шаблон пользователя.
+	Save(ctx context.Context, user string, t Template) error
+	// Get возвращает шаблон пользователя по имени или ErrTemplateNotFound.
+	Get(ctx context.Context, user string, name string) (Template, error)
+	// List возвращает шаблоны пользователя, упорядоченные по имени.
+	List(ctx context.Context, user string) ([]Template, error)
+	// Delete удаляет шаблон пользователя или возвращает ErrTemplateNotFound.
+	Delete(ctx context.Context, user string, name string) error
+	// Close завершает работу хранилища.
+	Close() error
+}
+
+// sortTemplates упорядочивает шаблоны по имени.
+func sortTemplates(templates []Template) []Template {
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates
+}
@@ -0,0 +1,77 @@
+package utm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApply(t *testing.T) {
+	tmpl := Template{Name: "newsletter", Params: map[string]string{
+		"utm_source":   "mail",
+		"utm_medium":   "email",
+		"utm_campaign": "spring sale",
+	}}
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "no query",
+			url:  "https://ya.ru/path",
+			want: "https://ya.ru/path?utm_campaign=spring+sale&utm_medium=email&utm_source=mail",
+		},
+		{
+			name: "existing query is kept",
+			url:  "https://ya.ru/?text=go&b=%2F",
+			want: "https://ya.ru/?text=go&b=%2F&utm_campaign=spring+sale&utm_medium=email&utm_source=mail",
+		},
+		{
+			name: "own utm wins",
+			url:  "https://ya.ru/?utm_source=site#top",
+			want: "https://ya.ru/?utm_source=site&utm_campaign=spring+sale&utm_medium=email#top",
+		},
+		{
+			name: "nothing to add",
+			url:  "https://ya.ru/?utm_source=a&utm_medium=b&utm_campaign=c",
+			want: "https://ya.ru/?utm_source=a&utm_medium=b&utm_campaign=c",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(tt.url, tmpl)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTemplate_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    Template
+		wantErr bool
+	}{
+		{name: "valid", tmpl: Template{Name: "news-1", Params: map[string]string{"utm_source": "mail"}}},
+		{name: "empty name", tmpl: Template{Params: map[string]string{"utm_source": "mail"}}, wantErr: true},
+		{name: "bad name", tmpl: Template{Name: "a b", Params: map[string]string{"utm_source": "mail"}}, wantErr: true},
+		{name: "long name", tmpl: Template{Name: strings.Repeat("a", 65), Params: map[string]string{"utm_source": "mail"}}, wantErr: true},
+		{name: "no params", tmpl: Template{Name: "news"}, wantErr: true},
+		{name: "not utm param", tmpl: Template{Name: "news", Params: map[string]string{"ref": "mail"}}, wantErr: true},
+		{name: "bare prefix", tmpl: Template{Name: "news", Params: map[string]string{"utm_": "mail"}}, wantErr: true},
+		{name: "empty value", tmpl: Template{Name: "news", Params: map[string]string{"utm_source": ""}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tmpl.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}